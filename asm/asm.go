@@ -0,0 +1,314 @@
+// Package asm assembles CHIP-8, SUPER-CHIP and XO-CHIP programs written in
+// the Octo assembly language into ROM images.
+//
+// Supported are labels (": name"), :const, :alias, :org, :byte, :call,
+// :breakpoint, :macro, loop/while/again, if ... then, if ... begin ... else
+// ... end (including the <, >, <= and >= forms that use vf), numbers in
+// statement position as data bytes and every Octo statement for the three
+// instruction sets. As in Octo, a bare label name calls it as a subroutine
+// and execution starts at the label main.
+package asm
+
+import (
+	"strings"
+)
+
+// Program is an assembled ROM and the symbols defined while assembling it.
+type Program struct {
+	Rom     []byte // Loaded at 0x200
+	Symbols *Symbols
+}
+
+const (
+	origin      = 0x200
+	maxAddress  = 0x10000
+	maxExpanded = 10000 // Macro expansions before giving up on recursion
+)
+
+// Words with a meaning of their own that can't be used as names.
+var keywords = map[string]bool{
+	":=": true, "+=": true, "-=": true, "=-": true, "|=": true, "&=": true, "^=": true,
+	">>=": true, "<<=": true, "==": true, "!=": true, "<": true, ">": true, "<=": true, ">=": true,
+	"key": true, "-key": true, "i": true, "delay": true, "buzzer": true, "pitch": true,
+	"random": true, "hex": true, "bighex": true, "long": true, "clear": true, "return": true,
+	";": true, "exit": true, "hires": true, "lores": true, "scroll-down": true, "scroll-up": true,
+	"scroll-left": true, "scroll-right": true, "jump": true, "jump0": true, "native": true,
+	"sprite": true, "bcd": true, "save": true, "load": true, "saveflags": true, "loadflags": true,
+	"plane": true, "audio": true, "if": true, "then": true, "begin": true, "else": true,
+	"end": true, "loop": true, "while": true, "again": true, "-": true, "{": true, "}": true,
+}
+
+type macro struct {
+	args []string
+	body []Token
+}
+
+// A forward reference to a label, patched once every label is known.
+type fixup struct {
+	addr int   // Address of the instruction to patch
+	tok  Token // The label name
+	long bool  // 16 bit address after F000 rather than NNN
+}
+
+// An open if/else or loop.
+type block struct {
+	kind   string // "if", "else" or "loop"
+	tok    Token
+	addr   int   // Loop start, or the jump to patch at else/end
+	breaks []int // Jumps out of a loop from while
+}
+
+type assembler struct {
+	toks []Token
+	pos  int
+
+	mem [maxAddress]byte
+	pc  int
+	end int // One past the highest address written
+
+	labels      map[string]uint16
+	consts      map[string]int
+	aliases     map[string]int
+	macros      map[string]*macro
+	breakpoints map[string]uint16
+	fixups      []fixup
+	blocks      []block
+	expanded    int
+}
+
+// Assemble turns Octo source into a ROM. Errors are *Error values giving the
+// line and column of the problem.
+func Assemble(src string) (*Program, error) {
+	a := &assembler{
+		toks:        tokenize(src),
+		pc:          origin,
+		end:         origin,
+		labels:      map[string]uint16{},
+		consts:      map[string]int{},
+		aliases:     map[string]int{},
+		macros:      map[string]*macro{},
+		breakpoints: map[string]uint16{},
+	}
+
+	// Like Octo, programs with a main label start with a jump to it
+	for i := 0; i+1 < len(a.toks); i++ {
+		if a.toks[i].Text == ":" && a.toks[i+1].Text == "main" {
+			a.fixups = append(a.fixups, fixup{addr: origin, tok: a.toks[i+1]})
+			if err := a.emit(0x1000); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	for a.pos < len(a.toks) {
+		if err := a.statement(); err != nil {
+			return nil, err
+		}
+	}
+	if len(a.blocks) > 0 {
+		b := a.blocks[len(a.blocks)-1]
+		return nil, errorAt(b.tok, "%s is never closed", b.tok.Text)
+	}
+	for _, f := range a.fixups {
+		addr, ok := a.labels[f.tok.Text]
+		if !ok {
+			return nil, errorAt(f.tok, "undefined label %q", f.tok.Text)
+		}
+		if f.long {
+			a.mem[f.addr+2] = byte(addr >> 8)
+			a.mem[f.addr+3] = byte(addr)
+			continue
+		}
+		if addr > 0xFFF {
+			return nil, errorAt(f.tok, "label %q at 0x%04X is out of reach, use i := long", f.tok.Text, addr)
+		}
+		a.mem[f.addr] |= byte(addr >> 8)
+		a.mem[f.addr+1] = byte(addr)
+	}
+
+	symbols := &Symbols{Labels: a.labels, Consts: a.consts, Breakpoints: a.breakpoints}
+	rom := append([]byte(nil), a.mem[origin:a.end]...)
+	return &Program{Rom: rom, Symbols: symbols}, nil
+}
+
+func (self *assembler) next() (Token, error) {
+	if self.pos >= len(self.toks) {
+		last := Token{Line: 1, Col: 1}
+		if len(self.toks) > 0 {
+			last = self.toks[len(self.toks)-1]
+			last.Col += len(last.Text)
+		}
+		return last, errorAt(last, "unexpected end of source")
+	}
+	tok := self.toks[self.pos]
+	self.pos++
+	return tok, nil
+}
+
+func (self *assembler) peek() string {
+	if self.pos >= len(self.toks) {
+		return ""
+	}
+	return self.toks[self.pos].Text
+}
+
+// Takes the next token, which must be want.
+func (self *assembler) expect(want string) error {
+	tok, err := self.next()
+	if err != nil {
+		return err
+	}
+	if tok.Text != want {
+		return errorAt(tok, "expected %q, found %q", want, tok.Text)
+	}
+	return nil
+}
+
+func (self *assembler) emitByte(tok Token, b byte) error {
+	if self.pc >= maxAddress {
+		return errorAt(tok, "program is larger than 64KiB")
+	}
+	self.mem[self.pc] = b
+	self.pc++
+	if self.pc > self.end {
+		self.end = self.pc
+	}
+	return nil
+}
+
+func (self *assembler) emit(op uint16) error {
+	tok := Token{Line: 1, Col: 1}
+	if self.pos > 0 && self.pos <= len(self.toks) {
+		tok = self.toks[self.pos-1]
+	}
+	if err := self.emitByte(tok, byte(op>>8)); err != nil {
+		return err
+	}
+	return self.emitByte(tok, byte(op))
+}
+
+// Checks name is usable for a new label, constant, alias or macro.
+func (self *assembler) checkName(tok Token) error {
+	name := tok.Text
+	_, isNumber := parseNumber(name)
+	_, isRegister := parseRegister(name)
+	if isNumber || isRegister || keywords[name] || strings.HasPrefix(name, ":") {
+		return errorAt(tok, "%q can't be used as a name", name)
+	}
+	if _, ok := self.labels[name]; ok {
+		return errorAt(tok, "%q is already a label", name)
+	}
+	if _, ok := self.consts[name]; ok {
+		return errorAt(tok, "%q is already a constant", name)
+	}
+	if _, ok := self.aliases[name]; ok {
+		return errorAt(tok, "%q is already an alias", name)
+	}
+	if _, ok := self.macros[name]; ok {
+		return errorAt(tok, "%q is already a macro", name)
+	}
+	return nil
+}
+
+// A number or constant.
+func (self *assembler) value(tok Token) (int, error) {
+	if v, ok := parseNumber(tok.Text); ok {
+		return v, nil
+	}
+	if v, ok := self.consts[tok.Text]; ok {
+		return v, nil
+	}
+	return 0, errorAt(tok, "expected a number or constant, found %q", tok.Text)
+}
+
+// A value that must fit in bits, negative values allowed down to -2^(bits-1).
+func (self *assembler) sized(bits uint) (int, error) {
+	tok, err := self.next()
+	if err != nil {
+		return 0, err
+	}
+	v, err := self.value(tok)
+	if err != nil {
+		return 0, err
+	}
+	if v < -(1<<(bits-1)) || v >= 1<<bits {
+		return 0, errorAt(tok, "%d does not fit in %d bits", v, bits)
+	}
+	return v & (1<<bits - 1), nil
+}
+
+// A register name or alias.
+func (self *assembler) registerOf(tok Token) (int, bool) {
+	if r, ok := parseRegister(tok.Text); ok {
+		return r, true
+	}
+	r, ok := self.aliases[tok.Text]
+	return r, ok
+}
+
+func (self *assembler) register() (int, error) {
+	tok, err := self.next()
+	if err != nil {
+		return 0, err
+	}
+	r, ok := self.registerOf(tok)
+	if !ok {
+		return 0, errorAt(tok, "expected a register, found %q", tok.Text)
+	}
+	return r, nil
+}
+
+// Emits op with a 12 bit address from the next token, which can be a number,
+// constant or label defined before or after this point.
+func (self *assembler) emitAddress(op uint16) error {
+	tok, err := self.next()
+	if err != nil {
+		return err
+	}
+	addr, ok := self.labels[tok.Text]
+	if !ok {
+		if _, isConst := self.consts[tok.Text]; isConst || isNumberToken(tok) {
+			v, err := self.value(tok)
+			if err != nil {
+				return err
+			}
+			if v < 0 || v > 0xFFF {
+				return errorAt(tok, "address 0x%X is out of range", v)
+			}
+			addr = uint16(v)
+		} else {
+			if err := self.checkLabelRef(tok); err != nil {
+				return err
+			}
+			self.fixups = append(self.fixups, fixup{addr: self.pc, tok: tok})
+		}
+	} else if addr > 0xFFF {
+		return errorAt(tok, "label %q at 0x%04X is out of reach, use i := long", tok.Text, addr)
+	}
+	return self.emit(op | addr)
+}
+
+func isNumberToken(tok Token) bool {
+	_, ok := parseNumber(tok.Text)
+	return ok
+}
+
+// Labels can be used before they are defined, but not with names that could
+// never be labels.
+func (self *assembler) checkLabelRef(tok Token) error {
+	if _, isRegister := self.registerOf(tok); isRegister || keywords[tok.Text] || strings.HasPrefix(tok.Text, ":") {
+		return errorAt(tok, "expected an address, found %q", tok.Text)
+	}
+	return nil
+}
+
+// Patches the jump at addr to continue at target.
+func (self *assembler) patchJump(tok Token, addr, target int) error {
+	if target > 0xFFF {
+		return errorAt(tok, "jump target 0x%04X is out of reach", target)
+	}
+	self.mem[addr] = 0x10 | byte(target>>8)
+	self.mem[addr+1] = byte(target)
+	return nil
+}
@@ -0,0 +1,156 @@
+package asm_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/bomer/chip8/asm"
+)
+
+func assemble(t *testing.T, src string) []byte {
+	t.Helper()
+	prog, err := asm.Assemble(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prog.Rom
+}
+
+func TestStatements(t *testing.T) {
+	cases := []struct {
+		src  string
+		want []byte
+	}{
+		{"clear return exit hires lores", []byte{0x00, 0xE0, 0x00, 0xEE, 0x00, 0xFD, 0x00, 0xFF, 0x00, 0xFE}},
+		{"v3 := 0x12 v3 += 1 v3 -= 1", []byte{0x63, 0x12, 0x73, 0x01, 0x73, 0xFF}},
+		{"va := vb va <<= vb va =- vb", []byte{0x8A, 0xB0, 0x8A, 0xBE, 0x8A, 0xB7}},
+		{"v1 := random 0xFF v2 := key v3 := delay", []byte{0xC1, 0xFF, 0xF2, 0x0A, 0xF3, 0x07}},
+		{"delay := v1 buzzer := v2 pitch := v3", []byte{0xF1, 0x15, 0xF2, 0x18, 0xF3, 0x3A}},
+		{"i := 0x300 i += v2 i := hex v3 i := bighex v4", []byte{0xA3, 0x00, 0xF2, 0x1E, 0xF3, 0x29, 0xF4, 0x30}},
+		{"i := long 0xBEEF", []byte{0xF0, 0x00, 0xBE, 0xEF}},
+		{"sprite v0 v1 5 bcd v2 save v3 load v4", []byte{0xD0, 0x15, 0xF2, 0x33, 0xF3, 0x55, 0xF4, 0x65}},
+		{"save v1 - v3 load v2 - v1 saveflags v7 loadflags v7", []byte{0x51, 0x32, 0x52, 0x13, 0xF7, 0x75, 0xF7, 0x85}},
+		{"scroll-down 4 scroll-up 2 scroll-left scroll-right plane 3 audio", []byte{0x00, 0xC4, 0x00, 0xD2, 0x00, 0xFC, 0x00, 0xFB, 0xF3, 0x01, 0xF0, 0x02}},
+		{":const size 8 :alias x v5 x := size 1 0b11 -1 :byte size", []byte{0x65, 0x08, 0x01, 0x03, 0xFF, 0x08}},
+	}
+	for _, c := range cases {
+		if got := assemble(t, c.src); !bytes.Equal(got, c.want) {
+			t.Errorf("%q: got % X, want % X", c.src, got, c.want)
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	src := `
+: main
+	i := dot
+	draw
+	jump main
+: draw        # called as a subroutine
+	sprite v0 v0 1
+	;
+: dot
+	0xFF
+`
+	prog, err := asm.Assemble(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x12, 0x02, // 0x200 jump main
+		0xA2, 0x0C, // 0x202 i := dot
+		0x22, 0x08, // 0x204 draw
+		0x12, 0x02, // 0x206 jump main
+		0xD0, 0x01, // 0x208 sprite
+		0x00, 0xEE, // 0x20A ;
+		0xFF, // 0x20C
+	}
+	if !bytes.Equal(prog.Rom, want) {
+		t.Errorf("got % X, want % X", prog.Rom, want)
+	}
+	if prog.Symbols.Labels["dot"] != 0x20C {
+		t.Errorf("dot at %03X", prog.Symbols.Labels["dot"])
+	}
+}
+
+func TestControlFlow(t *testing.T) {
+	cases := []struct {
+		src  string
+		want []byte
+	}{
+		{"if v1 == 3 then v2 := 1", []byte{0x41, 0x03, 0x62, 0x01}},
+		{"if v1 != v2 then clear", []byte{0x51, 0x20, 0x00, 0xE0}},
+		{"if v1 key then clear", []byte{0xE1, 0xA1, 0x00, 0xE0}},
+		{"if v1 < v2 then clear", []byte{0x8F, 0x10, 0x8F, 0x25, 0x3F, 0x01, 0x00, 0xE0}},
+		{"if v1 >= 5 then clear", []byte{0x6F, 0x05, 0x8F, 0x17, 0x4F, 0x01, 0x00, 0xE0}},
+		// 0x200 skip if true, 0x202 jump to else, 0x204 body, 0x206 jump past else
+		{"if v0 == 0 begin clear else exit end", []byte{0x30, 0x00, 0x12, 0x08, 0x00, 0xE0, 0x12, 0x0A, 0x00, 0xFD}},
+		// 0x200 body, 0x202 skip if still going, 0x204 break, 0x206 again
+		{"loop v0 += 1 while v0 != 10 again", []byte{0x70, 0x01, 0x40, 0x0A, 0x12, 0x08, 0x12, 0x00}},
+	}
+	for _, c := range cases {
+		if got := assemble(t, c.src); !bytes.Equal(got, c.want) {
+			t.Errorf("%q: got % X, want % X", c.src, got, c.want)
+		}
+	}
+}
+
+func TestMacros(t *testing.T) {
+	src := `
+:macro set reg value { reg := value }
+set v1 5
+set v2 v1
+`
+	want := []byte{0x61, 0x05, 0x82, 0x10}
+	if got := assemble(t, src); !bytes.Equal(got, want) {
+		t.Errorf("got % X, want % X", got, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cases := []struct {
+		src       string
+		line, col int
+	}{
+		{"clear\n  v1 := 300", 2, 9},
+		{"jump nowhere", 1, 6},
+		{"loop clear", 1, 1},
+		{"again", 1, 1},
+		{"v1 ?= 2", 1, 4},
+		{": v1", 1, 3},
+		{":macro m { m }\nm", 1, 12},
+	}
+	for _, c := range cases {
+		_, err := asm.Assemble(c.src)
+		var asmErr *asm.Error
+		if !errors.As(err, &asmErr) {
+			t.Errorf("%q: expected *asm.Error, got %v", c.src, err)
+			continue
+		}
+		if asmErr.Line != c.line || asmErr.Col != c.col {
+			t.Errorf("%q: error at %d:%d, want %d:%d (%v)", c.src, asmErr.Line, asmErr.Col, c.line, c.col, err)
+		}
+	}
+}
+
+func TestSymbolsRoundTrip(t *testing.T) {
+	prog, err := asm.Assemble(":const n 3 : main :breakpoint here jump main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := prog.Symbols.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	syms, err := asm.ReadSymbols(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if syms.Labels["main"] != 0x202 || syms.Breakpoints["here"] != 0x202 || syms.Consts["n"] != 3 {
+		t.Errorf("unexpected symbols %+v", syms)
+	}
+	if name, ok := syms.Label(0x202); !ok || name != "main" {
+		t.Errorf("Label(0x202) = %q, %v", name, ok)
+	}
+}
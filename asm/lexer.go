@@ -0,0 +1,88 @@
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Token is a whitespace separated word of source with its position.
+type Token struct {
+	Text string
+	Line int // 1 based
+	Col  int // 1 based, in bytes
+}
+
+// Error is an assembly error at a position in the source.
+type Error struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (self *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", self.Line, self.Col, self.Msg)
+}
+
+func errorAt(tok Token, format string, args ...interface{}) *Error {
+	return &Error{Line: tok.Line, Col: tok.Col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Splits source into tokens, dropping # comments.
+func tokenize(src string) []Token {
+	var toks []Token
+	for n, line := range strings.Split(src, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		start := -1
+		for i := 0; i <= len(line); i++ {
+			space := i == len(line) || line[i] == ' ' || line[i] == '\t' || line[i] == '\r'
+			if space && start >= 0 {
+				toks = append(toks, Token{Text: line[start:i], Line: n + 1, Col: start + 1})
+				start = -1
+			} else if !space && start < 0 {
+				start = i
+			}
+		}
+	}
+	return toks
+}
+
+// Parses a decimal, 0x hex or 0b binary number, optionally negative.
+func parseNumber(s string) (int, bool) {
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	base := 10
+	switch {
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		base, s = 16, s[2:]
+	case strings.HasPrefix(s, "0b") || strings.HasPrefix(s, "0B"):
+		base, s = 2, s[2:]
+	}
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(s, base, 32)
+	if err != nil {
+		return 0, false
+	}
+	if neg {
+		v = -v
+	}
+	return int(v), true
+}
+
+// Parses v0-vf (either case) into a register number.
+func parseRegister(s string) (int, bool) {
+	if len(s) != 2 || (s[0] != 'v' && s[0] != 'V') {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 4)
+	if err != nil {
+		return 0, false
+	}
+	return int(v), true
+}
@@ -0,0 +1,632 @@
+package asm
+
+import (
+	"strings"
+)
+
+// Assembles one statement, directive or data byte.
+func (self *assembler) statement() error {
+	tok, err := self.next()
+	if err != nil {
+		return err
+	}
+
+	switch tok.Text {
+	case ":":
+		return self.defineLabel()
+	case ":const":
+		name, err := self.next()
+		if err != nil {
+			return err
+		}
+		if err := self.checkName(name); err != nil {
+			return err
+		}
+		v, err := self.next()
+		if err != nil {
+			return err
+		}
+		value, err := self.value(v)
+		if err != nil {
+			return err
+		}
+		self.consts[name.Text] = value
+		return nil
+	case ":alias":
+		name, err := self.next()
+		if err != nil {
+			return err
+		}
+		if err := self.checkName(name); err != nil {
+			return err
+		}
+		r, err := self.register()
+		if err != nil {
+			return err
+		}
+		self.aliases[name.Text] = r
+		return nil
+	case ":org":
+		v, err := self.next()
+		if err != nil {
+			return err
+		}
+		addr, err := self.value(v)
+		if err != nil {
+			return err
+		}
+		if addr < origin || addr >= maxAddress {
+			return errorAt(v, "address 0x%X is outside the program", addr)
+		}
+		self.pc = addr
+		return nil
+	case ":byte":
+		b, err := self.sized(8)
+		if err != nil {
+			return err
+		}
+		return self.emitByte(tok, byte(b))
+	case ":call":
+		return self.emitAddress(0x2000)
+	case ":breakpoint":
+		name, err := self.next()
+		if err != nil {
+			return err
+		}
+		self.breakpoints[name.Text] = uint16(self.pc)
+		return nil
+	case ":macro":
+		return self.defineMacro()
+
+	case "clear":
+		return self.emit(0x00E0)
+	case "return", ";":
+		return self.emit(0x00EE)
+	case "exit":
+		return self.emit(0x00FD)
+	case "lores":
+		return self.emit(0x00FE)
+	case "hires":
+		return self.emit(0x00FF)
+	case "scroll-down":
+		n, err := self.nibble()
+		if err != nil {
+			return err
+		}
+		return self.emit(0x00C0 | uint16(n))
+	case "scroll-up":
+		n, err := self.nibble()
+		if err != nil {
+			return err
+		}
+		return self.emit(0x00D0 | uint16(n))
+	case "scroll-right":
+		return self.emit(0x00FB)
+	case "scroll-left":
+		return self.emit(0x00FC)
+	case "jump":
+		return self.emitAddress(0x1000)
+	case "jump0":
+		return self.emitAddress(0xB000)
+	case "native":
+		return self.emitAddress(0x0000)
+	case "sprite":
+		x, err := self.register()
+		if err != nil {
+			return err
+		}
+		y, err := self.register()
+		if err != nil {
+			return err
+		}
+		n, err := self.nibble()
+		if err != nil {
+			return err
+		}
+		return self.emit(0xD000 | uint16(x)<<8 | uint16(y)<<4 | uint16(n))
+	case "bcd":
+		return self.emitRegister(0xF033)
+	case "save", "load":
+		return self.saveLoad(tok.Text == "save")
+	case "saveflags":
+		return self.emitRegister(0xF075)
+	case "loadflags":
+		return self.emitRegister(0xF085)
+	case "plane":
+		n, err := self.sized(2)
+		if err != nil {
+			return err
+		}
+		return self.emit(0xF001 | uint16(n)<<8)
+	case "audio":
+		return self.emit(0xF002)
+	case "delay":
+		return self.timerStore(0xF015)
+	case "buzzer":
+		return self.timerStore(0xF018)
+	case "pitch":
+		return self.timerStore(0xF03A)
+	case "i":
+		return self.indexOp()
+
+	case "if":
+		return self.ifStatement(tok)
+	case "else":
+		return self.elseStatement(tok)
+	case "end":
+		return self.endStatement(tok)
+	case "loop":
+		self.blocks = append(self.blocks, block{kind: "loop", tok: tok, addr: self.pc})
+		return nil
+	case "while":
+		return self.whileStatement(tok)
+	case "again":
+		return self.againStatement(tok)
+	}
+
+	if x, ok := self.registerOf(tok); ok {
+		return self.registerOp(x)
+	}
+	if m, ok := self.macros[tok.Text]; ok {
+		return self.expand(tok, m)
+	}
+	if v, ok := parseNumber(tok.Text); ok {
+		if v < -128 || v > 0xFF {
+			return errorAt(tok, "%d does not fit in a byte", v)
+		}
+		return self.emitByte(tok, byte(v))
+	}
+	if v, ok := self.consts[tok.Text]; ok {
+		return self.emitByte(tok, byte(v))
+	}
+	if keywords[tok.Text] || strings.HasPrefix(tok.Text, ":") {
+		return errorAt(tok, "unexpected %q", tok.Text)
+	}
+
+	// A bare name calls the label
+	self.pos--
+	return self.emitAddress(0x2000)
+}
+
+func (self *assembler) defineLabel() error {
+	name, err := self.next()
+	if err != nil {
+		return err
+	}
+	if err := self.checkName(name); err != nil {
+		return err
+	}
+	self.labels[name.Text] = uint16(self.pc)
+	return nil
+}
+
+// A 4 bit operand such as a sprite height or scroll distance.
+func (self *assembler) nibble() (int, error) {
+	tok, err := self.next()
+	if err != nil {
+		return 0, err
+	}
+	v, err := self.value(tok)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 || v > 0xF {
+		return 0, errorAt(tok, "%d is not between 0 and 15", v)
+	}
+	return v, nil
+}
+
+// Emits op with X taken from the next token.
+func (self *assembler) emitRegister(op uint16) error {
+	x, err := self.register()
+	if err != nil {
+		return err
+	}
+	return self.emit(op | uint16(x)<<8)
+}
+
+// delay := vx, buzzer := vx and pitch := vx.
+func (self *assembler) timerStore(op uint16) error {
+	if err := self.expect(":="); err != nil {
+		return err
+	}
+	return self.emitRegister(op)
+}
+
+// save vx, load vx and the XO-CHIP ranges save vx - vy, load vx - vy.
+func (self *assembler) saveLoad(save bool) error {
+	x, err := self.register()
+	if err != nil {
+		return err
+	}
+	if self.peek() != "-" {
+		if save {
+			return self.emit(0xF055 | uint16(x)<<8)
+		}
+		return self.emit(0xF065 | uint16(x)<<8)
+	}
+	self.pos++
+	y, err := self.register()
+	if err != nil {
+		return err
+	}
+	op := uint16(0x5002)
+	if !save {
+		op = 0x5003
+	}
+	return self.emit(op | uint16(x)<<8 | uint16(y)<<4)
+}
+
+// i := NNN, i := long NNNN, i := hex vx, i := bighex vx and i += vx.
+func (self *assembler) indexOp() error {
+	op, err := self.next()
+	if err != nil {
+		return err
+	}
+	switch op.Text {
+	case "+=":
+		return self.emitRegister(0xF01E)
+	case ":=":
+	default:
+		return errorAt(op, "expected := or += after i, found %q", op.Text)
+	}
+
+	switch self.peek() {
+	case "hex":
+		self.pos++
+		return self.emitRegister(0xF029)
+	case "bighex":
+		self.pos++
+		return self.emitRegister(0xF030)
+	case "long":
+		self.pos++
+		return self.longAddress()
+	}
+	return self.emitAddress(0xA000)
+}
+
+// F000 NNNN, which can reach all 64KiB.
+func (self *assembler) longAddress() error {
+	tok, err := self.next()
+	if err != nil {
+		return err
+	}
+	addr, ok := self.labels[tok.Text]
+	if !ok {
+		if _, isConst := self.consts[tok.Text]; isConst || isNumberToken(tok) {
+			v, err := self.value(tok)
+			if err != nil {
+				return err
+			}
+			if v < 0 || v >= maxAddress {
+				return errorAt(tok, "address 0x%X is out of range", v)
+			}
+			addr = uint16(v)
+		} else {
+			if err := self.checkLabelRef(tok); err != nil {
+				return err
+			}
+			self.fixups = append(self.fixups, fixup{addr: self.pc, tok: tok, long: true})
+		}
+	}
+	if err := self.emit(0xF000); err != nil {
+		return err
+	}
+	return self.emit(addr)
+}
+
+// Operators taking a second register as vy, giving the low nibble of 8XYN.
+var registerOps = map[string]uint16{
+	":=": 0x0, "|=": 0x1, "&=": 0x2, "^=": 0x3, "+=": 0x4,
+	"-=": 0x5, ">>=": 0x6, "=-": 0x7, "<<=": 0xE,
+}
+
+// Statements starting with a register: assignments and arithmetic.
+func (self *assembler) registerOp(x int) error {
+	op, err := self.next()
+	if err != nil {
+		return err
+	}
+	vx := uint16(x) << 8
+	n, isRegisterOp := registerOps[op.Text]
+	if !isRegisterOp {
+		return errorAt(op, "unknown operator %q", op.Text)
+	}
+
+	rhs, err := self.next()
+	if err != nil {
+		return err
+	}
+	if y, ok := self.registerOf(rhs); ok {
+		return self.emit(0x8000 | vx | uint16(y)<<4 | n)
+	}
+
+	switch op.Text {
+	case ":=":
+		switch rhs.Text {
+		case "random":
+			mask, err := self.sized(8)
+			if err != nil {
+				return err
+			}
+			return self.emit(0xC000 | vx | uint16(mask))
+		case "key":
+			return self.emit(0xF00A | vx)
+		case "delay":
+			return self.emit(0xF007 | vx)
+		}
+		self.pos--
+		v, err := self.sized(8)
+		if err != nil {
+			return err
+		}
+		return self.emit(0x6000 | vx | uint16(v))
+	case "+=":
+		self.pos--
+		v, err := self.sized(8)
+		if err != nil {
+			return err
+		}
+		return self.emit(0x7000 | vx | uint16(v))
+	case "-=":
+		// Adding the two's complement leaves vf alone, unlike 8XY5
+		self.pos--
+		v, err := self.sized(8)
+		if err != nil {
+			return err
+		}
+		return self.emit(0x7000 | vx | uint16(-v&0xFF))
+	}
+	return errorAt(rhs, "expected a register after %s, found %q", op.Text, rhs.Text)
+}
+
+// A condition of if or while, as the instruction that skips when it holds
+// and the one that skips when it doesn't.
+type condition struct {
+	skipIfTrue  uint16
+	skipIfFalse uint16
+}
+
+// Parses a condition, emitting the vf arithmetic the comparison operators
+// need first.
+func (self *assembler) condition() (condition, error) {
+	x, err := self.register()
+	if err != nil {
+		return condition{}, err
+	}
+	vx := uint16(x) << 8
+	op, err := self.next()
+	if err != nil {
+		return condition{}, err
+	}
+	switch op.Text {
+	case "key":
+		return condition{skipIfTrue: 0xE09E | vx, skipIfFalse: 0xE0A1 | vx}, nil
+	case "-key":
+		return condition{skipIfTrue: 0xE0A1 | vx, skipIfFalse: 0xE09E | vx}, nil
+	case "==", "!=", "<", ">", "<=", ">=":
+	default:
+		return condition{}, errorAt(op, "unknown comparison %q", op.Text)
+	}
+
+	rhs, err := self.next()
+	if err != nil {
+		return condition{}, err
+	}
+	y, isRegister := self.registerOf(rhs)
+	var nn int
+	if !isRegister {
+		self.pos--
+		if nn, err = self.sized(8); err != nil {
+			return condition{}, err
+		}
+	}
+
+	equal := func(vx uint16) condition {
+		if isRegister {
+			return condition{skipIfTrue: 0x5000 | vx | uint16(y)<<4, skipIfFalse: 0x9000 | vx | uint16(y)<<4}
+		}
+		return condition{skipIfTrue: 0x3000 | vx | uint16(nn), skipIfFalse: 0x4000 | vx | uint16(nn)}
+	}
+	switch op.Text {
+	case "==":
+		return equal(vx), nil
+	case "!=":
+		c := equal(vx)
+		return condition{skipIfTrue: c.skipIfFalse, skipIfFalse: c.skipIfTrue}, nil
+	}
+
+	// vf ends up 0 when the subtraction borrowed. For < and >= that is
+	// vx - rhs, for > and <= rhs - vx.
+	var ops [2]uint16
+	less := op.Text == "<" || op.Text == ">="
+	switch {
+	case isRegister && less:
+		ops = [2]uint16{0x8F00 | uint16(x)<<4, 0x8F05 | uint16(y)<<4}
+	case isRegister:
+		ops = [2]uint16{0x8F00 | uint16(y)<<4, 0x8F05 | uint16(x)<<4}
+	case less:
+		ops = [2]uint16{0x6F00 | uint16(nn), 0x8F07 | uint16(x)<<4}
+	default:
+		ops = [2]uint16{0x6F00 | uint16(nn), 0x8F05 | uint16(x)<<4}
+	}
+	for _, o := range ops {
+		if err := self.emit(o); err != nil {
+			return condition{}, err
+		}
+	}
+	// vf == 1 means no borrow
+	noBorrow := condition{skipIfTrue: 0x3F01, skipIfFalse: 0x4F01}
+	if op.Text == ">=" || op.Text == "<=" {
+		return noBorrow, nil
+	}
+	return condition{skipIfTrue: noBorrow.skipIfFalse, skipIfFalse: noBorrow.skipIfTrue}, nil
+}
+
+// Skips the next instruction when c doesn't hold, so it only runs when it
+// does.
+func (self *assembler) emitUnless(c condition) error {
+	return self.emit(c.skipIfFalse)
+}
+
+func (self *assembler) ifStatement(tok Token) error {
+	c, err := self.condition()
+	if err != nil {
+		return err
+	}
+	word, err := self.next()
+	if err != nil {
+		return err
+	}
+	switch word.Text {
+	case "then":
+		return self.emitUnless(c)
+	case "begin":
+		// Skip the jump past the body when the condition holds
+		if err := self.emit(c.skipIfTrue); err != nil {
+			return err
+		}
+		self.blocks = append(self.blocks, block{kind: "if", tok: tok, addr: self.pc})
+		return self.emit(0x1000)
+	}
+	return errorAt(word, "expected then or begin, found %q", word.Text)
+}
+
+func (self *assembler) elseStatement(tok Token) error {
+	if len(self.blocks) == 0 || self.blocks[len(self.blocks)-1].kind != "if" {
+		return errorAt(tok, "else without if ... begin")
+	}
+	b := &self.blocks[len(self.blocks)-1]
+	jump := self.pc
+	if err := self.emit(0x1000); err != nil {
+		return err
+	}
+	if err := self.patchJump(tok, b.addr, self.pc); err != nil {
+		return err
+	}
+	b.kind, b.addr = "else", jump
+	return nil
+}
+
+func (self *assembler) endStatement(tok Token) error {
+	if len(self.blocks) == 0 || self.blocks[len(self.blocks)-1].kind == "loop" {
+		return errorAt(tok, "end without if ... begin")
+	}
+	b := self.blocks[len(self.blocks)-1]
+	self.blocks = self.blocks[:len(self.blocks)-1]
+	return self.patchJump(tok, b.addr, self.pc)
+}
+
+// Finds the innermost open loop.
+func (self *assembler) innermostLoop() *block {
+	for i := len(self.blocks) - 1; i >= 0; i-- {
+		if self.blocks[i].kind == "loop" {
+			return &self.blocks[i]
+		}
+	}
+	return nil
+}
+
+func (self *assembler) whileStatement(tok Token) error {
+	if self.innermostLoop() == nil {
+		return errorAt(tok, "while outside loop")
+	}
+	c, err := self.condition()
+	if err != nil {
+		return err
+	}
+	// Leave the loop when the condition doesn't hold
+	if err := self.emit(c.skipIfTrue); err != nil {
+		return err
+	}
+	loop := self.innermostLoop()
+	loop.breaks = append(loop.breaks, self.pc)
+	return self.emit(0x1000)
+}
+
+func (self *assembler) againStatement(tok Token) error {
+	if len(self.blocks) == 0 || self.blocks[len(self.blocks)-1].kind != "loop" {
+		return errorAt(tok, "again without loop")
+	}
+	b := self.blocks[len(self.blocks)-1]
+	self.blocks = self.blocks[:len(self.blocks)-1]
+	jump := self.pc
+	if err := self.emit(0x1000); err != nil {
+		return err
+	}
+	if err := self.patchJump(tok, jump, b.addr); err != nil {
+		return err
+	}
+	for _, addr := range b.breaks {
+		if err := self.patchJump(tok, addr, self.pc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// :macro name arg ... { body }
+func (self *assembler) defineMacro() error {
+	name, err := self.next()
+	if err != nil {
+		return err
+	}
+	if err := self.checkName(name); err != nil {
+		return err
+	}
+	m := &macro{}
+	for {
+		arg, err := self.next()
+		if err != nil {
+			return err
+		}
+		if arg.Text == "{" {
+			break
+		}
+		m.args = append(m.args, arg.Text)
+	}
+	for depth := 1; ; {
+		tok, err := self.next()
+		if err != nil {
+			return errorAt(name, "macro %q is never closed", name.Text)
+		}
+		switch tok.Text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+		m.body = append(m.body, tok)
+	}
+	self.macros[name.Text] = m
+	return nil
+}
+
+// Replaces a macro invocation with its body, arguments substituted.
+func (self *assembler) expand(tok Token, m *macro) error {
+	self.expanded++
+	if self.expanded > maxExpanded {
+		return errorAt(tok, "too many macro expansions, %q may be recursive", tok.Text)
+	}
+	args := map[string]Token{}
+	for _, name := range m.args {
+		arg, err := self.next()
+		if err != nil {
+			return err
+		}
+		args[name] = arg
+	}
+	body := make([]Token, 0, len(m.body)+len(self.toks)-self.pos)
+	for _, t := range m.body {
+		if arg, ok := args[t.Text]; ok {
+			t = arg
+		}
+		body = append(body, t)
+	}
+	self.toks = append(body, self.toks[self.pos:]...)
+	self.pos = 0
+	return nil
+}
@@ -0,0 +1,53 @@
+package asm
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// Symbols are the names defined by a program, written alongside the ROM so
+// debuggers can show labels and stop at :breakpoint directives.
+type Symbols struct {
+	Labels      map[string]uint16 `json:"labels"`
+	Consts      map[string]int    `json:"consts,omitempty"`
+	Breakpoints map[string]uint16 `json:"breakpoints,omitempty"`
+}
+
+// WriteTo writes the symbols as indented JSON.
+func (self *Symbols) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.MarshalIndent(self, "", "\t")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(b, '\n'))
+	return int64(n), err
+}
+
+// ReadSymbols reads symbols written by WriteTo.
+func ReadSymbols(r io.Reader) (*Symbols, error) {
+	syms := &Symbols{}
+	if err := json.NewDecoder(r).Decode(syms); err != nil {
+		return nil, err
+	}
+	if syms.Labels == nil {
+		syms.Labels = map[string]uint16{}
+	}
+	return syms, nil
+}
+
+// Label returns the name of a label at addr, the alphabetically first if
+// there are several.
+func (self *Symbols) Label(addr uint16) (string, bool) {
+	var names []string
+	for name, a := range self.Labels {
+		if a == addr {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "", false
+	}
+	sort.Strings(names)
+	return names[0], true
+}
@@ -0,0 +1,159 @@
+// Package audio turns the CHIP-8 sound timer into samples and plays or
+// records them through an AudioSink.
+//
+// A Synth makes one frame of samples at a time, to be called after each
+// RunFrame: a tone for frames the sound timer ran in, or the XO-CHIP audio
+// pattern once a program has loaded one. A buzz of ST=n is heard for n
+// frames, starting with the frame that set it.
+package audio
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+// AudioSink receives mono samples between -1 and 1 at the sample rate it
+// was made for.
+type AudioSink interface {
+	WriteSamples(samples []float32) error
+	Close() error
+}
+
+// NullSink discards everything, for running without sound.
+type NullSink struct{}
+
+func (NullSink) WriteSamples(samples []float32) error { return nil }
+func (NullSink) Close() error                         { return nil }
+
+// Waveform is the shape of the buzzer tone.
+type Waveform int
+
+const (
+	Square Waveform = iota
+	Triangle
+	Sawtooth
+	Sine
+)
+
+var waveformNames = []string{"square", "triangle", "sawtooth", "sine"}
+
+// ParseWaveform looks a waveform up by name: square, triangle, sawtooth or
+// sine.
+func ParseWaveform(name string) (Waveform, error) {
+	for i, n := range waveformNames {
+		if n == name {
+			return Waveform(i), nil
+		}
+	}
+	return 0, fmt.Errorf("audio: unknown waveform %q", name)
+}
+
+func (self Waveform) String() string {
+	if int(self) < len(waveformNames) {
+		return waveformNames[self]
+	}
+	return fmt.Sprintf("Waveform(%d)", int(self))
+}
+
+// Config is the sound of the buzzer.
+type Config struct {
+	SampleRate int     // Samples per second
+	Frequency  float64 // Tone in Hz
+	Volume     float64 // 0 to 1
+	Waveform   Waveform
+}
+
+// DefaultConfig is a quiet 440Hz square wave at CD sample rate.
+var DefaultConfig = Config{
+	SampleRate: 44100,
+	Frequency:  440,
+	Volume:     0.25,
+	Waveform:   Square,
+}
+
+// Synth generates the buzzer, keeping its phase between frames so the tone
+// doesn't click at frame boundaries.
+type Synth struct {
+	Config
+	sample  int64   // Samples of tone played, giving its phase
+	bit     float64 // Position in the XO-CHIP pattern, 0 to 128
+	samples []float32
+}
+
+// NewSynth makes a synth with the given sound.
+func NewSynth(config Config) *Synth {
+	return &Synth{Config: config}
+}
+
+// Frame returns one 60th of a second of samples for c, called after each
+// RunFrame. It sounds if c.Buzzing, the sound timer having been non-zero at
+// the frame's tick. The slice is reused by the next call.
+func (self *Synth) Frame(c *chip8.Chip8) []float32 {
+	n := self.SampleRate / chip8.FrameRate
+	if cap(self.samples) < n {
+		self.samples = make([]float32, n)
+	}
+	self.samples = self.samples[:n]
+
+	if !c.Buzzing {
+		for i := range self.samples {
+			self.samples[i] = 0
+		}
+		return self.samples
+	}
+
+	volume := float32(self.Volume)
+	if c.PatternLoaded {
+		// 128 one bit samples played at the rate set by the pitch register
+		step := c.PatternRate() / float64(self.SampleRate)
+		for i := range self.samples {
+			bit := int(self.bit)
+			if c.Pattern[bit/8]&(0x80>>uint(bit%8)) != 0 {
+				self.samples[i] = volume
+			} else {
+				self.samples[i] = -volume
+			}
+			self.bit = math.Mod(self.bit+step, 128)
+		}
+		return self.samples
+	}
+
+	for i := range self.samples {
+		cycles := float64(self.sample) * self.Frequency / float64(self.SampleRate)
+		self.samples[i] = volume * float32(self.wave(cycles-math.Floor(cycles)))
+		self.sample++
+	}
+	return self.samples
+}
+
+// The waveform at phase p from 0 to 1, between -1 and 1.
+func (self *Synth) wave(p float64) float64 {
+	switch self.Waveform {
+	case Triangle:
+		return 1 - 4*math.Abs(p-0.5)
+	case Sawtooth:
+		return 2*p - 1
+	case Sine:
+		return math.Sin(2 * math.Pi * p)
+	}
+	if p < 0.5 {
+		return 1
+	}
+	return -1
+}
+
+// Converts samples to signed 16 bit little endian PCM, appending to buf.
+func appendPCM16(buf []byte, samples []float32) []byte {
+	for _, s := range samples {
+		if s > 1 {
+			s = 1
+		} else if s < -1 {
+			s = -1
+		}
+		v := int16(s * math.MaxInt16)
+		buf = append(buf, byte(v), byte(v>>8))
+	}
+	return buf
+}
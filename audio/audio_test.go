@@ -0,0 +1,119 @@
+package audio_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bomer/chip8/audio"
+	"github.com/bomer/chip8/chip8"
+)
+
+func TestSynthSquareWave(t *testing.T) {
+	var c chip8.Chip8
+	c.Init()
+	config := audio.Config{SampleRate: 48000, Frequency: 400, Volume: 0.5, Waveform: audio.Square}
+	s := audio.NewSynth(config)
+
+	samples := s.Frame(&c)
+	if len(samples) != 800 {
+		t.Fatalf("expected 800 samples a frame, got %d", len(samples))
+	}
+	for _, v := range samples {
+		if v != 0 {
+			t.Fatal("synth should be silent while the sound timer is 0")
+		}
+	}
+
+	c.Sound_timer = 2
+	c.TickTimers()
+	samples = s.Frame(&c)
+	// 400Hz at 48kHz is 120 samples a cycle, high for the first 60
+	for i, v := range samples {
+		want := float32(0.5)
+		if i%120 >= 60 {
+			want = -0.5
+		}
+		if v != want {
+			t.Fatalf("sample %d: got %v want %v", i, v, want)
+		}
+	}
+}
+
+func TestSynthPattern(t *testing.T) {
+	var c chip8.Chip8
+	c.Init()
+	c.Sound_timer = 1
+	c.TickTimers()
+	c.PatternLoaded = true
+	c.Pattern[0] = 0xF0
+	// Pitch 64 plays 4000 bits a second, at 8000Hz each bit lasts 2 samples
+	s := audio.NewSynth(audio.Config{SampleRate: 8000, Frequency: 440, Volume: 1})
+	samples := s.Frame(&c)
+	for i, want := range []float32{1, 1, 1, 1, 1, 1, 1, 1, -1, -1} {
+		if samples[i] != want {
+			t.Errorf("sample %d: got %v want %v", i, samples[i], want)
+		}
+	}
+}
+
+func TestWAVSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink, err := audio.NewWAVSink(f, 8000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.WriteSamples([]float32{0, 1, -1})
+	sink.WriteSamples([]float32{0.5})
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	data, _ := os.ReadFile(path)
+	if len(data) != 44+8 || string(data[:4]) != "RIFF" || string(data[36:40]) != "data" {
+		t.Fatalf("bad WAV file % X", data)
+	}
+	if size := binary.LittleEndian.Uint32(data[40:]); size != 8 {
+		t.Errorf("data size %d, want 8", size)
+	}
+	if v := int16(binary.LittleEndian.Uint16(data[46:])); v != 32767 {
+		t.Errorf("full scale sample %d", v)
+	}
+}
+
+func TestParseWaveform(t *testing.T) {
+	for _, w := range []audio.Waveform{audio.Square, audio.Triangle, audio.Sawtooth, audio.Sine} {
+		if got, err := audio.ParseWaveform(w.String()); err != nil || got != w {
+			t.Errorf("%v: got %v, %v", w, got, err)
+		}
+	}
+	if _, err := audio.ParseWaveform("noise"); err == nil {
+		t.Error("expected an error for an unknown waveform")
+	}
+}
+
+// ST=n sounds for n frames, starting with the frame that sets it
+func TestSynthTimerFrames(t *testing.T) {
+	for _, st := range []byte{1, 3} {
+		var c chip8.Chip8
+		c.Init()
+		c.LoadBytes("buzz", []byte{0x60, st, 0xF0, 0x18, 0x12, 0x04}) // ST = n, then loop
+		s := audio.NewSynth(audio.DefaultConfig)
+		heard := 0
+		for frame := 0; frame < 10; frame++ {
+			c.RunFrame()
+			if s.Frame(&c)[0] != 0 {
+				heard++
+			}
+		}
+		if heard != int(st) {
+			t.Errorf("ST=%d was heard for %d frames", st, heard)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+//go:build android || openal
+// +build android openal
+
+package audio
+
+import (
+	"fmt"
+
+	"golang.org/x/mobile/exp/audio/al"
+)
+
+// Buffers queued on the OpenAL source. More buffers survive longer stalls
+// of the emulator goroutine at the cost of latency.
+const alBuffers = 4
+
+type alSink struct {
+	sampleRate int
+	source     al.Source
+	free       []al.Buffer
+	buf        []byte
+}
+
+// NewRealtimeSink plays samples on the default audio device through OpenAL.
+// Samples are queued a frame at a time, and dropped rather than blocking
+// when the device falls behind.
+func NewRealtimeSink(sampleRate int) (AudioSink, error) {
+	if err := al.OpenDevice(); err != nil {
+		return nil, fmt.Errorf("audio: %v", err)
+	}
+	self := &alSink{
+		sampleRate: sampleRate,
+		source:     al.GenSources(1)[0],
+		free:       al.GenBuffers(alBuffers),
+	}
+	if code := al.Error(); code != 0 {
+		al.CloseDevice()
+		return nil, fmt.Errorf("audio: OpenAL error 0x%X", code)
+	}
+	return self, nil
+}
+
+func (self *alSink) WriteSamples(samples []float32) error {
+	// Take back the buffers that have finished playing
+	if n := int(self.source.BuffersProcessed()); n > 0 {
+		done := make([]al.Buffer, n)
+		self.source.UnqueueBuffers(done...)
+		self.free = append(self.free, done...)
+	}
+	if len(self.free) == 0 {
+		return nil
+	}
+	b := self.free[len(self.free)-1]
+	self.free = self.free[:len(self.free)-1]
+
+	self.buf = appendPCM16(self.buf[:0], samples)
+	b.BufferData(al.FormatMono16, self.buf, int32(self.sampleRate))
+	self.source.QueueBuffers(b)
+	if self.source.State() != al.Playing {
+		al.PlaySources(self.source)
+	}
+	if code := al.Error(); code != 0 {
+		return fmt.Errorf("audio: OpenAL error 0x%X", code)
+	}
+	return nil
+}
+
+func (self *alSink) Close() error {
+	al.StopSources(self.source)
+	al.DeleteSources(self.source)
+	al.CloseDevice()
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !android && !openal
+// +build !android,!openal
+
+package audio
+
+import "errors"
+
+// NewRealtimeSink needs OpenAL, which desktop builds only link against when
+// built with -tags openal.
+func NewRealtimeSink(sampleRate int) (AudioSink, error) {
+	return nil, errors.New("audio: built without OpenAL, rebuild with -tags openal for sound")
+}
@@ -0,0 +1,70 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// WAVSink writes samples to a 16 bit mono WAV file, for recording headless
+// runs. The sizes in the header are filled in by Close.
+type WAVSink struct {
+	w          io.WriteSeeker
+	sampleRate int
+	size       int // Bytes of sample data written
+	buf        []byte
+}
+
+// NewWAVSink starts a WAV file at sampleRate samples per second.
+func NewWAVSink(w io.WriteSeeker, sampleRate int) (*WAVSink, error) {
+	self := &WAVSink{w: w, sampleRate: sampleRate}
+	if err := self.writeHeader(); err != nil {
+		return nil, err
+	}
+	return self, nil
+}
+
+func (self *WAVSink) writeHeader() error {
+	const bytesPerSample = 2
+	header := []interface{}{
+		[4]byte{'R', 'I', 'F', 'F'},
+		uint32(36 + self.size),
+		[4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '},
+		uint32(16), // fmt chunk size
+		uint16(1),  // PCM
+		uint16(1),  // Mono
+		uint32(self.sampleRate),
+		uint32(self.sampleRate * bytesPerSample),
+		uint16(bytesPerSample),
+		uint16(16), // Bits per sample
+		[4]byte{'d', 'a', 't', 'a'},
+		uint32(self.size),
+	}
+	for _, v := range header {
+		if err := binary.Write(self.w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteSamples appends samples to the file.
+func (self *WAVSink) WriteSamples(samples []float32) error {
+	self.buf = appendPCM16(self.buf[:0], samples)
+	n, err := self.w.Write(self.buf)
+	self.size += n
+	return err
+}
+
+// Close rewrites the header with the final sizes. It doesn't close the
+// underlying writer.
+func (self *WAVSink) Close() error {
+	if _, err := self.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := self.writeHeader(); err != nil {
+		return err
+	}
+	_, err := self.w.Seek(0, io.SeekEnd)
+	return err
+}
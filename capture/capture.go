@@ -0,0 +1,106 @@
+// Package capture turns the display into images: PNG screenshots and
+// animated GIF clips, scaled up by a whole number and coloured with a chosen
+// palette.
+package capture
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+// Palette gives the colour of each Gfx value. Plain CHIP-8 only uses the
+// first two, XO-CHIP can draw all four.
+type Palette [4]color.RGBA
+
+// DefaultPalette matches the OpenGL frontend.
+var DefaultPalette = Palette{
+	{0x00, 0x00, 0x00, 0xFF}, // Off
+	{0xFF, 0xFF, 0xFF, 0xFF}, // Plane 1
+	{0xFF, 0x66, 0x00, 0xFF}, // Plane 2
+	{0x66, 0x22, 0x00, 0xFF}, // Both
+}
+
+// ParsePalette reads four comma separated hex colours, e.g.
+// "000000,ffffff,ff6600,662200".
+func ParsePalette(s string) (Palette, error) {
+	var p Palette
+	colours := strings.Split(s, ",")
+	if len(colours) != len(p) {
+		return p, fmt.Errorf("capture: palette %q should be %d colours", s, len(p))
+	}
+	for i, c := range colours {
+		c = strings.TrimPrefix(strings.TrimSpace(c), "#")
+		v, err := strconv.ParseUint(c, 16, 32)
+		if err != nil || len(c) != 6 {
+			return p, fmt.Errorf("capture: %q is not a hex colour like ff6600", c)
+		}
+		p[i] = color.RGBA{byte(v >> 16), byte(v >> 8), byte(v), 0xFF}
+	}
+	return p, nil
+}
+
+func (self Palette) String() string {
+	colours := make([]string, len(self))
+	for i, c := range self {
+		colours[i] = fmt.Sprintf("%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return strings.Join(colours, ",")
+}
+
+func (self Palette) colors() color.Palette {
+	p := make(color.Palette, len(self))
+	for i, c := range self {
+		p[i] = c
+	}
+	return p
+}
+
+// Image draws the display of c with each pixel a scale by scale square.
+func (self Palette) Image(c *chip8.Chip8, scale int) *image.Paletted {
+	if scale < 1 {
+		scale = 1
+	}
+	img := image.NewPaletted(image.Rect(0, 0, c.Width()*scale, c.Height()*scale), self.colors())
+	draw(img, c.Gfx, c.Width(), c.Height())
+	return img
+}
+
+// Fills img with a w by h display, scaling it to fit by a whole number or
+// dropping pixels if it is smaller.
+func draw(img *image.Paletted, gfx []byte, w, h int) {
+	if len(gfx) != w*h {
+		return
+	}
+	scale := img.Rect.Dx() / w
+	if scale < 1 {
+		for y := 0; y < img.Rect.Dy(); y++ {
+			for x := 0; x < img.Rect.Dx(); x++ {
+				img.Pix[y*img.Stride+x] = gfx[y*h/img.Rect.Dy()*w+x*w/img.Rect.Dx()] & chip8.AllPlanes
+			}
+		}
+		return
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := gfx[y*w+x] & chip8.AllPlanes
+			for dy := 0; dy < scale; dy++ {
+				row := img.Pix[(y*scale+dy)*img.Stride:]
+				for dx := 0; dx < scale; dx++ {
+					row[x*scale+dx] = v
+				}
+			}
+		}
+	}
+}
+
+// WritePNG writes a screenshot of c.
+func (self Palette) WritePNG(w io.Writer, c *chip8.Chip8, scale int) error {
+	return png.Encode(w, self.Image(c, scale))
+}
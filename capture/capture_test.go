@@ -0,0 +1,93 @@
+package capture_test
+
+import (
+	"bytes"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"github.com/bomer/chip8/capture"
+	"github.com/bomer/chip8/chip8"
+)
+
+func display() *chip8.Chip8 {
+	c := &chip8.Chip8{}
+	c.Init()
+	c.Gfx[0] = 1
+	c.Gfx[64+1] = 3
+	return c
+}
+
+func TestScreenshot(t *testing.T) {
+	var buf bytes.Buffer
+	if err := capture.DefaultPalette.WritePNG(&buf, display(), 3); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 64*3 || b.Dy() != 32*3 {
+		t.Fatalf("screenshot is %v", b)
+	}
+	for _, tc := range []struct {
+		x, y  int
+		index int
+	}{{0, 0, 1}, {2, 2, 1}, {3, 0, 0}, {3, 3, 3}, {5, 5, 3}, {6, 6, 0}} {
+		r, g, b, _ := img.At(tc.x, tc.y).RGBA()
+		want := capture.DefaultPalette[tc.index]
+		if byte(r>>8) != want.R || byte(g>>8) != want.G || byte(b>>8) != want.B {
+			t.Errorf("(%d, %d) is %v, want palette %d", tc.x, tc.y, img.At(tc.x, tc.y), tc.index)
+		}
+	}
+}
+
+func TestParsePalette(t *testing.T) {
+	p, err := capture.ParsePalette("000000, #FFFFFF,ff6600,662200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != capture.DefaultPalette || p.String() != "000000,ffffff,ff6600,662200" {
+		t.Errorf("got %v", p)
+	}
+	for _, bad := range []string{"", "000000,ffffff", "000000,ffffff,ff6600,66220"} {
+		if _, err := capture.ParsePalette(bad); err == nil {
+			t.Errorf("%q should not parse", bad)
+		}
+	}
+}
+
+func TestClip(t *testing.T) {
+	c := display()
+	clip := capture.NewClip(capture.DefaultPalette, 2)
+	for i := 0; i < 6; i++ {
+		if i == 3 {
+			c.Gfx[2] = 1
+		}
+		clip.Add(c)
+	}
+	c.Memory[0x200], c.Memory[0x201] = 0x00, 0xFF // Switch to high resolution
+	c.EmulateCycle()
+	clip.Add(c)
+
+	var buf bytes.Buffer
+	if err := clip.WriteGIF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clip.Len() != 7 || len(anim.Image) != 3 {
+		t.Fatalf("%d frames made %d images, want 7 and 3", clip.Len(), len(anim.Image))
+	}
+	// 3 frames each, then 1: 5, 5 and 1 hundredths rounding the total
+	if d := anim.Delay; d[0] != 5 || d[1] != 5 || d[2] != 1 {
+		t.Errorf("delays %v", d)
+	}
+	for _, img := range anim.Image {
+		if b := img.Bounds(); b.Dx() != 128 || b.Dy() != 64 {
+			t.Errorf("frame is %v, want the size of the first", b)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package capture
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"io"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+// Clip records frames for an animated GIF. Frames are kept as copies of Gfx
+// and only drawn when the GIF is written, and a frame the same as the one
+// before just makes that one last longer.
+type Clip struct {
+	Palette Palette
+	Scale   int
+	frames  []clipFrame
+	count   int
+}
+
+type clipFrame struct {
+	gfx           []byte
+	width, height int
+	count         int // 60Hz frames it is shown for
+}
+
+// NewClip starts an empty clip.
+func NewClip(palette Palette, scale int) *Clip {
+	if scale < 1 {
+		scale = 1
+	}
+	return &Clip{Palette: palette, Scale: scale}
+}
+
+// Add records the display of c as the next frame, normally after each
+// RunFrame.
+func (self *Clip) Add(c *chip8.Chip8) {
+	self.count++
+	if n := len(self.frames); n > 0 {
+		last := &self.frames[n-1]
+		if last.width == c.Width() && bytes.Equal(last.gfx, c.Gfx) {
+			last.count++
+			return
+		}
+	}
+	gfx := append([]byte(nil), c.Gfx...)
+	self.frames = append(self.frames, clipFrame{gfx: gfx, width: c.Width(), height: c.Height(), count: 1})
+}
+
+// Len is the number of frames added.
+func (self *Clip) Len() int {
+	return self.count
+}
+
+// WriteGIF writes the clip as a looping GIF. Its size is set by the first
+// frame, frames at the other resolution are scaled to fill it.
+func (self *Clip) WriteGIF(w io.Writer) error {
+	anim := &gif.GIF{}
+	if len(self.frames) == 0 {
+		return gif.EncodeAll(w, anim)
+	}
+	first := self.frames[0]
+	bounds := image.Rect(0, 0, first.width*self.Scale, first.height*self.Scale)
+	colors := self.Palette.colors()
+
+	// GIF delays are in hundredths of a second, so round the running total
+	// rather than each frame to keep the clip at 60Hz.
+	shown := 0
+	for _, f := range self.frames {
+		img := image.NewPaletted(bounds, colors)
+		draw(img, f.gfx, f.width, f.height)
+		start := shown * 100 / chip8.FrameRate
+		shown += f.count
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, shown*100/chip8.FrameRate-start)
+	}
+	return gif.EncodeAll(w, anim)
+}
@@ -2,10 +2,7 @@ package chip8
 
 //Struct for the Main Chip8 System
 import (
-	"fmt"
-	"golang.org/x/mobile/asset"
-	"io/ioutil"
-	"math/rand"
+	"crypto/sha1"
 )
 
 var Chip8_fontset = [80]byte{
@@ -31,12 +28,15 @@ type Chip8 struct {
 
 	/* System Memory Map
 	0x000-0x1FF - Chip 8 interpreter (contains font set in emu)
-	0x050-0x0A0 - Used for the built in 4x5 pixel font set (0-F)
+	0x000-0x04F - Used for the built in 4x5 pixel font set (0-F)
+	0x050-0x0EF - SUPER-CHIP 8x10 font set (0-F)
 	0x200-0xFFF - Program ROM and work RAM
+	0x1000-0xFFFF - XO-CHIP extended memory
 	*/
 
-	//Ram for the whole system. 4x1024 byes available
-	Memory [4096]byte
+	//Ram for the whole system. 64x1024 bytes so any 16 bit address is valid,
+	//CHIP-8 programs only use the first 4x1024
+	Memory [MemorySize]byte
 	// V is for the CPU Registers. v0,v1... v15. Last one is a carry flag
 	V [16]byte
 
@@ -47,385 +47,149 @@ type Chip8 struct {
 	Index  uint16 //Index Register
 	Sp     uint16 //Stack Pointer
 
-	//GPU Buffer
-	Gfx       [64 * 32]byte
+	//GPU Buffer, Width()*Height() pixels. Reallocated when the resolution changes.
+	Gfx       []byte
 	Draw_flag bool
+	HiRes     bool // SUPER-CHIP 128x64 mode
+	Halted    bool // Set by 00FD, the program has exited, or by a Fault
+	Plane     byte // XO-CHIP bitplanes drawn to, set by FN01
 
-	//Sound Variables
+	//SUPER-CHIP RPL user flags, saved and loaded by FX75/FX85
+	Rpl [16]byte
+
+	//Sound Variables, counted down at 60Hz by TickTimers
 	Delay_timer byte
 	Sound_timer byte
 
+	//Whether Sound_timer was running at the last tick, so the frame that
+	//just ran should be heard. Set by TickTimers before counting down.
+	Buzzing bool
+
+	//XO-CHIP audio, a 1 bit 128 sample pattern played while Sound_timer is
+	//non-zero at PatternRate(). Until F002 loads one the buzzer is a plain tone.
+	Pattern       [16]byte
+	PatternLoaded bool
+	Pitch         byte
+
 	//Stack
 	Stack [16]uint16
 
 	//Keyboard
 	Key [16]byte
 
-	//Games index/tracking
-	Games     []string
-	GameIndex int
+	//Which interpretation of the ambiguous instructions to follow
+	Quirks Quirks
+	vblank bool // Set by TickTimers, cleared when a waiting DXYN draws
+
+	//What to do about unknown opcodes. Kept by Init.
+	OnInvalid InvalidPolicy
+	//Why the machine halted, nil if it ran 00FD or is still running
+	Fault *OpcodeError
+	err   *OpcodeError // Reported by the current instruction
+
+	//Debugger watchpoints, nil when not debugging
+	OnMemory MemoryHook
+
+	//Instructions run, counted when not nil
+	Coverage *Coverage
+
+	//Called with each instruction before it runs when not nil, see package
+	//trace
+	Trace func(c *Chip8, in Instruction)
+
+	//Random numbers for CXNN, seeded from the clock if nil. Kept by Init.
+	Random RandomSource
+
+	//60Hz frames since Init, counted by TickTimers
+	Frame uint64
+
+	//Instructions run by each RunFrame, DefaultCyclesPerFrame if 0
+	CyclesPerFrame int
+	inFrame        int // Instructions Step has run since the timers last ticked
+
+	//The loaded ROM, for picking quirks and identifying saves
+	RomName string
+	RomHash [sha1.Size]byte
+	RomSize int
 }
 
-// Initialize registers and Memory once
+// Initialize registers and Memory once. Load a ROM afterwards with one of
+// the Load functions.
 func (self *Chip8) Init() {
-	self.Pc = 0x200 // Program counter starts at 0x200, the Space of Memory after the interpreter
-	self.Opcode = 0 // Reset current Opcode
-	self.Index = 0  // Reset index register
-	self.Sp = 0     // Reset stack pointer
+	self.Pc = RomStart // Program counter starts at 0x200, the Space of Memory after the interpreter
+	self.Opcode = 0    // Reset current Opcode
+	self.Index = 0     // Reset index register
+	self.Sp = 0        // Reset stack pointer
 
 	for x := 0; x < 16; x++ {
 		self.V[x] = 0
+		self.Stack[x] = 0
+		self.Key[x] = 0
 	}
-
-	//
-	for i := 0; i < 80; i++ {
-		self.Memory[i] = Chip8_fontset[i]
+	self.Delay_timer = 0
+	self.Sound_timer = 0
+	self.Buzzing = false
+	self.vblank = false
+	self.inFrame = 0
+	self.Frame = 0
+	self.Halted = false
+	self.Fault = nil
+	self.Plane = Plane1
+	self.Pattern = [16]byte{}
+	self.PatternLoaded = false
+	self.Pitch = DefaultPitch
+	for i := range self.Rpl {
+		self.Rpl[i] = 0
 	}
+	self.RomName = ""
+	self.RomHash = [sha1.Size]byte{}
+	self.RomSize = 0
 
-	// Clear display
-	for i := 0; i < 64*32; i++ {
-		self.Gfx[i] = 0
+	// Clear memory so a previous game doesn't leak into the next one
+	for i := range self.Memory {
+		self.Memory[i] = 0
 	}
-	self.Games = []string{"brix.c8", "tetris.c8", "ufo.c8", "invaders.c8"}
-	self.LoadGame("brix.c8")
+	copy(self.Memory[FontStart:], Chip8_fontset[:])
+	copy(self.Memory[BigFontStart:], Chip8_bigfontset[:])
 
+	// Clear display, back in low resolution
+	self.setHiRes(false)
+	self.Draw_flag = false
 }
 
-//Read file in curent dir into Memory
-func (self *Chip8) LoadGame(filename string) {
-	// rom, _ := ioutil.ReadFile(filename)
-	filename = self.Games[self.GameIndex]
-	fmt.Printf("Loading Game %s", filename)
-	f, _ := asset.Open(filename)
-	rom, _ := ioutil.ReadAll(f)
-	rom_length := len(rom)
-	if rom_length > 0 {
-		// fmt.Printf("Rom Length = %d\n", rom_length)
+//Tick to load next emulation cycle
+//
+// Returns an *OpcodeError when the instruction can't be run, whether or not
+// OnInvalid skipped it, and Fault again for as long as the machine is halted.
+func (self *Chip8) EmulateCycle() error {
+	if self.Halted {
+		return self.faultError()
 	}
 
-	//If room to store ROM in RAM, start at 512 or 0x200
-	if (4096 - 512) > rom_length {
-		for i := 0; i < rom_length; i++ {
-			self.Memory[i+512] = rom[i]
-		}
+	// Fetch and decode the Opcode, then run it
+	in := Decode(self.Memory[self.Pc:])
+	if !self.Quirks.XOChip {
+		in.Kind = in.Kind.classic()
 	}
-}
-
-//Tick to load next emulation cycle
-func (self *Chip8) EmulateCycle() {
-	// Fetch Opcode
-	b1 := uint16(self.Memory[self.Pc])
-	b2 := uint16(self.Memory[self.Pc+1])
-
-	//Bitwise, add padding to end of first byte and append second byte to end
-	self.Opcode = (b1 << 8) | b2
-	x := (self.Opcode & 0x0F00) >> 8
-	y := self.Opcode & 0x00F0 >> 4
-
-	// 0x00E0 and 0x000E We have to do first because Golang seems to truncate 0x0000 into 0x00
-	switch self.Opcode {
-	case 0xE0: // 0x00E0: Clears the screen
-		for i := 0; i < 64*32; i++ {
-			self.Gfx[i] = 0
-		}
-		self.Draw_flag = true
-		self.Pc += 2
-		break
-
-	case 0xEE: // 0x00EE: Returns from subroutine
-		self.Sp--                     // 16 levels of stack, decrease stack pointer to prevent overwrite
-		self.Pc = self.Stack[self.Sp] // Put the stored return address from the stack back into the program counter
-		self.Pc += 2                  // Don't forget to increase the program counter!
-		break
-
+	self.Opcode = in.Opcode
+	if self.Coverage != nil {
+		self.Coverage.Counts[in.Kind]++
 	}
-
-	switch self.Opcode & 0xF000 {
-	//1 to 7, jump, call and skip instructions
-	case 0x1000: // 0x1NNN: Jumps to address NNN
-		self.Pc = self.Opcode & 0x0FFF
-		break
-	case 0x2000: // 0x2NNN: Calls subroutine at NNN.
-		self.Stack[self.Sp] = self.Pc  // Store current address in stack
-		self.Sp++                      // Increment stack pointer
-		self.Pc = self.Opcode & 0x0FFF // Set the program counter to the address at NNN
-		break
-	case 0x3000: // 0x3XNN: Skips the next instruction if VX equals NN
-		if uint16(self.V[(self.Opcode&0x0F00)>>8]) == self.Opcode&0x00FF {
-			self.Pc += 4
-		} else {
-			self.Pc += 2
-		}
-		break
-	case 0x4000: // 0x4XNN: Skips the next instruction if VX doesn't equal NN.
-		if uint16(self.V[(self.Opcode&0x0F00)>>8]) != self.Opcode&0x00FF {
-			self.Pc += 4
-		} else {
-			self.Pc += 2
-		}
-		break
-	case 0x5000: // 0x5XY0: Skips the next instruction if VX equals VY.
-		x := (self.Opcode & 0x0F00) >> 8
-		y := (self.Opcode & 0x00F0) >> 4
-		// fmt.Printf("x = %02x and y= %02x", x, y)
-		// fmt.Printf("V0 = %02x v1= %02x", self.V[x], self.V[y])
-
-		if uint16(self.V[x]) == uint16(self.V[y]) {
-			self.Pc += 4
-		} else {
-			self.Pc += 2
-		}
-		break
-	case 0x6000: //6XNN	Sets VX to NN.
-		NN := byte(self.Opcode & 0x00FF)
-		self.V[x] = NN
-		self.Pc += 2
-		break
-	case 0x7000: //0x7XNN	Adds NN to VX.
-		x := (self.Opcode & 0xF00) >> 8
-		NN := byte(self.Opcode & 0x00FF)
-		self.V[x] += NN
-		self.Pc += 2
-		break
-
-		//0X8000 - 8 CASES
-		/*
-			8XY0	Sets VX to the value of VY.
-			8XY1	Sets VX to VX or VY.
-			8XY2	Sets VX to VX and VY.
-			8XY3	Sets VX to VX xor VY.
-			8XY4	Adds VY to VX. VF is set to 1 when there's a carry, and to 0 when there isn't.
-		*/
-
-	case 0x8000:
-		switch self.Opcode & 0x000F { //	8XY0 Sets VX to the value of VY.
-		case 0x0000: // 0x8XY0: Sets VX to the value of VY
-			self.V[x] = self.V[y]
-			self.Pc += 2
-			break
-		case 0x0001: // 0x8XY0: Sets VX to the value of VY
-			self.V[x] |= self.V[y]
-			self.Pc += 2
-			break
-
-		case 0x0002: // 0x8XY0: Sets VX to VX and VY.
-			self.V[x] &= self.V[y]
-			self.Pc += 2
-			break
-
-		case 0x0003: // 0x8XY3:	Sets VX to VX xor VY.
-			self.V[x] ^= self.V[y]
-			self.Pc += 2
-			break
-		case 0x0004: // 0x8XY4: Adds VY to VX. VF is set to 1 when there's a carry, and to 0 when there isn't.
-			if self.V[y] > 0xFF-self.V[x] {
-				self.V[0xF] = 1
-			} else {
-				self.V[0xF] = 0
-			}
-			self.V[x] += self.V[y]
-			self.Pc += 2
-			break
-
-		case 0x0005: // 0x8XY5: VY is subtracted from VX. VF is set to 0 when there's a borrow, and 1 when there isn't
-			y := self.Opcode & 0x00F0 >> 4
-			if self.V[y] > self.V[x] {
-				self.V[0xF] = 0 //Borrow
-			} else {
-				self.V[0xF] = 1
-			}
-			self.V[x] -= self.V[y]
-			self.Pc += 2
-			break
-
-		case 0x0006: // 8XY6 Shifts VX right by one. VF set to the value of the least significant bit of VX before the shift
-			// y := self.Opcode & 0x00F0 >> 4
-			// fmt.Printf("Bit shifting CPU register %d", x)
-			self.V[0xF] = self.V[x] & 0x1
-			self.V[x] >>= 1
-			self.Pc += 2
-			break
-
-		case 0x0007: //8XY7: Sets VX to VY minus VX. VF is set to 0 when there's a borrow, and 1 when there isn't.
-			y := self.Opcode & 0x00F0 >> 4
-			if self.V[x] > self.V[y] {
-				self.V[0xF] = 0 //Borrow
-			} else {
-				self.V[0xF] = 1
-			}
-			self.V[x] = self.V[y] - self.V[x]
-			self.Pc += 2
-			break
-		case 0x000E: //0x8XYE: Shifts VX left by one. VF is set to the value of the most significant bit of VX before the shift
-			//Because we're shifting left we need the left hand bit.
-			self.V[0xF] = self.V[x] >> 7
-			self.V[x] <<= 1
-			self.Pc += 2
-			break
-		}
-	case 0x9000: //9XY0	Skips the next instruction if VX doesn't equal VY. Same as 0x5XY0 but !
-		y := self.Opcode & 0x00F0 >> 4
-		if uint16(self.V[x]) != uint16(self.V[y]) {
-			self.Pc += 4
-		} else {
-			self.Pc += 2
-		}
-		break
-	case 0xA000: //ANNN	Sets I to the address NNN.
-		self.Index = self.Opcode & 0x0FFF
-		self.Pc += 2
-		break
-	case 0xB000: //BNNN	Jumps to the address NNN plus V0.
-		self.Pc = self.Opcode&0x0FFF + uint16(self.V[0])
-		break
-	case 0xC000: //Sets VX to the result of a bitwise and operation on a random number and NN
-		self.Pc += 2
-		self.V[x] = byte(uint16(rand.Intn(0xFF)) & (self.Opcode & 0x00FF))
-	case 0xD000: // DXYN: Draws a sprite at coordinate (VX, VY) that has a width of 8 pixels and a height of N pixels.
-		// Each row of 8 pixels is read as bit-coded starting from memory location I;
-		// I value doesn't change after the execution of this instruction.
-		// VF is set to 1 if any screen pixels are flipped from set to unset when the sprite is drawn,
-		// and to 0 if that doesn't happen
-		x := uint16(self.V[x])
-		y := uint16(self.V[y])
-		height := uint16(self.Opcode & 0x000F)
-
-		// fmt.Printf("Drawing - %02x, x=%d y=%d", self.Opcode, x, y)
-		var pixel byte
-		var yline uint16
-		var xline uint16
-		self.V[0xF] = 0
-		//For each scan line
-		for yline = 0; yline < height; yline++ {
-			pixel = self.Memory[self.Index+uint16(yline)]
-			//For each pixel in the scan line
-			for xline = 0; xline < 8; xline++ {
-				//if there is a pixel value
-				if pixel&(0x80>>xline) != 0 {
-					//If the pixel value is already 1, then we need to store V[0xf] as 1 to indicate
-					if (x + xline + ((y + yline) * 64)) < 2048 {
-						if self.Gfx[(x+xline+((y+yline)*64))] == 1 {
-							self.V[0xF] = 1
-						}
-						self.Gfx[x+xline+((y+yline)*64)] ^= 1
-					}
-
-				}
-
-			}
-		}
-
-		// fmt.Printf(format, ...)
-		self.Draw_flag = true
-		self.Pc += 2
-
-		break
-	case 0xE000:
-		switch self.Opcode & 0x00FF {
-		case 0x009E: // EX9E: Skips the next instruction if the key stored in VX is pressed
-			if self.Key[self.V[x]] != 0 {
-				self.Pc += 4
-			} else {
-				self.Pc += 2
-			}
-			break
-
-		case 0x00A1: // EX9E: Skips the next instruction if the key stored in VX is pressed
-			if self.Key[self.V[x]] == 0 {
-				self.Pc += 4
-			} else {
-				self.Pc += 2
-			}
-			break
-		}
-		break
-	//Final sett woop! fix:fe33
-	case 0xF000:
-		switch self.Opcode & 0x00FF {
-		case 0x0007: // FX07: Sets VX to the value of the delay timer
-			self.V[x] = self.Delay_timer
-			self.Pc += 2
-			break
-		case 0x000A: // FX0A: A key press is awaited, and then stored in VX.
-			keyPressed := false
-			for i := 0; i < 16; i++ {
-				if self.Key[i] != 0 {
-					self.V[x] = byte(i)
-					keyPressed = true
-				}
-			}
-			if keyPressed {
-				self.Pc += 2
-			}
-			break
-		case 0x0015: //	FX15: Sets the delay timer to VX.
-			self.Delay_timer = self.V[x]
-			self.Pc += 2
-			break
-		case 0x0018: //	FX18: Sets the delay timer to VX.
-			self.Sound_timer = byte(self.V[x])
-			self.Pc += 2
-			break
-		case 0x001E: // FX1E: Adds VX to I
-			// VF is set to 1 when range overflow (I+VX>0xFFF), and 0 when there isn't.
-			if self.Index+uint16(self.V[x]) > 0xFFF { // VF is set to 1 when range overflow (I+VX>0xFFF), and 0 when there isn't.
-				self.V[0xF] = 1
-			} else {
-				self.V[0xF] = 0
-			}
-			self.Index += uint16(self.V[x])
-			self.Pc += 2
-			break
-		case 0x0029: // FX29: Sets I to the location of the sprite for the character in VX. Characters 0-F (in hexadecimal) are represented by a 4x5 font
-			self.Index = uint16(self.V[x]) * 0x5
-			self.Pc += 2
-			break
-		case 0x0033: // FX33: Stores the Binary-coded decimal representation of VX at the addresses I, I plus 1, and I plus 2
-			self.Memory[self.Index] = self.V[x] / 100
-			self.Memory[self.Index+1] = (self.V[x] / 10) % 10
-			self.Memory[self.Index+2] = (self.V[x] % 100) % 10
-			self.Pc += 2
-			break
-		case 0x055: // FX55	Stores V0 to VX (including VX) in memory starting at address I.[4]
-			for i := 0; i < int(x); i++ {
-				self.Memory[self.Index+uint16(i)] = self.V[i]
-			}
-			self.Index += x + 1
-			self.Pc += 2
-			break
-		case 0x065: // FX55	Fills V0 to VX (including VX) with values from memory starting at address I.[4]
-			for i := 0; i < int(x); i++ {
-				self.V[i] = self.Memory[int(self.Index)+i]
-			}
-			self.Index += x + 1
-			self.Pc += 2
-			break
-		default:
-			fmt.Printf("Unknown opcode [0xF000]: 0x%X\n", self.Opcode)
-			break
-		}
-
-	default:
-		if self.Opcode != 0xE0 && self.Opcode != 0xEE {
-			fmt.Printf("Error Processing Op Code %02x\n", self.Opcode)
-			// fmt.Println("Unknown Opcode!")
-
-		}
-		break
-
+	if self.Trace != nil {
+		self.Trace(self, in)
 	}
-
-	// Update timers
-	if self.Delay_timer > 0 {
-		self.Delay_timer--
-		// fmt.Printf("delay timer -- . now %d", self.Delay_timer)
+	self.err = nil
+	executors[in.Kind](self, in)
+	if self.err != nil {
+		return self.err
 	}
+	return nil
+}
 
-	if self.Sound_timer > 0 {
-		if self.Sound_timer == 1 {
-			// fmt.Printf("BEEP!\n")
-
-		}
-		self.Sound_timer--
+// Fault as an error, nil rather than a nil *OpcodeError when there is none.
+func (self *Chip8) faultError() error {
+	if self.Fault == nil {
+		return nil
 	}
+	return self.Fault
 }
@@ -10,7 +10,9 @@ var myChip8 chip8.Chip8
 
 func Prep() {
 	myChip8.Init()
-	myChip8.LoadGame("../pong.c8")
+	if err := myChip8.LoadGame("../assets/pong.c8"); err != nil {
+		panic(err)
+	}
 }
 
 func TestInit(t *testing.T) {
@@ -533,6 +535,31 @@ func TestOpCode8XYE(t *testing.T) {
 
 }
 
+// 8XY4, 8XY5 and 8XY7 write VF after the result, so it keeps the flag when
+// it is VX and is read as an operand before being overwritten.
+func TestOpCode8XYNIntoVF(t *testing.T) {
+	for _, tc := range []struct {
+		op     byte
+		vf, v1 byte
+		want   byte
+	}{
+		{0x14, 0xF0, 0x20, 1}, // Carry
+		{0x15, 0x10, 0x30, 0}, // Borrow
+		{0x17, 0x30, 0x10, 0}, // Borrow
+	} {
+		set := func(c *chip8.Chip8) { c.V[0xF] = tc.vf; c.V[1] = tc.v1 }
+		if c := runQuirk(chip8.Quirks{}, 0x8F, tc.op, set); c.V[0xF] != tc.want {
+			t.Errorf("8F%02X: VF=%X, want %X", tc.op, c.V[0xF], tc.want)
+		}
+	}
+
+	// 80F4 adds VF before setting the carry
+	set := func(c *chip8.Chip8) { c.V[0] = 0xF0; c.V[0xF] = 0x20 }
+	if c := runQuirk(chip8.Quirks{}, 0x80, 0xF4, set); c.V[0] != 0x10 || c.V[0xF] != 1 {
+		t.Errorf("80F4: V0=%02X VF=%X", c.V[0], c.V[0xF])
+	}
+}
+
 // 0x5XY0: Skips the next instruction if VX equals VY.
 func TestOpCode9XY0(t *testing.T) {
 	Prep()
@@ -605,13 +632,20 @@ func TestOpCodeBNNN(t *testing.T) {
 //CXNN	Sets VX to the result of a bitwise and operation on a random number and NN.
 func TestOpCodeCXNN(t *testing.T) {
 	Prep()
+	myChip8.Random = chip8.NewScriptedSource(0xFF, 0x5A)
+	defer func() { myChip8.Random = nil }()
 	myChip8.Memory[512] = 0xC0
 	myChip8.Memory[513] = 0x11
-	myChip8.V[0] = 0
+	myChip8.Memory[514] = 0xC1
+	myChip8.Memory[515] = 0x33
+	myChip8.EmulateCycle()
 	myChip8.EmulateCycle()
 
-	if myChip8.V[0] == 0 {
-		t.Error("Random number error, number not changed!!")
+	if myChip8.V[0] != 0x11 || myChip8.V[1] != 0x12 {
+		t.Errorf("Random number error, got %02X %02X", myChip8.V[0], myChip8.V[1])
+	}
+	if myChip8.Pc != 516 {
+		t.Error("Did not Update the PC correctly")
 	}
 }
 
@@ -746,31 +780,39 @@ func TestOpCodeFX0A(t *testing.T) {
 // FX15	Sets the delay timer to VX.
 func TestOpCodeFX15(t *testing.T) {
 	Prep()
-	// Success Case, 54 becomes be because timer decreases at ed of CPU cycle
+	// Success Case, timers only count down on the 60Hz tick, not every CPU cycle
 	myChip8.Pc = 512
 	myChip8.V[0] = 54
 	myChip8.Memory[512] = 0xF0
 	myChip8.Memory[513] = 0x15
 	myChip8.EmulateCycle()
 	// fmt.Printf("dtimer=%d", myChip8.Delay_timer)
-	if myChip8.Delay_timer != 53 {
+	if myChip8.Delay_timer != 54 {
 		t.Error("Did not Update delay timer correctly")
 	}
+	myChip8.TickTimers()
+	if myChip8.Delay_timer != 53 {
+		t.Error("Did not count down delay timer correctly")
+	}
 
 }
 
 // FX18	Sets the sound timer to VX.
 func TestOpCodeFX18(t *testing.T) {
 	Prep()
-	// Success Case, 54 becomes be because timer decreases at ed of CPU cycle
+	// Success Case, timers only count down on the 60Hz tick, not every CPU cycle
 	myChip8.Pc = 512
 	myChip8.V[0] = 54
 	myChip8.Memory[512] = 0xF0
 	myChip8.Memory[513] = 0x18
 	myChip8.EmulateCycle()
 	// fmt.Printf("dtimer=%d", myChip8.Delay_timer)
+	if myChip8.Sound_timer != 54 {
+		t.Error("Did not Update sound timer correctly")
+	}
+	myChip8.TickTimers()
 	if myChip8.Sound_timer != 53 {
-		t.Error("Did not Update delay timer correctly")
+		t.Error("Did not count down sound timer correctly")
 	}
 }
 
@@ -849,15 +891,29 @@ func TestOpCodeFX55(t *testing.T) {
 	myChip8.Index = 1024
 	myChip8.V[0] = 5
 	myChip8.V[1] = 6
+	myChip8.V[2] = 7
+	myChip8.Quirks.LoadStoreIncrementsI = true
 	myChip8.Memory[512] = 0xF2
 	myChip8.Memory[513] = 0x55
 	myChip8.EmulateCycle()
+	myChip8.Quirks.LoadStoreIncrementsI = false
 	if myChip8.Memory[myChip8.Index-3] != 5 { //50*5
 		t.Error("Index error")
 	}
 	if myChip8.Memory[myChip8.Index-2] != 6 { //50*5
 		t.Error("Index error")
 	}
+	if myChip8.Memory[myChip8.Index-1] != 7 { // VX is included
+		t.Error("Index error")
+	}
+
+	// Without the quirk I stays where it was
+	myChip8.Pc = 512
+	myChip8.Index = 1024
+	myChip8.EmulateCycle()
+	if myChip8.Index != 1024 {
+		t.Error("Index should not move")
+	}
 }
 
 //FX65	Fills V0 to VX (including VX) with values from memory starting at address I.[4]
@@ -866,8 +922,8 @@ func TestOpCodeFX65(t *testing.T) {
 	// Success Case, No overflow
 	myChip8.Pc = 512
 	myChip8.Index = 1024
-	myChip8.Memory[1023] = 5
-	myChip8.Memory[1225] = 6
+	myChip8.Memory[1024] = 5
+	myChip8.Memory[1025] = 6
 	myChip8.Memory[512] = 0xF3
 	myChip8.Memory[513] = 0x65
 	myChip8.EmulateCycle()
@@ -0,0 +1,159 @@
+package chip8
+
+// SUPER-CHIP 8x10 font for FX30, stored in Memory straight after the small
+// font. SCHIP 1.1 only had 0-9, A-F are the ones Octo uses.
+var Chip8_bigfontset = [160]byte{
+	0xFF, 0xFF, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, //0
+	0x18, 0x78, 0x78, 0x18, 0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF, //1
+	0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, //2
+	0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, //3
+	0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0x03, 0x03, //4
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, //5
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, //6
+	0xFF, 0xFF, 0x03, 0x03, 0x06, 0x0C, 0x18, 0x18, 0x18, 0x18, //7
+	0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, //8
+	0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, //9
+	0x7E, 0xFF, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xC3, //A
+	0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, //B
+	0x3C, 0xFF, 0xC3, 0xC0, 0xC0, 0xC0, 0xC0, 0xC3, 0xFF, 0x3C, //C
+	0xFC, 0xFE, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFE, 0xFC, //D
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, //E
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xC0, 0xC0, //F
+}
+
+// Where the fonts live in Memory
+const (
+	FontStart    = 0x000
+	BigFontStart = 0x050
+)
+
+// Display sizes in low (CHIP-8) and high (SUPER-CHIP) resolution
+const (
+	LoResWidth  = 64
+	LoResHeight = 32
+	HiResWidth  = 128
+	HiResHeight = 64
+)
+
+// Width of the display in the current resolution
+func (self *Chip8) Width() int {
+	if self.HiRes {
+		return HiResWidth
+	}
+	return LoResWidth
+}
+
+// Height of the display in the current resolution
+func (self *Chip8) Height() int {
+	if self.HiRes {
+		return HiResHeight
+	}
+	return LoResHeight
+}
+
+// Switch resolution, which reallocates and clears Gfx.
+func (self *Chip8) setHiRes(hires bool) {
+	self.HiRes = hires
+	self.Gfx = make([]byte, self.Width()*self.Height())
+	self.Draw_flag = true
+}
+
+// Clears the selected bitplanes.
+func (self *Chip8) clearScreen() {
+	for i := range self.Gfx {
+		self.Gfx[i] &^= self.Plane
+	}
+	self.Draw_flag = true
+}
+
+// Move the selected bitplanes by dx, dy pixels, filling the uncovered area
+// with blank pixels.
+func (self *Chip8) scroll(dx, dy int) {
+	w, h := self.Width(), self.Height()
+	scrolled := make([]byte, len(self.Gfx))
+	for y := 0; y < h; y++ {
+		sy := y - dy
+		if sy < 0 || sy >= h {
+			continue
+		}
+		for x := 0; x < w; x++ {
+			sx := x - dx
+			if sx < 0 || sx >= w {
+				continue
+			}
+			scrolled[y*w+x] = self.Gfx[sy*w+sx] & self.Plane
+		}
+	}
+	for i := range self.Gfx {
+		self.Gfx[i] = self.Gfx[i]&^self.Plane | scrolled[i]
+	}
+	self.Draw_flag = true
+}
+
+// Draws a sprite from Memory[Index] at (vx, vy), XORing it onto the display.
+// Sprites are 8 pixels wide and height rows tall, or 16x16 with two bytes
+// per row when height is 0 (SUPER-CHIP DXY0). With both XO-CHIP planes
+// selected the plane 2 sprite follows the plane 1 sprite in memory. VF is
+// set to 1 if any lit pixel was turned off.
+func (self *Chip8) drawSprite(vx, vy byte, height uint16) {
+	width := uint16(8)
+	if height == 0 {
+		width, height = 16, 16
+	}
+
+	self.V[0xF] = 0
+	addr := self.Index
+	for _, plane := range []byte{Plane1, Plane2} {
+		if self.Plane&plane == 0 {
+			continue
+		}
+		self.drawPlane(plane, addr, vx, vy, width, height)
+		addr += width / 8 * height
+	}
+	self.Draw_flag = true
+}
+
+func (self *Chip8) drawPlane(plane byte, addr uint16, vx, vy byte, width, height uint16) {
+	w, h := uint16(self.Width()), uint16(self.Height())
+
+	// The start position wraps, the rest of the sprite is clipped or wrapped
+	x := uint16(vx) % w
+	y := uint16(vy) % h
+
+	var yline uint16
+	var xline uint16
+	//For each scan line
+	for yline = 0; yline < height; yline++ {
+		py := y + yline
+		if py >= h {
+			if !self.Quirks.SpriteWrap {
+				break
+			}
+			py %= h
+		}
+		var row uint16
+		if width == 16 {
+			row = uint16(self.load(addr+yline*2))<<8 | uint16(self.load(addr+yline*2+1))
+		} else {
+			row = uint16(self.load(addr+yline)) << 8
+		}
+		//For each pixel in the scan line
+		for xline = 0; xline < width; xline++ {
+			px := x + xline
+			if px >= w {
+				if !self.Quirks.SpriteWrap {
+					break
+				}
+				px %= w
+			}
+			//if there is a pixel value
+			if row&(0x8000>>xline) != 0 {
+				//If the pixel value is already 1, then we need to store V[0xf] as 1 to indicate
+				if self.Gfx[px+(py*w)]&plane != 0 {
+					self.V[0xF] = 1
+				}
+				self.Gfx[px+(py*w)] ^= plane
+			}
+		}
+	}
+}
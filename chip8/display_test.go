@@ -0,0 +1,109 @@
+package chip8_test
+
+import (
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+// Loads a program at 0x200 on a fresh machine.
+func schipProgram(program ...byte) *chip8.Chip8 {
+	c := &chip8.Chip8{}
+	c.Init()
+	c.LoadBytes("test", program)
+	return c
+}
+
+func TestOpCode00FF00FE(t *testing.T) {
+	c := schipProgram(0x00, 0xFF, 0x00, 0xFE)
+	if c.Width() != 64 || c.Height() != 32 || len(c.Gfx) != 64*32 {
+		t.Fatal("should start in low resolution")
+	}
+	c.EmulateCycle()
+	if !c.HiRes || c.Width() != 128 || c.Height() != 64 || len(c.Gfx) != 128*64 {
+		t.Error("00FF should switch to 128x64")
+	}
+	c.EmulateCycle()
+	if c.HiRes || len(c.Gfx) != 64*32 {
+		t.Error("00FE should switch back to 64x32")
+	}
+}
+
+func TestOpCodeDXY0(t *testing.T) {
+	// Hires, I = 0x300, draw a 16x16 sprite at (0, 0)
+	c := schipProgram(0x00, 0xFF, 0xA3, 0x00, 0xD0, 0x00)
+	c.Memory[0x300] = 0x80 // Row 0, leftmost pixel
+	c.Memory[0x301] = 0x01 // Row 0, pixel 15
+	c.Memory[0x31F] = 0x01 // Row 15, pixel 15
+	for i := 0; i < 3; i++ {
+		c.EmulateCycle()
+	}
+	w := c.Width()
+	if c.Gfx[0] != 1 || c.Gfx[15] != 1 || c.Gfx[15*w+15] != 1 {
+		t.Error("16x16 sprite not drawn")
+	}
+	if c.Gfx[16] != 0 || c.Gfx[16*w] != 0 {
+		t.Error("16x16 sprite drew too much")
+	}
+	if c.V[0xF] != 0 {
+		t.Error("No collision expected")
+	}
+}
+
+func TestOpCodeScroll(t *testing.T) {
+	// 00C2 scroll down 2, 00FB right 4, 00FC left 4
+	c := schipProgram(0x00, 0xC2, 0x00, 0xFB, 0x00, 0xFC)
+	c.Gfx[0] = 1
+	c.Gfx[63] = 1
+
+	c.EmulateCycle()
+	if c.Gfx[0] != 0 || c.Gfx[2*64] != 1 || c.Gfx[2*64+63] != 1 {
+		t.Error("00C2 should move pixels down 2 rows")
+	}
+	c.EmulateCycle()
+	if c.Gfx[2*64+4] != 1 || c.Gfx[2*64] != 0 {
+		t.Error("00FB should move pixels right 4")
+	}
+	if c.Gfx[2*64+63] != 0 {
+		t.Error("00FB should drop pixels scrolled off the edge")
+	}
+	c.EmulateCycle()
+	if c.Gfx[2*64] != 1 || c.Gfx[2*64+4] != 0 {
+		t.Error("00FC should move pixels left 4")
+	}
+}
+
+func TestOpCodeFX30(t *testing.T) {
+	c := schipProgram(0xF3, 0x30)
+	c.V[3] = 9
+	c.EmulateCycle()
+	if c.Index != chip8.BigFontStart+90 {
+		t.Errorf("I should point at the big 9, got %03X", c.Index)
+	}
+	if c.Memory[c.Index] != chip8.Chip8_bigfontset[90] {
+		t.Error("big font not loaded")
+	}
+}
+
+func TestOpCodeFX75FX85(t *testing.T) {
+	c := schipProgram(0xF2, 0x75, 0x60, 0x00, 0x61, 0x00, 0x62, 0x00, 0xF2, 0x85)
+	c.V[0], c.V[1], c.V[2], c.V[3] = 1, 2, 3, 4
+	for i := 0; i < 5; i++ {
+		c.EmulateCycle()
+	}
+	if c.Rpl[0] != 1 || c.Rpl[2] != 3 || c.Rpl[3] != 0 {
+		t.Errorf("RPL flags wrong: %v", c.Rpl[:4])
+	}
+	if c.V[0] != 1 || c.V[1] != 2 || c.V[2] != 3 {
+		t.Errorf("registers not restored: %v", c.V[:3])
+	}
+}
+
+func TestOpCode00FD(t *testing.T) {
+	c := schipProgram(0x00, 0xFD)
+	c.EmulateCycle()
+	c.EmulateCycle()
+	if !c.Halted || c.Pc != 0x200 {
+		t.Error("00FD should halt the machine")
+	}
+}
@@ -0,0 +1,41 @@
+package chip8
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// DumpGfx writes the display as text, one line per scan line, '.' for an
+// unlit pixel, '#' for plane 1, '+' for plane 2 and '@' for both.
+func (self *Chip8) DumpGfx(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	width, height := self.Width(), self.Height()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			bw.WriteByte(".#+@"[self.Gfx[(y*width)+x]&AllPlanes])
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// DumpRegisters writes the CPU registers, timers and stack in a human readable form.
+func (self *Chip8) DumpRegisters(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "PC=%04X OP=%04X I=%04X SP=%X DT=%02X ST=%02X\n",
+		self.Pc, self.Opcode, self.Index, self.Sp, self.Delay_timer, self.Sound_timer)
+	for i := 0; i < 16; i++ {
+		if i > 0 {
+			bw.WriteByte(' ')
+		}
+		fmt.Fprintf(bw, "V%X=%02X", i, self.V[i])
+	}
+	bw.WriteByte('\n')
+	bw.WriteString("Stack:")
+	for i := uint16(0); i < self.Sp && i < 16; i++ {
+		fmt.Fprintf(bw, " %04X", self.Stack[i])
+	}
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
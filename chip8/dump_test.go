@@ -0,0 +1,46 @@
+package chip8_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+func TestDumpGfx(t *testing.T) {
+	var c chip8.Chip8
+	c.Init()
+	c.Gfx[0] = 1
+	c.Gfx[64+63] = 1
+
+	var buf bytes.Buffer
+	if err := c.DumpGfx(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 32 {
+		t.Fatalf("expected 32 lines, got %d", len(lines))
+	}
+	if lines[0] != "#"+strings.Repeat(".", 63) || lines[1] != strings.Repeat(".", 63)+"#" {
+		t.Errorf("unexpected pixels:\n%s\n%s", lines[0], lines[1])
+	}
+}
+
+func TestDumpRegisters(t *testing.T) {
+	var c chip8.Chip8
+	c.Init()
+	c.V[0xA] = 0x42
+	c.Stack[0] = 0x2A4
+	c.Sp = 1
+
+	var buf bytes.Buffer
+	if err := c.DumpRegisters(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"PC=0200", "VA=42", "Stack: 02A4"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, buf.String())
+		}
+	}
+}
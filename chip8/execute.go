@@ -0,0 +1,489 @@
+package chip8
+
+// What each kind of instruction does, looked up by EmulateCycle. Every entry
+// leaves Pc at the instruction to run next.
+var executors = [NumKinds]func(self *Chip8, in Instruction){
+	OpInvalid: (*Chip8).opInvalid,
+
+	OpScrollDown:  (*Chip8).opScrollDown,
+	OpScrollUp:    (*Chip8).opScrollUp,
+	OpClear:       (*Chip8).opClear,
+	OpReturn:      (*Chip8).opReturn,
+	OpScrollRight: (*Chip8).opScrollRight,
+	OpScrollLeft:  (*Chip8).opScrollLeft,
+	OpExit:        (*Chip8).opExit,
+	OpLowRes:      (*Chip8).opLowRes,
+	OpHighRes:     (*Chip8).opHighRes,
+
+	OpJump:            (*Chip8).opJump,
+	OpCall:            (*Chip8).opCall,
+	OpSkipEqual:       (*Chip8).opSkipEqual,
+	OpSkipNotEqual:    (*Chip8).opSkipNotEqual,
+	OpSkipEqualReg:    (*Chip8).opSkipEqualReg,
+	OpSaveRange:       (*Chip8).opSaveRange,
+	OpLoadRange:       (*Chip8).opLoadRange,
+	OpSet:             (*Chip8).opSet,
+	OpAdd:             (*Chip8).opAdd,
+	OpMove:            (*Chip8).opMove,
+	OpOr:              (*Chip8).opOr,
+	OpAnd:             (*Chip8).opAnd,
+	OpXor:             (*Chip8).opXor,
+	OpAddReg:          (*Chip8).opAddReg,
+	OpSub:             (*Chip8).opSub,
+	OpShiftRight:      (*Chip8).opShiftRight,
+	OpSubReverse:      (*Chip8).opSubReverse,
+	OpShiftLeft:       (*Chip8).opShiftLeft,
+	OpSkipNotEqualReg: (*Chip8).opSkipNotEqualReg,
+	OpSetIndex:        (*Chip8).opSetIndex,
+	OpJumpOffset:      (*Chip8).opJumpOffset,
+	OpRandom:          (*Chip8).opRandom,
+	OpDraw:            (*Chip8).opDraw,
+	OpSkipKey:         (*Chip8).opSkipKey,
+	OpSkipNotKey:      (*Chip8).opSkipNotKey,
+
+	OpSetIndexLong: (*Chip8).opSetIndexLong,
+	OpPlane:        (*Chip8).opPlane,
+	OpAudio:        (*Chip8).opAudio,
+	OpGetDelay:     (*Chip8).opGetDelay,
+	OpWaitKey:      (*Chip8).opWaitKey,
+	OpSetDelay:     (*Chip8).opSetDelay,
+	OpSetSound:     (*Chip8).opSetSound,
+	OpAddIndex:     (*Chip8).opAddIndex,
+	OpFont:         (*Chip8).opFont,
+	OpBigFont:      (*Chip8).opBigFont,
+	OpBCD:          (*Chip8).opBCD,
+	OpPitch:        (*Chip8).opPitch,
+	OpStore:        (*Chip8).opStore,
+	OpLoad:         (*Chip8).opLoad,
+	OpSaveFlags:    (*Chip8).opSaveFlags,
+	OpLoadFlags:    (*Chip8).opLoadFlags,
+}
+
+// Skips the next instruction if cond holds, otherwise moves on to it.
+func (self *Chip8) skipIf(cond bool) {
+	if cond {
+		self.skip()
+	} else {
+		self.Pc += 2
+	}
+}
+
+// Unknown opcodes, and 0NNN machine code routines no one emulates. What
+// happens next is up to OnInvalid.
+func (self *Chip8) opInvalid(in Instruction) {
+	err := ErrUnknownOpcode
+	// 0000 is empty memory rather than a call, so a runaway program still
+	// halts when 0NNN is ignored
+	sys := in.Opcode&0xF000 == 0 && in.Opcode != 0
+	if sys {
+		err = ErrMachineCode
+	}
+	switch {
+	case sys && self.OnInvalid == IgnoreSys:
+		self.Pc += 2
+	case self.OnInvalid == SkipInvalid:
+		self.fault(in, err, false)
+		self.Pc += 2
+	default:
+		self.fault(in, err, true)
+	}
+}
+
+// 00CN: SUPER-CHIP, scrolls the display down N pixels
+func (self *Chip8) opScrollDown(in Instruction) {
+	self.scroll(0, int(in.N))
+	self.Pc += 2
+}
+
+// 00DN: XO-CHIP, scrolls the display up N pixels
+func (self *Chip8) opScrollUp(in Instruction) {
+	self.scroll(0, -int(in.N))
+	self.Pc += 2
+}
+
+// 00E0: Clears the screen
+func (self *Chip8) opClear(in Instruction) {
+	self.clearScreen()
+	self.Pc += 2
+}
+
+// 00EE: Returns from subroutine
+func (self *Chip8) opReturn(in Instruction) {
+	if self.Sp == 0 {
+		self.fault(in, ErrStackUnderflow, true)
+		return
+	}
+	self.Sp--                     // 16 levels of stack, decrease stack pointer to prevent overwrite
+	self.Pc = self.Stack[self.Sp] // Put the stored return address from the stack back into the program counter
+	self.Pc += 2                  // Don't forget to increase the program counter!
+}
+
+// 00FB: SUPER-CHIP, scrolls right 4 pixels
+func (self *Chip8) opScrollRight(in Instruction) {
+	self.scroll(4, 0)
+	self.Pc += 2
+}
+
+// 00FC: SUPER-CHIP, scrolls left 4 pixels
+func (self *Chip8) opScrollLeft(in Instruction) {
+	self.scroll(-4, 0)
+	self.Pc += 2
+}
+
+// 00FD: SUPER-CHIP, exits the interpreter
+func (self *Chip8) opExit(in Instruction) {
+	self.Halted = true
+}
+
+// 00FE: SUPER-CHIP, low resolution 64x32
+func (self *Chip8) opLowRes(in Instruction) {
+	self.setHiRes(false)
+	self.Pc += 2
+}
+
+// 00FF: SUPER-CHIP, high resolution 128x64
+func (self *Chip8) opHighRes(in Instruction) {
+	self.setHiRes(true)
+	self.Pc += 2
+}
+
+// 1NNN: Jumps to address NNN
+func (self *Chip8) opJump(in Instruction) {
+	self.Pc = in.NNN
+}
+
+// 2NNN: Calls subroutine at NNN
+func (self *Chip8) opCall(in Instruction) {
+	if int(self.Sp) >= len(self.Stack) {
+		self.fault(in, ErrStackOverflow, true)
+		return
+	}
+	self.Stack[self.Sp] = self.Pc // Store current address in stack
+	self.Sp++                     // Increment stack pointer
+	self.Pc = in.NNN              // Set the program counter to the address at NNN
+}
+
+// 3XNN: Skips the next instruction if VX equals NN
+func (self *Chip8) opSkipEqual(in Instruction) {
+	self.skipIf(self.V[in.X] == in.NN)
+}
+
+// 4XNN: Skips the next instruction if VX doesn't equal NN
+func (self *Chip8) opSkipNotEqual(in Instruction) {
+	self.skipIf(self.V[in.X] != in.NN)
+}
+
+// 5XY0: Skips the next instruction if VX equals VY
+func (self *Chip8) opSkipEqualReg(in Instruction) {
+	self.skipIf(self.V[in.X] == self.V[in.Y])
+}
+
+// 5XY2: XO-CHIP, stores VX to VY (either direction) in memory starting at I
+func (self *Chip8) opSaveRange(in Instruction) {
+	for i, r := range registerRange(in.X, in.Y) {
+		self.store(self.Index+uint16(i), self.V[r])
+	}
+	self.Pc += 2
+}
+
+// 5XY3: XO-CHIP, fills VX to VY (either direction) from memory starting at I
+func (self *Chip8) opLoadRange(in Instruction) {
+	for i, r := range registerRange(in.X, in.Y) {
+		self.V[r] = self.load(self.Index + uint16(i))
+	}
+	self.Pc += 2
+}
+
+// 6XNN: Sets VX to NN
+func (self *Chip8) opSet(in Instruction) {
+	self.V[in.X] = in.NN
+	self.Pc += 2
+}
+
+// 7XNN: Adds NN to VX, leaving VF alone
+func (self *Chip8) opAdd(in Instruction) {
+	self.V[in.X] += in.NN
+	self.Pc += 2
+}
+
+// 8XY0: Sets VX to the value of VY
+func (self *Chip8) opMove(in Instruction) {
+	self.V[in.X] = self.V[in.Y]
+	self.Pc += 2
+}
+
+// 8XY1, 8XY2 and 8XY3 reset VF on the VIP
+func (self *Chip8) logicDone() {
+	if self.Quirks.VFReset {
+		self.V[0xF] = 0
+	}
+	self.Pc += 2
+}
+
+// 8XY1: Sets VX to VX or VY
+func (self *Chip8) opOr(in Instruction) {
+	self.V[in.X] |= self.V[in.Y]
+	self.logicDone()
+}
+
+// 8XY2: Sets VX to VX and VY
+func (self *Chip8) opAnd(in Instruction) {
+	self.V[in.X] &= self.V[in.Y]
+	self.logicDone()
+}
+
+// 8XY3: Sets VX to VX xor VY
+func (self *Chip8) opXor(in Instruction) {
+	self.V[in.X] ^= self.V[in.Y]
+	self.logicDone()
+}
+
+// Sets VX to a result and VF to flag. VF is written last, so the flag wins
+// when VF is also the destination.
+func (self *Chip8) setWithFlag(x byte, result byte, flag bool) {
+	self.V[x] = result
+	if flag {
+		self.V[0xF] = 1
+	} else {
+		self.V[0xF] = 0
+	}
+	self.Pc += 2
+}
+
+// 8XY4: Adds VY to VX. VF is set to 1 when there's a carry, and to 0 when there isn't
+func (self *Chip8) opAddReg(in Instruction) {
+	vx, vy := self.V[in.X], self.V[in.Y]
+	self.setWithFlag(in.X, vx+vy, vy > 0xFF-vx)
+}
+
+// 8XY5: VY is subtracted from VX. VF is set to 0 when there's a borrow, and 1 when there isn't
+func (self *Chip8) opSub(in Instruction) {
+	vx, vy := self.V[in.X], self.V[in.Y]
+	self.setWithFlag(in.X, vx-vy, vy <= vx)
+}
+
+// The register 8XY6 and 8XYE shift. The VIP shifted VY into VX, later
+// interpreters shift VX in place.
+func (self *Chip8) shiftSource(in Instruction) byte {
+	if self.Quirks.ShiftUsesVY {
+		return self.V[in.Y]
+	}
+	return self.V[in.X]
+}
+
+// 8XY6: Shifts VX right by one. VF set to the value of the least significant bit of VX before the shift
+func (self *Chip8) opShiftRight(in Instruction) {
+	src := self.shiftSource(in)
+	self.setWithFlag(in.X, src>>1, src&0x1 != 0)
+}
+
+// 8XY7: Sets VX to VY minus VX. VF is set to 0 when there's a borrow, and 1 when there isn't
+func (self *Chip8) opSubReverse(in Instruction) {
+	vx, vy := self.V[in.X], self.V[in.Y]
+	self.setWithFlag(in.X, vy-vx, vx <= vy)
+}
+
+// 8XYE: Shifts VX left by one. VF is set to the value of the most significant bit of VX before the shift
+func (self *Chip8) opShiftLeft(in Instruction) {
+	src := self.shiftSource(in)
+	self.setWithFlag(in.X, src<<1, src>>7 != 0)
+}
+
+// 9XY0: Skips the next instruction if VX doesn't equal VY
+func (self *Chip8) opSkipNotEqualReg(in Instruction) {
+	self.skipIf(self.V[in.X] != self.V[in.Y])
+}
+
+// ANNN: Sets I to the address NNN
+func (self *Chip8) opSetIndex(in Instruction) {
+	self.Index = in.NNN
+	self.Pc += 2
+}
+
+// BNNN: Jumps to the address NNN plus V0
+func (self *Chip8) opJumpOffset(in Instruction) {
+	if self.Quirks.JumpUsesVX { // CHIP-48 read it as BXNN, jump to XNN plus VX
+		self.Pc = in.NNN + uint16(self.V[in.X])
+	} else {
+		self.Pc = in.NNN + uint16(self.V[0])
+	}
+}
+
+// CXNN: Sets VX to the result of a bitwise and operation on a random number and NN
+func (self *Chip8) opRandom(in Instruction) {
+	self.Pc += 2
+	self.V[in.X] = self.random() & in.NN
+}
+
+// DXYN: Draws a sprite at coordinate (VX, VY) that has a width of 8 pixels and a height of N pixels.
+// Each row of 8 pixels is read as bit-coded starting from memory location I;
+// I value doesn't change after the execution of this instruction.
+// VF is set to 1 if any screen pixels are flipped from set to unset when the sprite is drawn,
+// and to 0 if that doesn't happen
+// With DisplayWait, drawing only happens at the start of a frame like the VIP
+func (self *Chip8) opDraw(in Instruction) {
+	if self.Quirks.DisplayWait && !self.vblank {
+		return
+	}
+	self.vblank = false
+
+	self.drawSprite(self.V[in.X], self.V[in.Y], uint16(in.N))
+	self.Pc += 2
+}
+
+// EX9E: Skips the next instruction if the key in the low nibble of VX is
+// pressed
+func (self *Chip8) opSkipKey(in Instruction) {
+	self.skipIf(self.Key[self.V[in.X]&0xF] != 0)
+}
+
+// EXA1: Skips the next instruction if the key in the low nibble of VX isn't
+// pressed
+func (self *Chip8) opSkipNotKey(in Instruction) {
+	self.skipIf(self.Key[self.V[in.X]&0xF] == 0)
+}
+
+// F000 NNNN: XO-CHIP, sets I to the 16 bit address in the next word
+func (self *Chip8) opSetIndexLong(in Instruction) {
+	self.Index = in.Long
+	self.Pc += 4
+}
+
+// FN01: XO-CHIP, selects the bitplanes N to draw to
+func (self *Chip8) opPlane(in Instruction) {
+	self.Plane = in.X & AllPlanes
+	self.Pc += 2
+}
+
+// F002: XO-CHIP, loads the 16 byte audio pattern from I
+func (self *Chip8) opAudio(in Instruction) {
+	for i := range self.Pattern {
+		self.Pattern[i] = self.load(self.Index + uint16(i))
+	}
+	self.PatternLoaded = true
+	self.Pc += 2
+}
+
+// FX07: Sets VX to the value of the delay timer
+func (self *Chip8) opGetDelay(in Instruction) {
+	self.V[in.X] = self.Delay_timer
+	self.Pc += 2
+}
+
+// FX0A: A key press is awaited, and then stored in VX
+func (self *Chip8) opWaitKey(in Instruction) {
+	keyPressed := false
+	for i := 0; i < 16; i++ {
+		if self.Key[i] != 0 {
+			self.V[in.X] = byte(i)
+			keyPressed = true
+		}
+	}
+	if keyPressed {
+		self.Pc += 2
+	}
+}
+
+// FX15: Sets the delay timer to VX
+func (self *Chip8) opSetDelay(in Instruction) {
+	self.Delay_timer = self.V[in.X]
+	self.Pc += 2
+}
+
+// FX18: Sets the sound timer to VX
+func (self *Chip8) opSetSound(in Instruction) {
+	self.Sound_timer = self.V[in.X]
+	self.Pc += 2
+}
+
+// FX1E: Adds VX to I. VF is set to 1 when range overflow (I+VX>0xFFF), and 0 when there isn't.
+func (self *Chip8) opAddIndex(in Instruction) {
+	if self.Index+uint16(self.V[in.X]) > 0xFFF {
+		self.V[0xF] = 1
+	} else {
+		self.V[0xF] = 0
+	}
+	self.Index += uint16(self.V[in.X])
+	self.Pc += 2
+}
+
+// FX29: Sets I to the location of the sprite for the character in VX. Characters 0-F (in hexadecimal) are represented by a 4x5 font
+func (self *Chip8) opFont(in Instruction) {
+	self.Index = uint16(self.V[in.X]) * 0x5
+	self.Pc += 2
+}
+
+// FX30: SUPER-CHIP, sets I to the 8x10 font sprite for the character in VX
+func (self *Chip8) opBigFont(in Instruction) {
+	self.Index = BigFontStart + uint16(self.V[in.X]&0xF)*10
+	self.Pc += 2
+}
+
+// FX33: Stores the Binary-coded decimal representation of VX at the addresses I, I plus 1, and I plus 2
+func (self *Chip8) opBCD(in Instruction) {
+	v := self.V[in.X]
+	self.store(self.Index, v/100)
+	self.store(self.Index+1, (v/10)%10)
+	self.store(self.Index+2, v%10)
+	self.Pc += 2
+}
+
+// FX3A: XO-CHIP, sets the audio pattern pitch to VX
+func (self *Chip8) opPitch(in Instruction) {
+	self.Pitch = self.V[in.X]
+	self.Pc += 2
+}
+
+// FX55: Stores V0 to VX (including VX) in memory starting at address I
+func (self *Chip8) opStore(in Instruction) {
+	for i := 0; i <= int(in.X); i++ {
+		self.store(self.Index+uint16(i), self.V[i])
+	}
+	if self.Quirks.LoadStoreIncrementsI {
+		self.Index += uint16(in.X) + 1
+	}
+	self.Pc += 2
+}
+
+// FX65: Fills V0 to VX (including VX) with values from memory starting at address I
+func (self *Chip8) opLoad(in Instruction) {
+	for i := 0; i <= int(in.X); i++ {
+		self.V[i] = self.load(self.Index + uint16(i))
+	}
+	if self.Quirks.LoadStoreIncrementsI {
+		self.Index += uint16(in.X) + 1
+	}
+	self.Pc += 2
+}
+
+// FX75: SUPER-CHIP, stores V0 to VX in the RPL user flags
+func (self *Chip8) opSaveFlags(in Instruction) {
+	for i := 0; i <= int(in.X); i++ {
+		self.Rpl[i] = self.V[i]
+	}
+	self.Pc += 2
+}
+
+// FX85: SUPER-CHIP, fills V0 to VX from the RPL user flags
+func (self *Chip8) opLoadFlags(in Instruction) {
+	for i := 0; i <= int(in.X); i++ {
+		self.V[i] = self.Rpl[i]
+	}
+	self.Pc += 2
+}
+
+// Register numbers from x to y inclusive, counting down if y is below x.
+func registerRange(x, y byte) []byte {
+	var regs []byte
+	for r := x; ; {
+		regs = append(regs, r)
+		if r == y {
+			return regs
+		}
+		if y > x {
+			r++
+		} else {
+			r--
+		}
+	}
+}
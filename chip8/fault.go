@@ -0,0 +1,74 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Instructions the machine can't run. EmulateCycle returns an OpcodeError
+// wrapping one of these so callers can test them with errors.Is.
+var (
+	ErrUnknownOpcode  = errors.New("unknown opcode")
+	ErrMachineCode    = errors.New("machine code routine")
+	ErrStackOverflow  = errors.New("stack overflow")
+	ErrStackUnderflow = errors.New("return with an empty stack")
+)
+
+// OpcodeError describes an instruction the machine couldn't run.
+type OpcodeError struct {
+	Pc     uint16 // Address of the instruction
+	Opcode uint16
+	Err    error // One of the Err* values above
+}
+
+func (self *OpcodeError) Error() string {
+	return fmt.Sprintf("chip8: %v 0x%04X at 0x%03X", self.Err, self.Opcode, self.Pc)
+}
+
+func (self *OpcodeError) Unwrap() error {
+	return self.Err
+}
+
+// InvalidPolicy picks what happens when the machine meets an opcode it
+// doesn't know. Stack overflows and underflows always halt.
+type InvalidPolicy int
+
+const (
+	HaltOnInvalid InvalidPolicy = iota // Halt, leaving Pc at the opcode
+	SkipInvalid                        // Move on to the next instruction
+	IgnoreSys                          // Run 0NNN as a no-op, halt on anything else
+)
+
+var invalidPolicyNames = map[string]InvalidPolicy{
+	"halt": HaltOnInvalid,
+	"skip": SkipInvalid,
+	"sys":  IgnoreSys,
+}
+
+// ParseInvalidPolicy looks a policy up by name: halt, skip or sys.
+func ParseInvalidPolicy(name string) (InvalidPolicy, error) {
+	p, ok := invalidPolicyNames[name]
+	if !ok {
+		return 0, fmt.Errorf("chip8: unknown invalid opcode policy %q, want halt, skip or sys", name)
+	}
+	return p, nil
+}
+
+func (self InvalidPolicy) String() string {
+	for name, p := range invalidPolicyNames {
+		if p == self {
+			return name
+		}
+	}
+	return fmt.Sprintf("InvalidPolicy(%d)", int(self))
+}
+
+// Reports err for the instruction at Pc, halting the machine when halt is
+// set. Pc stays at the instruction so it can be inspected.
+func (self *Chip8) fault(in Instruction, err error, halt bool) {
+	self.err = &OpcodeError{Pc: self.Pc, Opcode: in.Opcode, Err: err}
+	if halt {
+		self.Halted = true
+		self.Fault = self.err
+	}
+}
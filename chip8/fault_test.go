@@ -0,0 +1,113 @@
+package chip8_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+func TestUnknownOpcodeHalts(t *testing.T) {
+	c := schipProgram(0x60, 0x05, 0xF1, 0x23)
+	if err := c.EmulateCycle(); err != nil {
+		t.Fatal(err)
+	}
+	err := c.EmulateCycle()
+	var oe *chip8.OpcodeError
+	if !errors.As(err, &oe) || !errors.Is(err, chip8.ErrUnknownOpcode) {
+		t.Fatalf("got %v, want an unknown opcode error", err)
+	}
+	if oe.Pc != 0x202 || oe.Opcode != 0xF123 || c.Pc != 0x202 {
+		t.Errorf("error at %03X for %04X, Pc %03X", oe.Pc, oe.Opcode, c.Pc)
+	}
+	if !c.Halted || c.Fault != oe {
+		t.Error("machine should halt with the error as its Fault")
+	}
+	if got := c.EmulateCycle(); got != err {
+		t.Errorf("halted machine returned %v, want the fault again", got)
+	}
+	if err.Error() != "chip8: unknown opcode 0xF123 at 0x202" {
+		t.Errorf("message %q", err)
+	}
+
+	c.Init()
+	if c.Halted || c.Fault != nil {
+		t.Error("Init should clear the fault")
+	}
+}
+
+func TestSkipInvalid(t *testing.T) {
+	c := schipProgram(0x01, 0x23, 0xE0, 0x00, 0x60, 0x05)
+	c.OnInvalid = chip8.SkipInvalid
+	if err := c.EmulateCycle(); !errors.Is(err, chip8.ErrMachineCode) {
+		t.Errorf("0123: got %v", err)
+	}
+	if err := c.EmulateCycle(); !errors.Is(err, chip8.ErrUnknownOpcode) {
+		t.Errorf("E000: got %v", err)
+	}
+	if err := c.EmulateCycle(); err != nil || c.V[0] != 5 || c.Halted || c.Fault != nil {
+		t.Errorf("should run on after skipping, V0=%d err %v", c.V[0], err)
+	}
+}
+
+func TestIgnoreSys(t *testing.T) {
+	c := schipProgram(0x01, 0x23, 0x00, 0x00)
+	c.OnInvalid = chip8.IgnoreSys
+	if err := c.EmulateCycle(); err != nil || c.Pc != 0x202 {
+		t.Errorf("0NNN should be a no-op, Pc %03X err %v", c.Pc, err)
+	}
+	// Running into empty memory still halts
+	if err := c.EmulateCycle(); !errors.Is(err, chip8.ErrUnknownOpcode) || !c.Halted {
+		t.Errorf("0000: got %v", err)
+	}
+}
+
+func TestStackFaults(t *testing.T) {
+	// 2200 calls itself forever
+	c := schipProgram(0x22, 0x00)
+	var err error
+	for i := 0; i < 20 && err == nil; i++ {
+		err = c.EmulateCycle()
+	}
+	if !errors.Is(err, chip8.ErrStackOverflow) || c.Sp != 16 || !c.Halted {
+		t.Errorf("got %v with Sp %d", err, c.Sp)
+	}
+
+	c = schipProgram(0x00, 0xEE)
+	c.OnInvalid = chip8.SkipInvalid // Only applies to unknown opcodes
+	if err := c.EmulateCycle(); !errors.Is(err, chip8.ErrStackUnderflow) || !c.Halted || c.Pc != 0x200 {
+		t.Errorf("got %v, Pc %03X", err, c.Pc)
+	}
+}
+
+func TestRunFrameReportsFault(t *testing.T) {
+	c := schipProgram(0xFF, 0xFF)
+	if err := c.RunFrame(); !errors.Is(err, chip8.ErrUnknownOpcode) {
+		t.Errorf("got %v", err)
+	}
+	if c.Frame != 1 {
+		t.Error("the timers should still tick")
+	}
+}
+
+// Only the low nibble of VX picks the key, rather than indexing past Key
+func TestSkipKeyMasksVX(t *testing.T) {
+	c := schipProgram(0xE0, 0x9E)
+	c.V[0] = 0x13
+	c.Key[3] = 1
+	if err := c.EmulateCycle(); err != nil || c.Pc != 0x204 {
+		t.Errorf("E09E with V0=0x13 should test key 3, Pc %03X", c.Pc)
+	}
+}
+
+func TestParseInvalidPolicy(t *testing.T) {
+	for _, p := range []chip8.InvalidPolicy{chip8.HaltOnInvalid, chip8.SkipInvalid, chip8.IgnoreSys} {
+		got, err := chip8.ParseInvalidPolicy(p.String())
+		if err != nil || got != p {
+			t.Errorf("%v: got %v, %v", p, got, err)
+		}
+	}
+	if _, err := chip8.ParseInvalidPolicy("ignore"); err == nil {
+		t.Error("unknown policy should fail")
+	}
+}
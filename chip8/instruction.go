@@ -0,0 +1,341 @@
+package chip8
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Kind identifies an instruction whatever its operands, e.g. OpAddReg for
+// every 8XY4.
+type Kind int
+
+const (
+	OpInvalid Kind = iota
+
+	OpScrollDown  // 00CN
+	OpScrollUp    // 00DN
+	OpClear       // 00E0
+	OpReturn      // 00EE
+	OpScrollRight // 00FB
+	OpScrollLeft  // 00FC
+	OpExit        // 00FD
+	OpLowRes      // 00FE
+	OpHighRes     // 00FF
+
+	OpJump            // 1NNN
+	OpCall            // 2NNN
+	OpSkipEqual       // 3XNN
+	OpSkipNotEqual    // 4XNN
+	OpSkipEqualReg    // 5XY0
+	OpSaveRange       // 5XY2
+	OpLoadRange       // 5XY3
+	OpSet             // 6XNN
+	OpAdd             // 7XNN
+	OpMove            // 8XY0
+	OpOr              // 8XY1
+	OpAnd             // 8XY2
+	OpXor             // 8XY3
+	OpAddReg          // 8XY4
+	OpSub             // 8XY5
+	OpShiftRight      // 8XY6
+	OpSubReverse      // 8XY7
+	OpShiftLeft       // 8XYE
+	OpSkipNotEqualReg // 9XY0
+	OpSetIndex        // ANNN
+	OpJumpOffset      // BNNN
+	OpRandom          // CXNN
+	OpDraw            // DXYN
+	OpSkipKey         // EX9E
+	OpSkipNotKey      // EXA1
+
+	OpSetIndexLong // F000 NNNN
+	OpPlane        // FN01
+	OpAudio        // F002
+	OpGetDelay     // FX07
+	OpWaitKey      // FX0A
+	OpSetDelay     // FX15
+	OpSetSound     // FX18
+	OpAddIndex     // FX1E
+	OpFont         // FX29
+	OpBigFont      // FX30
+	OpBCD          // FX33
+	OpPitch        // FX3A
+	OpStore        // FX55
+	OpLoad         // FX65
+	OpSaveFlags    // FX75
+	OpLoadFlags    // FX85
+
+	NumKinds
+)
+
+// InstructionSet is the extension an instruction first appeared in. Each set
+// is a superset of the one before.
+type InstructionSet int
+
+const (
+	SetChip8 InstructionSet = iota
+	SetSuperChip
+	SetXOChip
+)
+
+var kinds = [NumKinds]struct {
+	pattern     string
+	set         InstructionSet
+	description string
+}{
+	OpInvalid:         {"????", SetChip8, "not an instruction"},
+	OpScrollDown:      {"00CN", SetSuperChip, "scroll down N pixels"},
+	OpScrollUp:        {"00DN", SetXOChip, "scroll up N pixels"},
+	OpClear:           {"00E0", SetChip8, "clear the screen"},
+	OpReturn:          {"00EE", SetChip8, "return from a subroutine"},
+	OpScrollRight:     {"00FB", SetSuperChip, "scroll right 4 pixels"},
+	OpScrollLeft:      {"00FC", SetSuperChip, "scroll left 4 pixels"},
+	OpExit:            {"00FD", SetSuperChip, "exit the interpreter"},
+	OpLowRes:          {"00FE", SetSuperChip, "64x32 low resolution"},
+	OpHighRes:         {"00FF", SetSuperChip, "128x64 high resolution"},
+	OpJump:            {"1NNN", SetChip8, "jump to NNN"},
+	OpCall:            {"2NNN", SetChip8, "call the subroutine at NNN"},
+	OpSkipEqual:       {"3XNN", SetChip8, "skip if VX == NN"},
+	OpSkipNotEqual:    {"4XNN", SetChip8, "skip if VX != NN"},
+	OpSkipEqualReg:    {"5XY0", SetChip8, "skip if VX == VY"},
+	OpSaveRange:       {"5XY2", SetXOChip, "store VX to VY at I"},
+	OpLoadRange:       {"5XY3", SetXOChip, "load VX to VY from I"},
+	OpSet:             {"6XNN", SetChip8, "VX = NN"},
+	OpAdd:             {"7XNN", SetChip8, "VX += NN"},
+	OpMove:            {"8XY0", SetChip8, "VX = VY"},
+	OpOr:              {"8XY1", SetChip8, "VX |= VY"},
+	OpAnd:             {"8XY2", SetChip8, "VX &= VY"},
+	OpXor:             {"8XY3", SetChip8, "VX ^= VY"},
+	OpAddReg:          {"8XY4", SetChip8, "VX += VY, VF = carry"},
+	OpSub:             {"8XY5", SetChip8, "VX -= VY, VF = no borrow"},
+	OpShiftRight:      {"8XY6", SetChip8, "VX >>= 1, VF = bit shifted out"},
+	OpSubReverse:      {"8XY7", SetChip8, "VX = VY - VX, VF = no borrow"},
+	OpShiftLeft:       {"8XYE", SetChip8, "VX <<= 1, VF = bit shifted out"},
+	OpSkipNotEqualReg: {"9XY0", SetChip8, "skip if VX != VY"},
+	OpSetIndex:        {"ANNN", SetChip8, "I = NNN"},
+	OpJumpOffset:      {"BNNN", SetChip8, "jump to NNN + V0"},
+	OpRandom:          {"CXNN", SetChip8, "VX = random & NN"},
+	OpDraw:            {"DXYN", SetChip8, "draw N rows of sprite at VX, VY"},
+	OpSkipKey:         {"EX9E", SetChip8, "skip if key VX is down"},
+	OpSkipNotKey:      {"EXA1", SetChip8, "skip if key VX is up"},
+	OpSetIndexLong:    {"F000", SetXOChip, "I = the next 16 bit word"},
+	OpPlane:           {"FN01", SetXOChip, "draw to bitplanes N"},
+	OpAudio:           {"F002", SetXOChip, "load the audio pattern from I"},
+	OpGetDelay:        {"FX07", SetChip8, "VX = delay timer"},
+	OpWaitKey:         {"FX0A", SetChip8, "wait for a key, put it in VX"},
+	OpSetDelay:        {"FX15", SetChip8, "delay timer = VX"},
+	OpSetSound:        {"FX18", SetChip8, "sound timer = VX"},
+	OpAddIndex:        {"FX1E", SetChip8, "I += VX"},
+	OpFont:            {"FX29", SetChip8, "I = small font sprite for VX"},
+	OpBigFont:         {"FX30", SetSuperChip, "I = big font sprite for VX"},
+	OpBCD:             {"FX33", SetChip8, "store VX as 3 decimal digits at I"},
+	OpPitch:           {"FX3A", SetXOChip, "audio pitch = VX"},
+	OpStore:           {"FX55", SetChip8, "store V0 to VX at I"},
+	OpLoad:            {"FX65", SetChip8, "load V0 to VX from I"},
+	OpSaveFlags:       {"FX75", SetSuperChip, "store V0 to VX in the RPL flags"},
+	OpLoadFlags:       {"FX85", SetSuperChip, "load V0 to VX from the RPL flags"},
+}
+
+// String is the opcode pattern, e.g. "8XY4".
+func (self Kind) String() string {
+	if self < 0 || self >= NumKinds {
+		return fmt.Sprintf("Kind(%d)", int(self))
+	}
+	return kinds[self].pattern
+}
+
+// Set is the instruction set that added the instruction.
+func (self Kind) Set() InstructionSet {
+	return kinds[self].set
+}
+
+// Description says what the instruction does, e.g. "VX += VY, VF = carry".
+func (self Kind) Description() string {
+	return kinds[self].description
+}
+
+// Instruction is a decoded opcode with its operands pulled out.
+type Instruction struct {
+	Kind   Kind
+	Opcode uint16
+	X, Y   byte   // Register numbers
+	N      byte   // Low nibble
+	NN     byte   // Low byte
+	NNN    uint16 // Low 12 bits
+	Long   uint16 // The address following F000
+	Size   int    // 2, or 4 for F000 NNNN
+}
+
+// Decode decodes the instruction at the start of mem, recognising the whole
+// XO-CHIP superset. mem should hold 4 bytes for F000 NNNN, shorter slices are
+// padded with 0. 5XY2 and 5XY3 decode as the XO-CHIP register ranges, other
+// 5XYN and all 9XYN are skips without checking N like the original
+// interpreter.
+func Decode(mem []byte) Instruction {
+	var b [4]byte
+	copy(b[:], mem)
+	opcode := uint16(b[0])<<8 | uint16(b[1])
+	in := Instruction{
+		Opcode: opcode,
+		X:      b[0] & 0xF,
+		Y:      b[1] >> 4,
+		N:      b[1] & 0xF,
+		NN:     b[1],
+		NNN:    opcode & 0x0FFF,
+		Size:   2,
+	}
+	in.Kind = decodeKind(in)
+	if in.Kind == OpSetIndexLong {
+		in.Long = uint16(b[2])<<8 | uint16(b[3])
+		in.Size = 4
+	}
+	return in
+}
+
+// The 8XYN and FXNN instructions by their low nibble or byte.
+var (
+	arithmeticKinds = map[byte]Kind{0x0: OpMove, 0x1: OpOr, 0x2: OpAnd, 0x3: OpXor, 0x4: OpAddReg,
+		0x5: OpSub, 0x6: OpShiftRight, 0x7: OpSubReverse, 0xE: OpShiftLeft}
+	miscKinds = map[byte]Kind{0x01: OpPlane, 0x07: OpGetDelay, 0x0A: OpWaitKey, 0x15: OpSetDelay,
+		0x18: OpSetSound, 0x1E: OpAddIndex, 0x29: OpFont, 0x30: OpBigFont, 0x33: OpBCD,
+		0x3A: OpPitch, 0x55: OpStore, 0x65: OpLoad, 0x75: OpSaveFlags, 0x85: OpLoadFlags}
+)
+
+// The kind a machine without XO-CHIP runs. It treats 5XY2 and 5XY3 as 5XY0,
+// and the other XO-CHIP instructions are unknown, left to OnInvalid.
+func (self Kind) classic() Kind {
+	switch {
+	case self == OpSaveRange || self == OpLoadRange:
+		return OpSkipEqualReg
+	case kinds[self].set == SetXOChip:
+		return OpInvalid
+	}
+	return self
+}
+
+func decodeKind(in Instruction) Kind {
+	switch in.Opcode >> 12 {
+	case 0x0:
+		switch {
+		case in.Opcode&0xFFF0 == 0x00C0:
+			return OpScrollDown
+		case in.Opcode&0xFFF0 == 0x00D0:
+			return OpScrollUp
+		}
+		switch in.Opcode {
+		case 0x00E0:
+			return OpClear
+		case 0x00EE:
+			return OpReturn
+		case 0x00FB:
+			return OpScrollRight
+		case 0x00FC:
+			return OpScrollLeft
+		case 0x00FD:
+			return OpExit
+		case 0x00FE:
+			return OpLowRes
+		case 0x00FF:
+			return OpHighRes
+		}
+	case 0x1:
+		return OpJump
+	case 0x2:
+		return OpCall
+	case 0x3:
+		return OpSkipEqual
+	case 0x4:
+		return OpSkipNotEqual
+	case 0x5:
+		switch in.N {
+		case 0x2:
+			return OpSaveRange
+		case 0x3:
+			return OpLoadRange
+		}
+		return OpSkipEqualReg
+	case 0x6:
+		return OpSet
+	case 0x7:
+		return OpAdd
+	case 0x8:
+		if kind, ok := arithmeticKinds[in.N]; ok {
+			return kind
+		}
+	case 0x9:
+		return OpSkipNotEqualReg
+	case 0xA:
+		return OpSetIndex
+	case 0xB:
+		return OpJumpOffset
+	case 0xC:
+		return OpRandom
+	case 0xD:
+		return OpDraw
+	case 0xE:
+		switch in.NN {
+		case 0x9E:
+			return OpSkipKey
+		case 0xA1:
+			return OpSkipNotKey
+		}
+	case 0xF:
+		switch {
+		case in.Opcode == 0xF000:
+			return OpSetIndexLong
+		case in.Opcode == 0xF002:
+			return OpAudio
+		}
+		if kind, ok := miscKinds[in.NN]; ok {
+			return kind
+		}
+	}
+	return OpInvalid
+}
+
+// Coverage counts the instructions a program runs, by kind. Set
+// Chip8.Coverage to collect it.
+type Coverage struct {
+	Counts [NumKinds]uint64
+}
+
+// Executed lists the kinds of instruction that ran, in opcode order.
+func (self *Coverage) Executed() []Kind {
+	var executed []Kind
+	for kind := OpInvalid + 1; kind < NumKinds; kind++ {
+		if self.Counts[kind] > 0 {
+			executed = append(executed, kind)
+		}
+	}
+	return executed
+}
+
+// WriteTo writes a report of how many times each instruction ran, followed
+// by the ones that never did.
+func (self *Coverage) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	printf := func(format string, args ...interface{}) {
+		c, _ := fmt.Fprintf(bw, format, args...)
+		n += int64(c)
+	}
+
+	executed := self.Executed()
+	printf("%d of %d instructions executed\n", len(executed), NumKinds-1)
+	for _, kind := range executed {
+		printf("%12d  %s  %s\n", self.Counts[kind], kind, kind.Description())
+	}
+	if self.Counts[OpInvalid] > 0 {
+		printf("%12d  invalid opcodes\n", self.Counts[OpInvalid])
+	}
+	if len(executed) < int(NumKinds-1) {
+		printf("never executed:\n")
+		for kind := OpInvalid + 1; kind < NumKinds; kind++ {
+			if self.Counts[kind] == 0 {
+				printf("%12s  %s  %s\n", "", kind, kind.Description())
+			}
+		}
+	}
+	return n, bw.Flush()
+}
@@ -0,0 +1,78 @@
+package chip8_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		mem  []byte
+		want chip8.Instruction
+	}{
+		{[]byte{0x00, 0xE0}, chip8.Instruction{Kind: chip8.OpClear, Opcode: 0x00E0, Y: 0xE, NN: 0xE0, NNN: 0x0E0, Size: 2}},
+		{[]byte{0x8A, 0xB4}, chip8.Instruction{Kind: chip8.OpAddReg, Opcode: 0x8AB4, X: 0xA, Y: 0xB, N: 4, NN: 0xB4, NNN: 0xAB4, Size: 2}},
+		{[]byte{0xD1, 0x2F}, chip8.Instruction{Kind: chip8.OpDraw, Opcode: 0xD12F, X: 1, Y: 2, N: 0xF, NN: 0x2F, NNN: 0x12F, Size: 2}},
+		{[]byte{0xF0, 0x00, 0xBE, 0xEF}, chip8.Instruction{Kind: chip8.OpSetIndexLong, Opcode: 0xF000, Long: 0xBEEF, Size: 4}},
+		{[]byte{0x00, 0xC4}, chip8.Instruction{Kind: chip8.OpScrollDown, Opcode: 0x00C4, Y: 0xC, N: 4, NN: 0xC4, NNN: 0x0C4, Size: 2}},
+		{[]byte{0x91, 0x21}, chip8.Instruction{Kind: chip8.OpSkipNotEqualReg, Opcode: 0x9121, X: 1, Y: 2, N: 1, NN: 0x21, NNN: 0x121, Size: 2}},
+		{[]byte{0x81, 0x28}, chip8.Instruction{Kind: chip8.OpInvalid, Opcode: 0x8128, X: 1, Y: 2, N: 8, NN: 0x28, NNN: 0x128, Size: 2}},
+		{[]byte{0xF1, 0x02}, chip8.Instruction{Kind: chip8.OpInvalid, Opcode: 0xF102, X: 1, N: 2, NN: 0x02, NNN: 0x102, Size: 2}},
+		{[]byte{0x12}, chip8.Instruction{Kind: chip8.OpJump, Opcode: 0x1200, X: 2, NNN: 0x200, Size: 2}},
+	}
+	for _, c := range cases {
+		if got := chip8.Decode(c.mem); got != c.want {
+			t.Errorf("% X: got %+v, want %+v", c.mem, got, c.want)
+		}
+	}
+}
+
+// Every kind decodes from the opcode in its pattern and describes itself.
+func TestKinds(t *testing.T) {
+	for kind := chip8.OpInvalid + 1; kind < chip8.NumKinds; kind++ {
+		pattern := kind.String()
+		opcode := strings.NewReplacer("X", "1", "Y", "2", "N", "3").Replace(pattern)
+		var mem [2]byte
+		for i := range mem {
+			var b byte
+			for _, c := range opcode[2*i : 2*i+2] {
+				b = b<<4 | byte(strings.IndexRune("0123456789ABCDEF", c))
+			}
+			mem[i] = b
+		}
+		if got := chip8.Decode(mem[:]).Kind; got != kind {
+			t.Errorf("%s: %02X%02X decoded as %s", pattern, mem[0], mem[1], got)
+		}
+		if kind.Description() == "" {
+			t.Errorf("%s has no description", pattern)
+		}
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	// 6005 7001 1202: set V0 then add to it forever
+	c := schipProgram(0x60, 0x05, 0x70, 0x01, 0x12, 0x02)
+	c.Coverage = &chip8.Coverage{}
+	for i := 0; i < 7; i++ {
+		c.EmulateCycle()
+	}
+	executed := c.Coverage.Executed()
+	if len(executed) != 3 || executed[0] != chip8.OpJump || executed[1] != chip8.OpSet || executed[2] != chip8.OpAdd {
+		t.Fatalf("executed %v", executed)
+	}
+	if c.Coverage.Counts[chip8.OpAdd] != 3 || c.Coverage.Counts[chip8.OpJump] != 3 {
+		t.Errorf("counts %v", c.Coverage.Counts)
+	}
+
+	var buf bytes.Buffer
+	c.Coverage.WriteTo(&buf)
+	report := buf.String()
+	for _, want := range []string{"3 of 50 instructions executed", "           3  7XNN  VX += NN", "never executed:", "00E0  clear the screen"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report is missing %q:\n%s", want, report)
+		}
+	}
+}
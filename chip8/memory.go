@@ -0,0 +1,21 @@
+package chip8
+
+// MemoryHook is told about every data read and write instructions make to
+// Memory, after the access. Instruction fetches aren't reported.
+type MemoryHook func(addr uint16, write bool)
+
+// Reads a byte of data, reporting it to OnMemory.
+func (self *Chip8) load(addr uint16) byte {
+	if self.OnMemory != nil {
+		self.OnMemory(addr, false)
+	}
+	return self.Memory[addr]
+}
+
+// Writes a byte of data, reporting it to OnMemory.
+func (self *Chip8) store(addr uint16, value byte) {
+	self.Memory[addr] = value
+	if self.OnMemory != nil {
+		self.OnMemory(addr, true)
+	}
+}
@@ -0,0 +1,117 @@
+package chip8
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Quirks picks between the interpretations of the ambiguous instructions
+// used by different CHIP-8 interpreters over the years. The zero value shifts
+// VX in place, leaves I alone on FX55/FX65, jumps with V0, keeps VF on logic
+// ops, clips sprites, never waits for the display and is a 4K machine.
+type Quirks struct {
+	ShiftUsesVY          bool // 8XY6/8XYE shift VY into VX instead of shifting VX in place
+	LoadStoreIncrementsI bool // FX55/FX65 leave I pointing after the last register
+	JumpUsesVX           bool // BNNN jumps to XNN + VX instead of NNN + V0
+	VFReset              bool // 8XY1/8XY2/8XY3 reset VF to 0
+	SpriteWrap           bool // DXYN wraps pixels round the screen edges instead of clipping them
+	DisplayWait          bool // DXYN waits for the next 60Hz tick before drawing
+	XOChip               bool // XO-CHIP instructions run, skips step over F000 NNNN and ROMs can fill 64K
+}
+
+// Presets for the well known interpreters.
+var (
+	// The original interpreter on the COSMAC VIP.
+	QuirksVIP = Quirks{
+		ShiftUsesVY:          true,
+		LoadStoreIncrementsI: true,
+		VFReset:              true,
+		DisplayWait:          true,
+	}
+
+	// CHIP-48 on the HP48 calculators, which most 90s games were written for.
+	QuirksChip48 = Quirks{
+		JumpUsesVX: true,
+	}
+
+	// SUPER-CHIP 1.1, which kept the CHIP-48 behaviour.
+	QuirksSuperChip = Quirks{
+		JumpUsesVX: true,
+	}
+
+	// XO-CHIP as implemented by Octo.
+	QuirksXOChip = Quirks{
+		ShiftUsesVY:          true,
+		LoadStoreIncrementsI: true,
+		SpriteWrap:           true,
+		XOChip:               true,
+	}
+)
+
+// QuirkProfiles maps the profile names accepted by SetQuirkProfile to their quirks.
+var QuirkProfiles = map[string]Quirks{
+	"vip":    QuirksVIP,
+	"chip48": QuirksChip48,
+	"schip":  QuirksSuperChip,
+	"xochip": QuirksXOChip,
+}
+
+// DefaultProfile is used for ROMs that aren't listed in RomProfiles.
+const DefaultProfile = "vip"
+
+// RomProfiles records which profile known ROMs were written for, keyed by the
+// hex SHA-1 of the ROM.
+var RomProfiles = map[string]string{
+	"f13766c14aeb02ad8d4d103cb5eadd282d20cddc": "vip",    // brix.c8
+	"1830eb401ba8789a477dfcf294873a5479ebcfe8": "vip",    // pong.c8
+	"bdb92475acfe11bc7814a2f5eade13fcd09b756a": "vip",    // ufo.c8
+	"5f518084744bf3cb8733f6e5454dfd1634320563": "chip48", // tetris.c8
+	"f100197f0f2f05b4f3c8c31ab9c2c3930d3e9571": "chip48", // invaders.c8
+	"bc5faf54f04da3f4dbde50d3b31ccfc2bf8b9e06": "schip",  // alien.c8
+	"a56c09537df0f32e2d49fb68cb2ba8216b38f632": "schip",  // ant.c8
+	"6d677bb44500a5ee4754b3a75516cfd9e73947fc": "schip",  // joust.c8
+}
+
+// ProfileNames lists the known quirk profiles in alphabetical order.
+func ProfileNames() []string {
+	names := make([]string, 0, len(QuirkProfiles))
+	for name := range QuirkProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetQuirkProfile sets Quirks from a named profile. "auto" (or "") picks the
+// profile listed for the loaded ROM in RomProfiles, falling back to
+// DefaultProfile, or "xochip" for a ROM only XO-CHIP has room for. It returns the name of the profile used, or a *RomError
+// if the loaded ROM is too large for that machine, leaving Quirks alone.
+func (self *Chip8) SetQuirkProfile(name string) (string, error) {
+	if name == "" || name == "auto" {
+		name = DefaultProfile
+		if p, ok := RomProfiles[hex.EncodeToString(self.RomHash[:])]; ok {
+			name = p
+		} else if self.RomSize > MaxClassicRomSize {
+			name = "xochip"
+		}
+	}
+	q, ok := QuirkProfiles[name]
+	if !ok {
+		return "", fmt.Errorf("chip8: unknown quirk profile %q (have %v)", name, ProfileNames())
+	}
+	if max := q.MaxRomSize(); self.RomSize > max {
+		return "", &RomError{Name: self.RomName, Size: self.RomSize, Err: ErrRomTooLarge, Max: max}
+	}
+	self.Quirks = q
+	return name, nil
+}
+
+// MaxRomSize is the largest ROM the machine can hold: MaxRomSize with the
+// XO-CHIP 64K of memory, MaxClassicRomSize otherwise.
+func (self Quirks) MaxRomSize() int {
+	if self.XOChip {
+		return MaxRomSize
+	}
+	return MaxClassicRomSize
+}
@@ -0,0 +1,139 @@
+package chip8_test
+
+import (
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+// Runs a single instruction with the given quirks on a fresh machine.
+func runQuirk(q chip8.Quirks, b1, b2 byte, setup func(c *chip8.Chip8)) *chip8.Chip8 {
+	c := &chip8.Chip8{}
+	c.Init()
+	c.Quirks = q
+	c.Memory[0x200] = b1
+	c.Memory[0x201] = b2
+	if setup != nil {
+		setup(c)
+	}
+	c.EmulateCycle()
+	return c
+}
+
+func TestQuirkShiftUsesVY(t *testing.T) {
+	set := func(c *chip8.Chip8) { c.V[0] = 0x01; c.V[1] = 0x80 }
+
+	// 8016: VIP shifts V1 into V0
+	c := runQuirk(chip8.Quirks{ShiftUsesVY: true}, 0x80, 0x16, set)
+	if c.V[0] != 0x40 || c.V[0xF] != 0 {
+		t.Errorf("VIP shift right: V0=%02X VF=%X", c.V[0], c.V[0xF])
+	}
+	c = runQuirk(chip8.Quirks{}, 0x80, 0x16, set)
+	if c.V[0] != 0x00 || c.V[0xF] != 1 {
+		t.Errorf("CHIP-48 shift right: V0=%02X VF=%X", c.V[0], c.V[0xF])
+	}
+
+	// 801E
+	c = runQuirk(chip8.Quirks{ShiftUsesVY: true}, 0x80, 0x1E, set)
+	if c.V[0] != 0x00 || c.V[0xF] != 1 {
+		t.Errorf("VIP shift left: V0=%02X VF=%X", c.V[0], c.V[0xF])
+	}
+
+	// VF is written last, so shifting VF itself leaves the flag
+	c = runQuirk(chip8.Quirks{}, 0x8F, 0x06, func(c *chip8.Chip8) { c.V[0xF] = 0x03 })
+	if c.V[0xF] != 1 {
+		t.Errorf("shifting VF should leave the flag, got %X", c.V[0xF])
+	}
+}
+
+func TestQuirkVFReset(t *testing.T) {
+	for _, op := range []byte{0x11, 0x12, 0x13} {
+		set := func(c *chip8.Chip8) { c.V[0xF] = 5 }
+		if c := runQuirk(chip8.QuirksVIP, 0x80, op, set); c.V[0xF] != 0 {
+			t.Errorf("80%02X with VFReset: VF=%X", op, c.V[0xF])
+		}
+		if c := runQuirk(chip8.Quirks{}, 0x80, op, set); c.V[0xF] != 5 {
+			t.Errorf("80%02X without VFReset: VF=%X", op, c.V[0xF])
+		}
+	}
+}
+
+func TestQuirkJumpUsesVX(t *testing.T) {
+	set := func(c *chip8.Chip8) { c.V[0] = 1; c.V[3] = 2 }
+	if c := runQuirk(chip8.Quirks{}, 0xB3, 0x00, set); c.Pc != 0x301 {
+		t.Errorf("B300 with V0: PC=%03X", c.Pc)
+	}
+	if c := runQuirk(chip8.QuirksChip48, 0xB3, 0x00, set); c.Pc != 0x302 {
+		t.Errorf("B300 with VX: PC=%03X", c.Pc)
+	}
+}
+
+func TestQuirkSpriteClipAndWrap(t *testing.T) {
+	// D011 draws the 1 byte sprite 0xFF at (60, 31)
+	set := func(c *chip8.Chip8) {
+		c.V[0] = 60
+		c.V[1] = 31
+		c.Index = 0x300
+		c.Memory[0x300] = 0xFF
+	}
+	c := runQuirk(chip8.Quirks{}, 0xD0, 0x11, set)
+	if c.Gfx[31*64+63] != 1 || c.Gfx[31*64] != 0 {
+		t.Error("clipped sprite should stop at the right edge")
+	}
+	c = runQuirk(chip8.Quirks{SpriteWrap: true}, 0xD0, 0x11, set)
+	if c.Gfx[31*64+63] != 1 || c.Gfx[31*64+3] != 1 || c.Gfx[31*64+4] != 0 {
+		t.Error("wrapped sprite should continue on the left edge")
+	}
+
+	// The starting position always wraps
+	c = runQuirk(chip8.Quirks{}, 0xD0, 0x11, func(c *chip8.Chip8) {
+		set(c)
+		c.V[0] = 64 + 2
+		c.V[1] = 32 + 1
+	})
+	if c.Gfx[1*64+2] != 1 {
+		t.Error("start position should wrap")
+	}
+}
+
+func TestQuirkDisplayWait(t *testing.T) {
+	var c chip8.Chip8
+	c.Init()
+	c.Quirks.DisplayWait = true
+	c.Memory[0x200] = 0xD0
+	c.Memory[0x201] = 0x01
+
+	c.EmulateCycle()
+	if c.Pc != 0x200 {
+		t.Fatal("DXYN should wait for the 60Hz tick")
+	}
+	c.TickTimers()
+	c.EmulateCycle()
+	if c.Pc != 0x202 || !c.Draw_flag {
+		t.Error("DXYN should draw after the 60Hz tick")
+	}
+}
+
+func TestSetQuirkProfile(t *testing.T) {
+	var c chip8.Chip8
+	c.Init()
+	if err := c.LoadGame("../assets/invaders.c8"); err != nil {
+		t.Fatal(err)
+	}
+	name, err := c.SetQuirkProfile("auto")
+	if err != nil || name != "chip48" || c.Quirks != chip8.QuirksChip48 {
+		t.Errorf("invaders.c8 should pick chip48, got %q %v", name, err)
+	}
+	if name, _ := c.SetQuirkProfile("vip"); name != "vip" || c.Quirks != chip8.QuirksVIP {
+		t.Errorf("explicit profile not applied, got %q", name)
+	}
+	if _, err := c.SetQuirkProfile("nope"); err == nil {
+		t.Error("unknown profile should fail")
+	}
+
+	c.Init()
+	c.LoadBytes("homebrew", []byte{0x12, 0x00})
+	if name, _ := c.SetQuirkProfile(""); name != chip8.DefaultProfile {
+		t.Errorf("unknown ROM should use the default profile, got %q", name)
+	}
+}
@@ -0,0 +1,101 @@
+package chip8
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// RandomSource supplies the random bytes CXNN masks with NN.
+type RandomSource interface {
+	Byte() byte
+}
+
+// SeededSource is a math/rand generator, the same seed always giving the
+// same bytes.
+type SeededSource struct {
+	rng *rand.Rand
+}
+
+// NewSeededSource makes a generator seeded with seed.
+func NewSeededSource(seed int64) *SeededSource {
+	return &SeededSource{rand.New(rand.NewSource(seed))}
+}
+
+// Byte returns any value from 0x00 to 0xFF with equal chance.
+func (self *SeededSource) Byte() byte {
+	return byte(self.rng.Intn(256))
+}
+
+// VIPSource makes numbers the way the COSMAC VIP interpreter did: each call
+// moves a pointer on through a page of memory and adds the byte there to a
+// running total, which is the result. The VIP read its own interpreter code
+// and also moved the pointer on every 60Hz interrupt. This interpreter has
+// no code in memory, so Page starts out as the fonts, and the pointer only
+// moves per call so the numbers don't depend on timing.
+type VIPSource struct {
+	Page  [256]byte
+	index byte
+	total byte
+}
+
+// NewVIPSource starts the running total at seed.
+func NewVIPSource(seed byte) *VIPSource {
+	self := &VIPSource{total: seed}
+	n := copy(self.Page[:], Chip8_fontset[:])
+	copy(self.Page[n:], Chip8_bigfontset[:])
+	return self
+}
+
+func (self *VIPSource) Byte() byte {
+	self.index++
+	self.total += self.Page[self.index]
+	return self.total
+}
+
+// ScriptedSource returns Bytes in order, starting over after the last, so
+// tests can know exactly what CXNN will get.
+type ScriptedSource struct {
+	Bytes []byte
+	next  int
+}
+
+// NewScriptedSource makes a source returning bytes.
+func NewScriptedSource(bytes ...byte) *ScriptedSource {
+	return &ScriptedSource{Bytes: bytes}
+}
+
+func (self *ScriptedSource) Byte() byte {
+	if len(self.Bytes) == 0 {
+		return 0
+	}
+	b := self.Bytes[self.next%len(self.Bytes)]
+	self.next++
+	return b
+}
+
+// NewRandomSource makes a source by name, "seeded" for a SeededSource or
+// "vip" for a VIPSource, started from seed.
+func NewRandomSource(name string, seed int64) (RandomSource, error) {
+	switch name {
+	case "seeded":
+		return NewSeededSource(seed), nil
+	case "vip":
+		return NewVIPSource(byte(seed)), nil
+	}
+	return nil, fmt.Errorf("chip8: unknown random source %q, use seeded or vip", name)
+}
+
+// SeedRandom sets Random to a SeededSource.
+func (self *Chip8) SeedRandom(seed int64) {
+	self.Random = NewSeededSource(seed)
+}
+
+// The random number for CXNN. Without a Random source one seeded from the
+// clock is made on first use.
+func (self *Chip8) random() byte {
+	if self.Random == nil {
+		self.SeedRandom(time.Now().UnixNano())
+	}
+	return self.Random.Byte()
+}
@@ -0,0 +1,64 @@
+package chip8_test
+
+import (
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+func TestSeedRandom(t *testing.T) {
+	// CXFF repeated, a new random byte in VX each instruction
+	run := func(seed int64) []byte {
+		var c chip8.Chip8
+		c.Init()
+		for i := 0; i < 16; i++ {
+			c.Memory[0x200+2*i] = 0xC0 | byte(i)
+			c.Memory[0x201+2*i] = 0xFF
+		}
+		c.SeedRandom(seed)
+		for i := 0; i < 16; i++ {
+			c.EmulateCycle()
+		}
+		return c.V[:]
+	}
+	if a, b := run(7), run(7); string(a) != string(b) {
+		t.Errorf("same seed gave %X and %X", a, b)
+	}
+	if a, b := run(7), run(8); string(a) == string(b) {
+		t.Errorf("different seeds both gave %X", a)
+	}
+}
+
+func TestSeededSourceCoversEveryByte(t *testing.T) {
+	src := chip8.NewSeededSource(1)
+	var seen [256]bool
+	for i := 0; i < 10000; i++ {
+		seen[src.Byte()] = true
+	}
+	for b, ok := range seen {
+		if !ok {
+			t.Errorf("0x%02X never came up", b)
+		}
+	}
+}
+
+func TestVIPSource(t *testing.T) {
+	// The running total adds 0x90, 0x90 then 0x90 from the font for "0"
+	src := chip8.NewVIPSource(0x05)
+	for i, want := range []byte{0x95, 0x25, 0xB5} {
+		if got := src.Byte(); got != want {
+			t.Errorf("byte %d: got 0x%02X, want 0x%02X", i, got, want)
+		}
+	}
+}
+
+func TestScriptedSource(t *testing.T) {
+	src := chip8.NewScriptedSource(1, 2, 3)
+	var got []byte
+	for i := 0; i < 5; i++ {
+		got = append(got, src.Byte())
+	}
+	if string(got) != "\x01\x02\x03\x01\x02" {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,112 @@
+package chip8
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Programs are loaded at 0x200, straight after the interpreter area.
+const RomStart = 0x200
+
+// MaxRomSize is the largest ROM that fits between RomStart and the end of
+// Memory. Only XO-CHIP ROMs go past 0xFFF, SetQuirkProfile holds the others
+// to MaxClassicRomSize.
+const MaxRomSize = MemorySize - RomStart
+
+// MaxClassicRomSize is the largest ROM the 4K machines, CHIP-8 and
+// SUPER-CHIP, can hold.
+const MaxClassicRomSize = 0x1000 - RomStart
+
+// Reasons a ROM can fail to load. Returned errors wrap one of these so
+// callers can test them with errors.Is.
+var (
+	ErrRomNotFound   = errors.New("rom not found")
+	ErrRomEmpty      = errors.New("rom is empty")
+	ErrRomTooLarge   = errors.New("rom is too large")
+	ErrRomUnreadable = errors.New("rom could not be read")
+)
+
+// RomError describes a failed ROM load.
+type RomError struct {
+	Name  string // Path or name the ROM was loaded from
+	Size  int    // Bytes read before failing, if any
+	Max   int    // The size limit for ErrRomTooLarge, MaxRomSize if 0
+	Err   error  // One of the ErrRom* values
+	Cause error  // Underlying io or fs error, if any
+}
+
+func (self *RomError) Error() string {
+	msg := fmt.Sprintf("chip8: loading %q: %v", self.Name, self.Err)
+	if self.Err == ErrRomTooLarge {
+		max := self.Max
+		if max == 0 {
+			max = MaxRomSize
+		}
+		msg += fmt.Sprintf(" (%d bytes, max %d)", self.Size, max)
+	}
+	if self.Cause != nil {
+		msg += ": " + self.Cause.Error()
+	}
+	return msg
+}
+
+func (self *RomError) Unwrap() error {
+	return self.Err
+}
+
+// LoadGame reads the ROM at path on the local filesystem into Memory.
+func (self *Chip8) LoadGame(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return openError(path, err)
+	}
+	defer f.Close()
+	return self.LoadReader(path, f)
+}
+
+// LoadFS reads the ROM called name from fsys into Memory.
+func (self *Chip8) LoadFS(fsys fs.FS, name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return openError(name, err)
+	}
+	defer f.Close()
+	return self.LoadReader(name, f)
+}
+
+// LoadReader reads a whole ROM from r into Memory. name is only used for
+// error reporting.
+func (self *Chip8) LoadReader(name string, r io.Reader) error {
+	rom, err := io.ReadAll(r)
+	if err != nil {
+		return &RomError{Name: name, Size: len(rom), Err: ErrRomUnreadable, Cause: err}
+	}
+	return self.LoadBytes(name, rom)
+}
+
+// LoadBytes copies rom into Memory starting at RomStart. ROMs up to
+// MaxRomSize load, SetQuirkProfile checks they fit the machine.
+func (self *Chip8) LoadBytes(name string, rom []byte) error {
+	if len(rom) == 0 {
+		return &RomError{Name: name, Err: ErrRomEmpty}
+	}
+	if len(rom) > MaxRomSize {
+		return &RomError{Name: name, Size: len(rom), Err: ErrRomTooLarge}
+	}
+	copy(self.Memory[RomStart:], rom)
+	self.RomName = name
+	self.RomHash = sha1.Sum(rom)
+	self.RomSize = len(rom)
+	return nil
+}
+
+func openError(name string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return &RomError{Name: name, Err: ErrRomNotFound, Cause: err}
+	}
+	return &RomError{Name: name, Err: ErrRomUnreadable, Cause: err}
+}
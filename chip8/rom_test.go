@@ -0,0 +1,107 @@
+package chip8_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+func TestLoadGameFromPath(t *testing.T) {
+	var c chip8.Chip8
+	c.Init()
+	if err := c.LoadGame("../assets/brix.c8"); err != nil {
+		t.Fatal(err)
+	}
+	rom, _ := os.ReadFile("../assets/brix.c8")
+	if !bytes.Equal(c.Memory[chip8.RomStart:chip8.RomStart+len(rom)], rom) {
+		t.Error("ROM was not copied to 0x200")
+	}
+}
+
+func TestLoadGameNotFound(t *testing.T) {
+	var c chip8.Chip8
+	c.Init()
+	err := c.LoadGame("../assets/missing.c8")
+	if !errors.Is(err, chip8.ErrRomNotFound) {
+		t.Errorf("expected ErrRomNotFound, got %v", err)
+	}
+	var romErr *chip8.RomError
+	if !errors.As(err, &romErr) || romErr.Name != "../assets/missing.c8" {
+		t.Errorf("expected a RomError naming the file, got %#v", err)
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"roms/tiny.c8":  {Data: []byte{0x12, 0x00}},
+		"roms/empty.c8": {Data: []byte{}},
+	}
+	var c chip8.Chip8
+	c.Init()
+	if err := c.LoadFS(fsys, "roms/tiny.c8"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Memory[0x200] != 0x12 || c.Memory[0x201] != 0x00 {
+		t.Error("ROM was not copied to 0x200")
+	}
+	if err := c.LoadFS(fsys, "roms/empty.c8"); !errors.Is(err, chip8.ErrRomEmpty) {
+		t.Errorf("expected ErrRomEmpty, got %v", err)
+	}
+	if err := c.LoadFS(fsys, "roms/nope.c8"); !errors.Is(err, chip8.ErrRomNotFound) {
+		t.Errorf("expected ErrRomNotFound, got %v", err)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("disk on fire")
+}
+
+func TestLoadReaderUnreadable(t *testing.T) {
+	var c chip8.Chip8
+	c.Init()
+	err := c.LoadReader("broken", failingReader{})
+	if !errors.Is(err, chip8.ErrRomUnreadable) {
+		t.Errorf("expected ErrRomUnreadable, got %v", err)
+	}
+}
+
+func TestLoadBytesTooLarge(t *testing.T) {
+	var c chip8.Chip8
+	c.Init()
+	if err := c.LoadBytes("max", make([]byte, chip8.MaxRomSize)); err != nil {
+		t.Errorf("a ROM of exactly MaxRomSize should load, got %v", err)
+	}
+	err := c.LoadBytes("huge", make([]byte, chip8.MaxRomSize+1))
+	if !errors.Is(err, chip8.ErrRomTooLarge) {
+		t.Errorf("expected ErrRomTooLarge, got %v", err)
+	}
+}
+
+// ROMs past 0xFFF only fit the XO-CHIP machine
+func TestRomTooLargeForProfile(t *testing.T) {
+	var c chip8.Chip8
+	c.Init()
+	if err := c.LoadBytes("big", make([]byte, chip8.MaxClassicRomSize+1)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.SetQuirkProfile("vip")
+	var romErr *chip8.RomError
+	if !errors.As(err, &romErr) || romErr.Err != chip8.ErrRomTooLarge || romErr.Max != chip8.MaxClassicRomSize {
+		t.Errorf("vip: got %v", err)
+	}
+	if c.Quirks != (chip8.Quirks{}) {
+		t.Error("a failed SetQuirkProfile should leave Quirks alone")
+	}
+	if _, err := c.SetQuirkProfile("xochip"); err != nil {
+		t.Errorf("xochip: %v", err)
+	}
+	if name, err := c.SetQuirkProfile("auto"); name != "xochip" || err != nil {
+		t.Errorf("auto picked %q for an unlisted large ROM, %v", name, err)
+	}
+}
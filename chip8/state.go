@@ -0,0 +1,346 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Save states are a header, a sequence of chunks and a checksum:
+//
+//	magic    [4]byte  "C8SS"
+//	version  uint16   format version that wrote the state, StateVersion
+//	compat   uint16   oldest format version able to read it
+//	chunks            each a 4 byte tag, a uint32 length and length bytes
+//	"END "            chunk holding the uint32 CRC-32 (IEEE) of every byte
+//	                  before it
+//
+// Integers are big endian. Readers skip chunks with tags they don't know, so
+// later versions can add chunks and still be read by this one. compat is only
+// raised for changes that can't be made that way.
+//
+// Version 1 chunks, of which CPU, MEM and GFX are required:
+//
+//	"ROM "  RomHash [20]byte, uint16 name length, RomName
+//	"CPU "  V [16]byte, Pc, Opcode, Index, Sp uint16, Stack [16]uint16,
+//	        Delay_timer, Sound_timer, Plane, Pitch byte, a flags byte (bit 0
+//	        Halted, 1 HiRes, 2 Draw_flag, 3 PatternLoaded, 4 waiting for the
+//	        60Hz tick, 5 Buzzing from version 5), Rpl, Pattern, Key [16]byte
+//	"CONF"  Quirks as a uint32 (bit 0 ShiftUsesVY, 1 LoadStoreIncrementsI,
+//	        2 JumpUsesVX, 3 VFReset, 4 SpriteWrap, 5 DisplayWait, 6 XOChip
+//	        from version 3), CyclesPerFrame uint32
+//	"MEM "  Memory, MemorySize bytes
+//	"GFX "  Gfx, Width()*Height() bytes at the resolution given in CPU
+//
+// Version 2 adds:
+//
+//	"CLK "  Frame uint64
+//
+// Version 3 adds bit 6 of the quirks in CONF, earlier versions load with
+// XOChip false, and:
+//
+//	"LEN "  RomSize uint32
+//
+// Version 4 adds, only when the machine has faulted:
+//
+//	"FLT "  Fault.Pc, Fault.Opcode uint16, the reason as a byte, its index
+//	        in faultReasons
+//
+// Version 5 adds bit 5 of the CPU flags, earlier versions load with Buzzing
+// false.
+//
+// The random number generator isn't saved, CXNN carries on with the one the
+// machine loading the state already has. Older states saved after a fault
+// load halted without one.
+const (
+	StateVersion = 5
+	stateCompat  = 1
+	stateMagic   = "C8SS"
+	maxStateSize = 1 << 20
+)
+
+// Reasons a state can fail to load, wrapped by the returned errors.
+var (
+	ErrStateInvalid = errors.New("not a save state")
+	ErrStateVersion = errors.New("save state needs a newer version")
+	ErrStateCorrupt = errors.New("save state is corrupt")
+)
+
+const (
+	stateHalted = 1 << iota
+	stateHiRes
+	stateDrawFlag
+	statePatternLoaded
+	stateVblank
+	stateBuzzing
+)
+
+// SaveState writes the complete machine state, enough for LoadState to carry
+// on exactly where it left off.
+func (self *Chip8) SaveState(w io.Writer) error {
+	var out bytes.Buffer
+	out.WriteString(stateMagic)
+	binary.Write(&out, binary.BigEndian, [2]uint16{StateVersion, stateCompat})
+
+	var chunk bytes.Buffer
+	put := func(v interface{}) {
+		binary.Write(&chunk, binary.BigEndian, v)
+	}
+	end := func(tag string) {
+		out.WriteString(tag)
+		binary.Write(&out, binary.BigEndian, uint32(chunk.Len()))
+		chunk.WriteTo(&out)
+	}
+
+	put(self.RomHash)
+	put(uint16(len(self.RomName)))
+	chunk.WriteString(self.RomName)
+	end("ROM ")
+
+	var flags byte
+	for bit, set := range map[byte]bool{
+		stateHalted:        self.Halted,
+		stateBuzzing:       self.Buzzing,
+		stateHiRes:         self.HiRes,
+		stateDrawFlag:      self.Draw_flag,
+		statePatternLoaded: self.PatternLoaded,
+		stateVblank:        self.vblank,
+	} {
+		if set {
+			flags |= bit
+		}
+	}
+	put(self.V)
+	put([]uint16{self.Pc, self.Opcode, self.Index, self.Sp})
+	put(self.Stack)
+	put([]byte{self.Delay_timer, self.Sound_timer, self.Plane, self.Pitch, flags})
+	put(self.Rpl)
+	put(self.Pattern)
+	put(self.Key)
+	end("CPU ")
+
+	put(self.Quirks.Bits())
+	put(uint32(self.CyclesPerFrame))
+	end("CONF")
+
+	put(self.Frame)
+	end("CLK ")
+
+	put(uint32(self.RomSize))
+	end("LEN ")
+
+	if self.Fault != nil {
+		put([2]uint16{self.Fault.Pc, self.Fault.Opcode})
+		put(faultReason(self.Fault.Err))
+		end("FLT ")
+	}
+
+	chunk.Write(self.Memory[:])
+	end("MEM ")
+
+	chunk.Write(self.Gfx)
+	end("GFX ")
+
+	put(crc32.ChecksumIEEE(out.Bytes()))
+	end("END ")
+
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// LoadState restores a state written by SaveState. Nothing is changed unless
+// the whole state is valid. OnMemory is kept.
+func (self *Chip8) LoadState(r io.Reader) error {
+	data, err := io.ReadAll(io.LimitReader(r, maxStateSize+1))
+	if err != nil {
+		return fmt.Errorf("chip8: loading state: %w", err)
+	}
+	if len(data) > maxStateSize {
+		return stateError(ErrStateCorrupt, "larger than %d bytes", maxStateSize)
+	}
+	if len(data) < 8 || string(data[:4]) != stateMagic {
+		return stateError(ErrStateInvalid, "")
+	}
+	version := binary.BigEndian.Uint16(data[4:])
+	compat := binary.BigEndian.Uint16(data[6:])
+	if compat > StateVersion {
+		return stateError(ErrStateVersion, "format %d, this reads up to %d", version, StateVersion)
+	}
+
+	chunks := map[string][]byte{}
+	for pos := 8; ; {
+		if len(data)-pos < 8 {
+			return stateError(ErrStateCorrupt, "truncated")
+		}
+		tag := string(data[pos : pos+4])
+		size := int(binary.BigEndian.Uint32(data[pos+4:]))
+		body := data[pos+8:]
+		if size > len(body) {
+			return stateError(ErrStateCorrupt, "%q chunk is truncated", tag)
+		}
+		if tag == "END " {
+			if size != 4 || binary.BigEndian.Uint32(body) != crc32.ChecksumIEEE(data[:pos]) {
+				return stateError(ErrStateCorrupt, "checksum mismatch")
+			}
+			break
+		}
+		chunks[tag] = body[:size]
+		pos += 8 + size
+	}
+
+	state := *self
+	if err := state.restore(chunks); err != nil {
+		return err
+	}
+	*self = state
+	return nil
+}
+
+func stateError(reason error, format string, args ...interface{}) error {
+	if format == "" {
+		return fmt.Errorf("chip8: loading state: %w", reason)
+	}
+	return fmt.Errorf("chip8: loading state: %w: %s", reason, fmt.Sprintf(format, args...))
+}
+
+// Fills in the machine from the chunks of a checked state.
+func (self *Chip8) restore(chunks map[string][]byte) error {
+	for _, tag := range []string{"CPU ", "MEM ", "GFX "} {
+		if _, ok := chunks[tag]; !ok {
+			return stateError(ErrStateCorrupt, "no %q chunk", tag)
+		}
+	}
+	read := func(tag string, v ...interface{}) error {
+		r := bytes.NewReader(chunks[tag])
+		for _, field := range v {
+			if err := binary.Read(r, binary.BigEndian, field); err != nil {
+				return stateError(ErrStateCorrupt, "%q chunk is too short", tag)
+			}
+		}
+		return nil
+	}
+
+	var regs [4]uint16
+	var misc [5]byte
+	err := read("CPU ", &self.V, &regs, &self.Stack, &misc, &self.Rpl, &self.Pattern, &self.Key)
+	if err != nil {
+		return err
+	}
+	self.Pc, self.Opcode, self.Index, self.Sp = regs[0], regs[1], regs[2], regs[3]
+	if int(self.Sp) > len(self.Stack) {
+		return stateError(ErrStateCorrupt, "stack pointer %d out of range", self.Sp)
+	}
+	self.Delay_timer, self.Sound_timer, self.Plane, self.Pitch = misc[0], misc[1], misc[2], misc[3]
+	flags := misc[4]
+	self.Halted = flags&stateHalted != 0
+	self.Fault = nil
+	self.Draw_flag = flags&stateDrawFlag != 0
+	self.PatternLoaded = flags&statePatternLoaded != 0
+	self.vblank = flags&stateVblank != 0
+	self.inFrame = 0
+	self.Buzzing = flags&stateBuzzing != 0
+
+	if len(chunks["MEM "]) != MemorySize {
+		return stateError(ErrStateCorrupt, "memory is %d bytes", len(chunks["MEM "]))
+	}
+	copy(self.Memory[:], chunks["MEM "])
+
+	self.HiRes = flags&stateHiRes != 0
+	size := LoResWidth * LoResHeight
+	if self.HiRes {
+		size = HiResWidth * HiResHeight
+	}
+	if len(chunks["GFX "]) != size {
+		return stateError(ErrStateCorrupt, "display is %d bytes, expected %d", len(chunks["GFX "]), size)
+	}
+	self.Gfx = append([]byte(nil), chunks["GFX "]...)
+
+	if rom, ok := chunks["ROM "]; ok {
+		var n uint16
+		if err := read("ROM ", &self.RomHash, &n); err != nil {
+			return err
+		}
+		if len(rom) < len(self.RomHash)+2+int(n) {
+			return stateError(ErrStateCorrupt, "\"ROM \" chunk is too short")
+		}
+		self.RomName = string(rom[len(self.RomHash)+2 : len(self.RomHash)+2+int(n)])
+	}
+	if _, ok := chunks["CONF"]; ok {
+		var quirks, cycles uint32
+		if err := read("CONF", &quirks, &cycles); err != nil {
+			return err
+		}
+		self.Quirks = QuirksFromBits(quirks)
+		self.CyclesPerFrame = int(cycles)
+	}
+	self.Frame = 0
+	if _, ok := chunks["CLK "]; ok {
+		if err := read("CLK ", &self.Frame); err != nil {
+			return err
+		}
+	}
+	self.RomSize = 0
+	if _, ok := chunks["LEN "]; ok {
+		var size uint32
+		if err := read("LEN ", &size); err != nil {
+			return err
+		}
+		self.RomSize = int(size)
+	}
+	if _, ok := chunks["FLT "]; ok {
+		var at [2]uint16
+		var reason byte
+		if err := read("FLT ", &at, &reason); err != nil {
+			return err
+		}
+		if int(reason) >= len(faultReasons) {
+			return stateError(ErrStateCorrupt, "unknown fault reason %d", reason)
+		}
+		self.Fault = &OpcodeError{Pc: at[0], Opcode: at[1], Err: faultReasons[reason]}
+	}
+	return nil
+}
+
+// The errors a Fault can wrap, by the byte that stands for them in the
+// "FLT " chunk. New ones go on the end.
+var faultReasons = []error{ErrUnknownOpcode, ErrMachineCode, ErrStackOverflow, ErrStackUnderflow}
+
+func faultReason(err error) byte {
+	for i, reason := range faultReasons {
+		if err == reason {
+			return byte(i)
+		}
+	}
+	return 0
+}
+
+// Bits packs the quirks into the bit mask save states and movies store, bit 0
+// ShiftUsesVY to bit 6 XOChip in field order. New quirks take the next
+// bit so stored masks keep their meaning.
+func (self Quirks) Bits() uint32 {
+	var bits uint32
+	for i, set := range []bool{self.ShiftUsesVY, self.LoadStoreIncrementsI, self.JumpUsesVX,
+		self.VFReset, self.SpriteWrap, self.DisplayWait, self.XOChip} {
+		if set {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits
+}
+
+// QuirksFromBits unpacks a mask made by Bits.
+func QuirksFromBits(bits uint32) Quirks {
+	set := func(i uint) bool { return bits&(1<<i) != 0 }
+	return Quirks{
+		ShiftUsesVY:          set(0),
+		LoadStoreIncrementsI: set(1),
+		JumpUsesVX:           set(2),
+		VFReset:              set(3),
+		SpriteWrap:           set(4),
+		DisplayWait:          set(5),
+		XOChip:               set(6),
+	}
+}
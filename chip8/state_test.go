@@ -0,0 +1,140 @@
+package chip8_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"reflect"
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+func runningGame(t *testing.T) *chip8.Chip8 {
+	t.Helper()
+	c := &chip8.Chip8{}
+	c.Init()
+	if err := c.LoadGame("../assets/brix.c8"); err != nil {
+		t.Fatal(err)
+	}
+	c.SetQuirkProfile("auto")
+	for i := 0; i < 100; i++ {
+		c.RunFrame()
+	}
+	c.Key[4] = 1
+	return c
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	c := runningGame(t)
+	var buf bytes.Buffer
+	if err := c.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var restored chip8.Chip8
+	restored.Init()
+	restored.Random = c.Random // Not part of the state
+	if err := restored.LoadState(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*c, restored) {
+		t.Fatal("restored machine differs from the saved one")
+	}
+
+	// Both carry on identically given the same random numbers
+	for _, m := range []*chip8.Chip8{c, &restored} {
+		m.SeedRandom(1)
+		for i := 0; i < 100; i++ {
+			m.RunFrame()
+		}
+	}
+	if !reflect.DeepEqual(*c, restored) {
+		t.Error("machines diverged after loading the state")
+	}
+}
+
+func TestStateIntegrity(t *testing.T) {
+	c := runningGame(t)
+	var buf bytes.Buffer
+	c.SaveState(&buf)
+	good := buf.Bytes()
+
+	corrupt := append([]byte(nil), good...)
+	corrupt[len(corrupt)/2] ^= 0xFF
+	newer := append([]byte(nil), good...)
+	binary.BigEndian.PutUint16(newer[6:], chip8.StateVersion+1)
+
+	cases := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, chip8.ErrStateInvalid},
+		{"rom", []byte("not a state at all"), chip8.ErrStateInvalid},
+		{"flipped byte", corrupt, chip8.ErrStateCorrupt},
+		{"truncated", good[:len(good)-10], chip8.ErrStateCorrupt},
+		{"newer", newer, chip8.ErrStateVersion},
+	}
+	for _, tc := range cases {
+		before := *c
+		err := c.LoadState(bytes.NewReader(tc.data))
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.err)
+		}
+		if !reflect.DeepEqual(before, *c) {
+			t.Errorf("%s: failed load changed the machine", tc.name)
+		}
+	}
+}
+
+func TestStateSkipsUnknownChunks(t *testing.T) {
+	c := runningGame(t)
+	var buf bytes.Buffer
+	c.SaveState(&buf)
+	data := buf.Bytes()
+
+	// Insert a chunk from some later version before the checksum
+	end := len(data) - 12
+	var state bytes.Buffer
+	state.Write(data[:end])
+	state.WriteString("NEW!")
+	binary.Write(&state, binary.BigEndian, uint32(3))
+	state.Write([]byte{1, 2, 3})
+	sum := crc32.ChecksumIEEE(state.Bytes())
+	state.WriteString("END ")
+	binary.Write(&state, binary.BigEndian, []uint32{4, sum})
+
+	var restored chip8.Chip8
+	if err := restored.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Pc != c.Pc || restored.RomName != c.RomName {
+		t.Error("state with an unknown chunk was not restored")
+	}
+}
+
+// A faulted machine loads still showing the fault
+func TestStateKeepsFault(t *testing.T) {
+	c := schipProgram(0x00, 0xEE) // Return with an empty stack
+	c.EmulateCycle()
+	if c.Fault == nil {
+		t.Fatal("00EE with an empty stack should fault")
+	}
+	var buf bytes.Buffer
+	if err := c.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var restored chip8.Chip8
+	restored.Init()
+	if err := restored.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !restored.Halted || restored.Fault == nil || *restored.Fault != *c.Fault {
+		t.Errorf("restored Halted=%v Fault=%v, saved %v", restored.Halted, restored.Fault, c.Fault)
+	}
+	if !errors.Is(restored.Fault, chip8.ErrStackUnderflow) {
+		t.Errorf("restored fault %v should be a stack underflow", restored.Fault)
+	}
+}
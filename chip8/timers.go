@@ -0,0 +1,66 @@
+package chip8
+
+// The delay and sound timers count down at 60Hz whatever speed the CPU runs
+// at, so the machine is driven a frame at a time.
+const FrameRate = 60
+
+// DefaultCyclesPerFrame is used when CyclesPerFrame is 0, 600 instructions a
+// second.
+const DefaultCyclesPerFrame = 10
+
+// TickTimers is the 60Hz interrupt. It counts the timers down, noting in
+// Buzzing whether the sound timer ran this frame, advances Frame, starts
+// Step's count of instructions in the frame again and lets a DXYN that is
+// waiting because of Quirks.DisplayWait draw.
+func (self *Chip8) TickTimers() {
+	self.inFrame = 0
+	if self.Delay_timer > 0 {
+		self.Delay_timer--
+	}
+	self.Buzzing = self.Sound_timer > 0
+	if self.Sound_timer > 0 {
+		self.Sound_timer--
+	}
+	self.vblank = true
+	self.Frame++
+}
+
+// RunFrame runs one 60th of a second: CyclesPerFrame instructions followed by
+// a timer tick. Returns the first error EmulateCycle reported, the frame
+// stops early only if the machine halted.
+func (self *Chip8) RunFrame() error {
+	cycles := self.PerFrame()
+	var first error
+	for i := 0; i < cycles && !self.Halted; i++ {
+		if err := self.EmulateCycle(); err != nil && first == nil {
+			first = err
+		}
+	}
+	self.TickTimers()
+	if first == nil {
+		first = self.faultError()
+	}
+	return first
+}
+
+// PerFrame is the number of instructions run each frame, CyclesPerFrame or
+// DefaultCyclesPerFrame if that is 0.
+func (self *Chip8) PerFrame() int {
+	if self.CyclesPerFrame <= 0 {
+		return DefaultCyclesPerFrame
+	}
+	return self.CyclesPerFrame
+}
+
+// Step runs a single instruction, ticking the timers after every PerFrame
+// instructions the way RunFrame does, for single stepping and comparing
+// machines an instruction at a time. Returns the error EmulateCycle
+// reported.
+func (self *Chip8) Step() error {
+	err := self.EmulateCycle()
+	self.inFrame++
+	if self.inFrame >= self.PerFrame() {
+		self.TickTimers()
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package chip8_test
+
+import (
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+func TestTimersTickOncePerFrame(t *testing.T) {
+	// 1200: an endless loop, so every cycle is the same jump
+	c := schipProgram(0x12, 0x00)
+	c.Delay_timer = 10
+	c.Sound_timer = 1
+
+	for i := 0; i < 25; i++ {
+		c.EmulateCycle()
+	}
+	if c.Delay_timer != 10 || c.Sound_timer != 1 {
+		t.Error("timers should not count down per instruction")
+	}
+
+	c.RunFrame()
+	if c.Delay_timer != 9 || c.Sound_timer != 0 {
+		t.Errorf("one frame should tick once, DT=%d ST=%d", c.Delay_timer, c.Sound_timer)
+	}
+	c.RunFrame()
+	if c.Delay_timer != 8 || c.Sound_timer != 0 {
+		t.Errorf("timers should stop at 0, DT=%d ST=%d", c.Delay_timer, c.Sound_timer)
+	}
+}
+
+func TestCyclesPerFrame(t *testing.T) {
+	// 7001 repeated: add 1 to V0 each instruction
+	program := make([]byte, 0, 200)
+	for i := 0; i < 100; i++ {
+		program = append(program, 0x70, 0x01)
+	}
+	c := schipProgram(program...)
+	c.RunFrame()
+	if c.V[0] != chip8.DefaultCyclesPerFrame {
+		t.Errorf("expected %d instructions, ran %d", chip8.DefaultCyclesPerFrame, c.V[0])
+	}
+
+	c = schipProgram(program...)
+	c.CyclesPerFrame = 30
+	c.RunFrame()
+	c.RunFrame()
+	if c.V[0] != 60 {
+		t.Errorf("expected 60 instructions, ran %d", c.V[0])
+	}
+}
+
+func TestFrameCounter(t *testing.T) {
+	c := schipProgram(0x12, 0x00)
+	for i := 0; i < 5; i++ {
+		c.RunFrame()
+	}
+	if c.Frame != 5 {
+		t.Errorf("Frame is %d after 5 frames", c.Frame)
+	}
+	c.Init()
+	if c.Frame != 0 {
+		t.Error("Init should reset Frame")
+	}
+}
+
+func TestStep(t *testing.T) {
+	c := schipProgram(0x12, 0x00)
+	c.CyclesPerFrame = 4
+	if c.PerFrame() != 4 {
+		t.Errorf("PerFrame is %d, want 4", c.PerFrame())
+	}
+	for i := 0; i < 10; i++ {
+		c.Step()
+	}
+	if c.Frame != 2 {
+		t.Errorf("10 steps of 4 a frame ticked %d times, want 2", c.Frame)
+	}
+	// A frame starts the count again
+	c.RunFrame()
+	for i := 0; i < 3; i++ {
+		c.Step()
+	}
+	if c.Frame != 3 {
+		t.Errorf("Frame is %d, 3 steps into a new frame should not tick", c.Frame)
+	}
+
+	c.CyclesPerFrame = 0
+	if c.PerFrame() != chip8.DefaultCyclesPerFrame {
+		t.Errorf("PerFrame is %d with CyclesPerFrame 0", c.PerFrame())
+	}
+}
@@ -0,0 +1,34 @@
+package chip8
+
+import "math"
+
+// MemorySize is the XO-CHIP 64KiB address space. Plain CHIP-8 programs only
+// use the first 4KiB.
+const MemorySize = 0x10000
+
+// The XO-CHIP display has two bitplanes. Each Gfx byte holds one bit per
+// plane, so a pixel is one of four colours: 0 (off), Plane1, Plane2 or both.
+const (
+	Plane1    = 1
+	Plane2    = 2
+	AllPlanes = Plane1 | Plane2
+)
+
+// Default playback pitch set by Init, giving a 4000Hz pattern rate.
+const DefaultPitch = 64
+
+// Skips the next instruction, which is 4 bytes long if it is the XO-CHIP
+// F000 NNNN long index load and XO-CHIP is on.
+func (self *Chip8) skip() {
+	self.Pc += 2
+	if self.Quirks.XOChip && self.Memory[self.Pc] == 0xF0 && self.Memory[self.Pc+1] == 0x00 {
+		self.Pc += 2
+	}
+	self.Pc += 2
+}
+
+// PatternRate is the rate in bits per second the 128 bit audio Pattern is
+// played back at, from the XO-CHIP pitch register.
+func (self *Chip8) PatternRate() float64 {
+	return 4000 * math.Pow(2, (float64(self.Pitch)-64)/48)
+}
@@ -0,0 +1,139 @@
+package chip8_test
+
+import (
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+// Loads a program at 0x200 on a fresh machine with XO-CHIP on.
+func xochipProgram(program ...byte) *chip8.Chip8 {
+	c := schipProgram(program...)
+	c.Quirks.XOChip = true
+	return c
+}
+
+func TestOpCodeF000NNNN(t *testing.T) {
+	c := xochipProgram(0xF0, 0x00, 0xBE, 0xEF)
+	c.EmulateCycle()
+	if c.Index != 0xBEEF || c.Pc != 0x204 {
+		t.Errorf("I=%04X PC=%04X", c.Index, c.Pc)
+	}
+}
+
+func TestSkipOverLongLoad(t *testing.T) {
+	// 3000 skips the 4 byte F000 NNNN
+	c := xochipProgram(0x30, 0x00, 0xF0, 0x00, 0x12, 0x34)
+	c.EmulateCycle()
+	if c.Pc != 0x206 {
+		t.Errorf("skip should step over F000 NNNN, PC=%04X", c.Pc)
+	}
+}
+
+func TestOpCode5XY2And5XY3(t *testing.T) {
+	// I = 0x300, save V1-V3, save V3-V1 at 0x310, load V5-V7 from 0x300
+	c := xochipProgram(0xA3, 0x00, 0x51, 0x32, 0xA3, 0x10, 0x53, 0x12, 0xA3, 0x00, 0x55, 0x73)
+	c.V[1], c.V[2], c.V[3] = 1, 2, 3
+	for i := 0; i < 6; i++ {
+		c.EmulateCycle()
+	}
+	if c.Memory[0x300] != 1 || c.Memory[0x302] != 3 || c.Memory[0x303] != 0 {
+		t.Errorf("5132 stored %v", c.Memory[0x300:0x304])
+	}
+	if c.Memory[0x310] != 3 || c.Memory[0x312] != 1 {
+		t.Errorf("5312 stored %v", c.Memory[0x310:0x313])
+	}
+	if c.V[5] != 1 || c.V[6] != 2 || c.V[7] != 3 || c.Index != 0x300 {
+		t.Errorf("5573 loaded %v, I=%04X", c.V[5:8], c.Index)
+	}
+}
+
+// Without XO-CHIP, 5XY2 is a 5XY0 skip and leaves memory alone
+func TestOpCode5XY2Classic(t *testing.T) {
+	c := schipProgram(0xA3, 0x00, 0x51, 0x22)
+	c.Quirks = chip8.QuirksVIP
+	c.V[1], c.V[2] = 7, 7
+	c.EmulateCycle()
+	c.EmulateCycle()
+	if c.Pc != 0x206 || c.Memory[0x300] != 0 {
+		t.Errorf("5122 under VIP: PC=%03X, stored %v", c.Pc, c.Memory[0x300:0x302])
+	}
+}
+
+// Without XO-CHIP, F000 is a 2 byte unknown opcode, skipped over as one
+func TestXOChipOpcodesClassic(t *testing.T) {
+	c := schipProgram(0x30, 0x00, 0xF0, 0x00, 0x12, 0x34)
+	c.EmulateCycle()
+	if c.Pc != 0x204 {
+		t.Errorf("skip stepped over F000 as 4 bytes, PC=%04X", c.Pc)
+	}
+
+	for _, op := range [][2]byte{{0xF0, 0x00}, {0xF3, 0x01}, {0xF0, 0x02}, {0xF4, 0x3A}, {0x00, 0xD3}} {
+		c := schipProgram(op[0], op[1])
+		c.OnInvalid = chip8.SkipInvalid
+		if err := c.EmulateCycle(); err == nil || c.Pc != 0x202 {
+			t.Errorf("%02X%02X ran without XO-CHIP, PC=%04X", op[0], op[1], c.Pc)
+		}
+	}
+}
+
+func TestOpCodeFN01Planes(t *testing.T) {
+	// Select both planes and draw a 1 row sprite at (0,0) from 0x300 (plane 1)
+	// and 0x301 (plane 2), then clear plane 1 only.
+	c := xochipProgram(0xF3, 0x01, 0xA3, 0x00, 0xD0, 0x01, 0xF1, 0x01, 0x00, 0xE0)
+	c.Memory[0x300] = 0xC0
+	c.Memory[0x301] = 0x60
+	for i := 0; i < 3; i++ {
+		c.EmulateCycle()
+	}
+	if c.Gfx[0] != chip8.Plane1 || c.Gfx[1] != chip8.AllPlanes || c.Gfx[2] != chip8.Plane2 {
+		t.Errorf("planes drawn wrong: %v", c.Gfx[:3])
+	}
+	c.EmulateCycle()
+	c.EmulateCycle()
+	if c.Gfx[0] != 0 || c.Gfx[1] != chip8.Plane2 || c.Gfx[2] != chip8.Plane2 {
+		t.Errorf("00E0 should only clear plane 1: %v", c.Gfx[:3])
+	}
+}
+
+func TestOpCodeF002FX3A(t *testing.T) {
+	c := xochipProgram(0xA3, 0x00, 0xF0, 0x02, 0xF4, 0x3A)
+	for i := 0; i < 16; i++ {
+		c.Memory[0x300+i] = byte(i)
+	}
+	c.V[4] = 112
+	if c.PatternRate() != 4000 {
+		t.Errorf("default rate should be 4000, got %v", c.PatternRate())
+	}
+	for i := 0; i < 3; i++ {
+		c.EmulateCycle()
+	}
+	if !c.PatternLoaded || c.Pattern[15] != 15 {
+		t.Error("F002 did not load the pattern")
+	}
+	if c.Pitch != 112 || c.PatternRate() != 8000 {
+		t.Errorf("FX3A pitch %d rate %v", c.Pitch, c.PatternRate())
+	}
+}
+
+func TestExtendedMemoryRom(t *testing.T) {
+	var c chip8.Chip8
+	c.Init()
+	rom := make([]byte, 0x2000)
+	rom[len(rom)-1] = 0xAB
+	if err := c.LoadBytes("big", rom); err != nil {
+		t.Fatal(err)
+	}
+	if c.Memory[0x21FF] != 0xAB {
+		t.Error("large ROM not loaded into extended memory")
+	}
+}
+
+func TestOpCode00DN(t *testing.T) {
+	c := xochipProgram(0x00, 0xD3)
+	c.Gfx[5*64+7] = chip8.Plane1
+	c.EmulateCycle()
+	if c.Gfx[2*64+7] != chip8.Plane1 || c.Gfx[5*64+7] != 0 || c.Pc != 0x202 {
+		t.Error("00D3 should scroll the display up 3 pixels")
+	}
+}
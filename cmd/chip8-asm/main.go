@@ -0,0 +1,67 @@
+// Command chip8-asm assembles Octo source into a ROM.
+//
+//	chip8-asm -sym game.sym game.8o
+//
+// The ROM is written next to the source with a .c8 extension unless -o is
+// given. Errors are reported as file:line:column.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bomer/chip8/asm"
+)
+
+var (
+	out    = flag.String("o", "", "write the ROM to `file` (default: source with .c8 extension)")
+	symOut = flag.String("sym", "", "write the symbol map as JSON to `file`")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("chip8-asm: ")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: chip8-asm [flags] source.8o\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	path := flag.Arg(0)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	prog, err := asm.Assemble(string(src))
+	if err != nil {
+		log.Fatalf("%s:%v", path, err)
+	}
+
+	romPath := *out
+	if romPath == "" {
+		romPath = strings.TrimSuffix(path, filepath.Ext(path)) + ".c8"
+	}
+	if err := os.WriteFile(romPath, prog.Rom, 0644); err != nil {
+		log.Fatal(err)
+	}
+	if *symOut != "" {
+		f, err := os.Create(*symOut)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err := prog.Symbols.WriteTo(f); err != nil {
+			log.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
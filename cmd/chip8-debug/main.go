@@ -0,0 +1,67 @@
+// Command chip8-debug runs a ROM under an interactive debugger.
+//
+//	chip8-debug -sym game.sym game.c8
+//
+// Type help at the (chip8) prompt for the commands. Breakpoints from
+// :breakpoint directives in the symbol file are set on start.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/bomer/chip8/asm"
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/debug"
+)
+
+var (
+	symFile = flag.String("sym", "", "load labels and breakpoints from the chip8-asm symbol `file`")
+	ipf     = flag.Int("ipf", chip8.DefaultCyclesPerFrame, "instructions executed per frame")
+	quirks  = flag.String("quirks", "auto", "quirk profile: auto, vip, chip48, schip or xochip")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("chip8-debug: ")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: chip8-debug [flags] rom.c8\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var c chip8.Chip8
+	c.Init()
+	if err := c.LoadGame(flag.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := c.SetQuirkProfile(*quirks); err != nil {
+		log.Fatal(err)
+	}
+	c.CyclesPerFrame = *ipf
+
+	d := debug.New(&c)
+	if *symFile != "" {
+		f, err := os.Open(*symFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		d.Symbols, err = asm.ReadSymbols(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, addr := range d.Symbols.Breakpoints {
+			d.Breakpoints[addr] = nil
+		}
+	}
+	if err := d.Run(os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
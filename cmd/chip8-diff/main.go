@@ -0,0 +1,237 @@
+// Command chip8-diff runs a ROM on two differently configured machines in
+// lockstep and reports the first instruction after which their registers,
+// memory or display differ, or checks one machine against a trace recorded
+// with -trace.
+//
+//	chip8-diff -a quirks=vip -b quirks=vip,displaywait=false assets/brix.c8
+//	chip8-diff -a quirks=vip -ref brix.jsonl assets/brix.c8
+//
+// A configuration is a comma separated list of settings, applied in order:
+//
+//	quirks=NAME     a quirk profile, or auto to pick one for the ROM
+//	ipf=N           instructions per frame
+//	random=NAME     random numbers for CXNN, seeded or vip
+//	seed=N          random number seed
+//	invalid=NAME    unknown opcode policy, halt, skip or sys
+//	FIELD=BOOL      a chip8.Quirks field by its lower case name, e.g.
+//	                vfreset=true, overriding the profile
+//
+// Both start from the auto quirk profile, the default speed and -seed, or
+// from what -replay's movie was recorded with, and -replay presses the
+// movie's keys on both. The exit status is 0 if the runs agree, 1 if they
+// differ and 2 if something went wrong.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/movie"
+	"github.com/bomer/chip8/trace"
+)
+
+var (
+	configA = flag.String("a", "", "settings for the first machine")
+	configB = flag.String("b", "", "settings for the second machine")
+	ref     = flag.String("ref", "", "compare the first machine against a trace `file` instead of the second")
+	replay  = flag.String("replay", "", "press the keys recorded in a movie `file`")
+	cycles  = flag.Uint64("cycles", 100000, "most instructions to run, a whole -replay by default")
+	seed    = flag.Int64("seed", 1, "random number seed")
+	context = flag.Int("context", trace.DefaultContext, "instructions to show either side of the difference")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("chip8-diff: ")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: chip8-diff [flags] rom.c8\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var m *movie.Movie
+	if *replay != "" {
+		var err error
+		if m, err = readMovie(*replay); err != nil {
+			fail(err)
+		}
+	}
+	a, err := newMachine("a", *configA, m)
+	if err != nil {
+		fail(err)
+	}
+	limit := *cycles
+	if m != nil && !isSet("cycles") {
+		limit = uint64(m.Len()) * uint64(a.Chip.PerFrame())
+	}
+
+	var d *trace.Divergence
+	ran := limit
+	if *ref != "" {
+		f, err := os.Open(*ref)
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		r, err := trace.NewReader(f)
+		if err != nil {
+			fail(err)
+		}
+		if d, ran, err = trace.Replay(a, r, limit, *context); err != nil {
+			fail(err)
+		}
+	} else {
+		b, err := newMachine("b", *configB, m)
+		if err != nil {
+			fail(err)
+		}
+		d = trace.Lockstep(a, b, limit, *context)
+	}
+
+	fmt.Printf("a: %s\n", describe(*configA))
+	if *ref != "" {
+		fmt.Printf("reference: %s\n", *ref)
+	} else {
+		fmt.Printf("b: %s\n", describe(*configB))
+	}
+	if d == nil {
+		fmt.Printf("no differences in %d instructions\n", ran)
+		return
+	}
+	fmt.Println()
+	if _, err := d.WriteTo(os.Stdout); err != nil {
+		fail(err)
+	}
+	os.Exit(1)
+}
+
+// Loads the ROM into a machine set up by settings.
+func newMachine(name, settings string, m *movie.Movie) (*trace.Machine, error) {
+	c := &chip8.Chip8{}
+	c.Init()
+	if err := c.LoadGame(flag.Arg(0)); err != nil {
+		return nil, err
+	}
+	if m != nil {
+		if err := m.Start(c); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := c.SetQuirkProfile("auto"); err != nil {
+			return nil, err
+		}
+		c.SeedRandom(*seed)
+	}
+	if err := configure(c, settings, m); err != nil {
+		return nil, fmt.Errorf("-%s: %v", name, err)
+	}
+	machine := &trace.Machine{Chip: c, Name: name}
+	if m != nil {
+		machine.Input = m
+	}
+	return machine, nil
+}
+
+// Applies a comma separated list of settings to c. random and seed change
+// what m was recorded with, if it isn't nil.
+func configure(c *chip8.Chip8, settings string, m *movie.Movie) error {
+	randomName, randomSeed, reseed := "seeded", *seed, false
+	if m != nil {
+		randomName, randomSeed = m.Random, m.Seed
+	}
+	if settings != "" {
+		for _, setting := range strings.Split(settings, ",") {
+			kv := strings.SplitN(setting, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("setting %q should be name=value", setting)
+			}
+			name, value := strings.ToLower(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+			var err error
+			switch name {
+			case "quirks":
+				_, err = c.SetQuirkProfile(value)
+			case "ipf":
+				c.CyclesPerFrame, err = strconv.Atoi(value)
+				if err == nil && c.CyclesPerFrame <= 0 {
+					err = fmt.Errorf("ipf must be at least 1")
+				}
+			case "random":
+				randomName, reseed = value, true
+			case "seed":
+				randomSeed, err = strconv.ParseInt(value, 0, 64)
+				reseed = true
+			case "invalid":
+				c.OnInvalid, err = chip8.ParseInvalidPolicy(value)
+			default:
+				err = setQuirk(&c.Quirks, name, value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if reseed {
+		source, err := chip8.NewRandomSource(randomName, randomSeed)
+		if err != nil {
+			return err
+		}
+		c.Random = source
+	}
+	return nil
+}
+
+// Sets the chip8.Quirks field whose lower case name is name.
+func setQuirk(q *chip8.Quirks, name, value string) error {
+	v := reflect.ValueOf(q).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if strings.ToLower(v.Type().Field(i).Name) != name {
+			continue
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s=%q should be true or false", name, value)
+		}
+		v.Field(i).SetBool(b)
+		return nil
+	}
+	return fmt.Errorf("unknown setting %q", name)
+}
+
+func describe(settings string) string {
+	if settings == "" {
+		return "defaults"
+	}
+	return settings
+}
+
+func isSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+func readMovie(path string) (*movie.Movie, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return movie.Read(f)
+}
+
+func fail(err error) {
+	log.Print(err)
+	os.Exit(2)
+}
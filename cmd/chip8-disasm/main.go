@@ -0,0 +1,75 @@
+// Command chip8-disasm prints instruction listings for CHIP-8 ROMs.
+//
+//	chip8-disasm assets/brix.c8
+//
+// With no arguments it lists every ROM in assets/.
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/disasm"
+)
+
+var syntaxFlag = flag.String("syntax", "auto", "instruction set: auto, chip8, schip or xochip")
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("chip8-disasm: ")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: chip8-disasm [flags] [rom.c8 ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	roms := flag.Args()
+	if len(roms) == 0 {
+		var err error
+		roms, err = filepath.Glob("assets/*.c8")
+		if err != nil || len(roms) == 0 {
+			log.Fatal("no ROMs given and none found in assets/")
+		}
+	}
+
+	for i, path := range roms {
+		rom, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		syntax, err := pickSyntax(rom)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("; %s, %d bytes, %s syntax\n", path, len(rom), syntax)
+		listing := disasm.Disassemble(rom, disasm.Options{Syntax: syntax})
+		if _, err := listing.WriteTo(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// The -syntax flag, or for auto the instruction set of the quirk profile
+// known for the ROM. Unknown ROMs get the full XO-CHIP set.
+func pickSyntax(rom []byte) (disasm.Syntax, error) {
+	if *syntaxFlag != "auto" {
+		return disasm.ParseSyntax(*syntaxFlag)
+	}
+	sum := sha1.Sum(rom)
+	switch chip8.RomProfiles[hex.EncodeToString(sum[:])] {
+	case "vip", "chip48":
+		return disasm.Chip8, nil
+	case "schip":
+		return disasm.SuperChip, nil
+	}
+	return disasm.XOChip, nil
+}
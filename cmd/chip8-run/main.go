@@ -0,0 +1,262 @@
+// Command chip8-run executes a ROM headlessly, without a window or OpenGL,
+// and dumps the final machine state.
+//
+//	chip8-run -frames 600 -gfx - -regs - assets/brix.c8
+//
+// Runs are fully deterministic: there is no wall clock and the random
+// number generator is seeded from -seed. -replay plays back a movie recorded
+// by the emulator, with the seed, quirks and speed it was recorded with.
+//
+// The outputs are written even if the program crashes on an instruction it
+// can't run, then the error is printed and chip8-run exits with status 1.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/bomer/chip8/audio"
+	"github.com/bomer/chip8/capture"
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/movie"
+	"github.com/bomer/chip8/trace"
+)
+
+var (
+	cycles  = flag.Int("cycles", 0, "number of instructions to execute (overrides -frames)")
+	frames  = flag.Int("frames", 600, "number of 60Hz frames to run")
+	ipf     = flag.Int("ipf", chip8.DefaultCyclesPerFrame, "instructions executed per frame")
+	seed    = flag.Int64("seed", 1, "random number seed")
+	random  = flag.String("random", "seeded", "random numbers for CXNN: seeded or vip")
+	quirks  = flag.String("quirks", "auto", "quirk profile: auto, vip, chip48, schip or xochip")
+	replay  = flag.String("replay", "", "replay the key presses of a movie `file`, running all its frames")
+	invalid = flag.String("invalid", "halt", "on unknown opcodes: halt, skip them, or sys to only run 0NNN as a no-op")
+
+	gfxOut  = flag.String("gfx", "", "write the display as text to `file` (- for stdout)")
+	regsOut = flag.String("regs", "", "write the registers to `file` (- for stdout)")
+	memOut  = flag.String("mem", "", "write memory to `file`, raw bytes or a hex dump for -")
+	wavOut  = flag.String("wav", "", "record the buzzer to a WAV `file`")
+	covOut  = flag.String("coverage", "", "write a report of the instructions executed to `file` (- for stdout)")
+	pngOut  = flag.String("png", "", "write a screenshot of the last frame to a PNG `file`")
+	gifOut  = flag.String("gif", "", "record the run as an animated GIF `file`")
+	clipLen = flag.Int("clip", 0, "only record the last `n` frames in the GIF, 0 for all")
+	scale   = flag.Int("scale", 4, "screenshot and GIF pixels per CHIP-8 pixel")
+	colours = flag.String("palette", capture.DefaultPalette.String(), "screenshot and GIF colours, four hex RGB values")
+
+	traceOut    = flag.String("trace", "", "trace every instruction to `file` (- for stdout)")
+	traceFormat = flag.String("trace-format", trace.JSON, "trace format: jsonl or binary")
+	tracePc     = flag.String("trace-pc", "", "only trace instructions in a hex address `range`, e.g. 200-2FF")
+	traceOps    = flag.String("trace-ops", "", "only trace opcodes starting with these hex `digits`, e.g. 8,D")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("chip8-run: ")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: chip8-run [flags] rom.c8\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *ipf <= 0 {
+		log.Fatal("-ipf must be at least 1")
+	}
+
+	var c chip8.Chip8
+	c.Init()
+	if err := c.LoadGame(flag.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := c.SetQuirkProfile(*quirks); err != nil {
+		log.Fatal(err)
+	}
+
+	source, err := chip8.NewRandomSource(*random, *seed)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.Random = source
+	c.CyclesPerFrame = *ipf
+	if c.OnInvalid, err = chip8.ParseInvalidPolicy(*invalid); err != nil {
+		log.Fatal(err)
+	}
+	if *covOut != "" {
+		c.Coverage = &chip8.Coverage{}
+	}
+	var tracer *trace.Tracer
+	if *traceOut != "" {
+		if tracer, err = startTrace(); err != nil {
+			log.Fatal(err)
+		}
+		tracer.Attach(&c)
+	}
+
+	var m *movie.Movie
+	if *replay != "" {
+		if m, err = readMovie(*replay); err != nil {
+			log.Fatal(err)
+		}
+		if err := m.Start(&c); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var sink audio.AudioSink = audio.NullSink{}
+	if *wavOut != "" {
+		f, err := os.Create(*wavOut)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		if sink, err = audio.NewWAVSink(f, audio.DefaultConfig.SampleRate); err != nil {
+			log.Fatal(err)
+		}
+	}
+	palette, err := capture.ParsePalette(*colours)
+	if err != nil {
+		log.Fatal(err)
+	}
+	clip := capture.NewClip(palette, *scale)
+	total := *frames
+	if m != nil {
+		total = m.Len()
+	} else if *cycles > 0 {
+		total = *cycles / *ipf
+	}
+
+	synth := audio.NewSynth(audio.DefaultConfig)
+	frame := 0
+	frameDone := func() {
+		if err := sink.WriteSamples(synth.Frame(&c)); err != nil {
+			log.Fatal(err)
+		}
+		if *gifOut != "" && (*clipLen <= 0 || frame >= total-*clipLen) {
+			clip.Add(&c)
+		}
+		frame++
+	}
+
+	if m != nil {
+		for m.Apply(&c) {
+			c.RunFrame()
+			frameDone()
+		}
+	} else if *cycles > 0 {
+		// Still tick the timers at the end of every -ipf instructions
+		for i := 0; i < *cycles; i++ {
+			frame := c.Frame
+			c.Step()
+			if c.Frame != frame {
+				frameDone()
+			}
+		}
+	} else {
+		for i := 0; i < *frames; i++ {
+			c.RunFrame()
+			frameDone()
+		}
+	}
+	if err := sink.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if tracer != nil {
+		if err := tracer.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *gifOut != "" {
+		if err := output(*gifOut, clip.WriteGIF); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *pngOut != "" {
+		if err := output(*pngOut, func(w io.Writer) error {
+			return palette.WritePNG(w, &c, *scale)
+		}); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// With no outputs chosen, show the screen and registers.
+	if *gfxOut == "" && *regsOut == "" && *memOut == "" && *pngOut == "" && *gifOut == "" && *covOut == "" {
+		*gfxOut, *regsOut = "-", "-"
+	}
+	if err := output(*gfxOut, c.DumpGfx); err != nil {
+		log.Fatal(err)
+	}
+	if err := output(*regsOut, c.DumpRegisters); err != nil {
+		log.Fatal(err)
+	}
+	if err := output(*covOut, func(w io.Writer) error {
+		_, err := c.Coverage.WriteTo(w)
+		return err
+	}); err != nil {
+		log.Fatal(err)
+	}
+	if err := output(*memOut, func(w io.Writer) error {
+		if w == os.Stdout {
+			d := hex.Dumper(w)
+			if _, err := d.Write(c.Memory[:]); err != nil {
+				return err
+			}
+			return d.Close()
+		}
+		_, err := w.Write(c.Memory[:])
+		return err
+	}); err != nil {
+		log.Fatal(err)
+	}
+	if c.Fault != nil {
+		log.Fatal(c.Fault)
+	}
+}
+
+// Opens the -trace file with the filters from the other -trace flags.
+func startTrace() (*trace.Tracer, error) {
+	filter, err := trace.ParseFilter(*tracePc, *traceOps)
+	if err != nil {
+		return nil, err
+	}
+	tracer, err := trace.Create(*traceOut, *traceFormat)
+	if err != nil {
+		return nil, err
+	}
+	tracer.Filter = filter
+	return tracer, nil
+}
+
+func readMovie(path string) (*movie.Movie, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return movie.Read(f)
+}
+
+// output calls dump with the destination named by path, doing nothing for an
+// empty path and using stdout for "-".
+func output(path string, dump func(io.Writer) error) error {
+	switch path {
+	case "":
+		return nil
+	case "-":
+		return dump(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := dump(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
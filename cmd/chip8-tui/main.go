@@ -0,0 +1,220 @@
+// Command chip8-tui plays CHIP-8 games in a terminal, no OpenGL needed.
+//
+//	chip8-tui [rom.c8 ...]
+//
+// With no ROMs it plays the bundled games in assets/. [ and ] switch between
+// the games, Escape or Ctrl-C quits. The keypad is mapped as in the OpenGL
+// frontend, from the same -keys file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/keymap"
+	"github.com/bomer/chip8/tui"
+	"golang.org/x/mobile/event/key"
+)
+
+var (
+	quirks     = flag.String("quirks", "auto", "quirk profile: auto, vip, chip48, schip or xochip")
+	ipf        = flag.Int("ipf", chip8.DefaultCyclesPerFrame, "instructions executed per 60Hz frame")
+	random     = flag.String("random", "seeded", "random numbers for CXNN: seeded or vip")
+	keysPath   = flag.String("keys", "keys.json", "keyboard mapping config `file`, defaults are used if it doesn't exist")
+	firstHold  = flag.Int("hold", tui.DefaultFirstHold, "frames a key stays down after it is pressed, longer than the keyboard repeat delay")
+	repeatHold = flag.Int("repeat", tui.DefaultRepeatHold, "frames a key stays down after each auto-repeat")
+	invalid    = flag.String("invalid", "halt", "on unknown opcodes: halt, skip them, or sys to only run 0NNN as a no-op")
+)
+
+// Frames to wait for the rest of an escape sequence before taking the escape
+// byte that started it for the Escape key.
+const escapeWait = 6
+
+// The machine and everything that changes with the game.
+type player struct {
+	roms   []string
+	index  int
+	chip   chip8.Chip8
+	config *keymap.Config
+	keypad *tui.Keypad
+	screen *tui.Screen
+	policy chip8.InvalidPolicy
+	crash  bool // Whether the crash is showing in the status line
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("chip8-tui: ")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: chip8-tui [flags] [rom.c8 ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *ipf <= 0 {
+		log.Fatal("-ipf must be at least 1")
+	}
+
+	p := &player{roms: flag.Args(), screen: tui.NewScreen()}
+	var err error
+	if p.policy, err = chip8.ParseInvalidPolicy(*invalid); err != nil {
+		log.Fatal(err)
+	}
+	if len(p.roms) == 0 {
+		p.roms, _ = filepath.Glob(filepath.Join("assets", "*.c8"))
+		if len(p.roms) == 0 {
+			log.Fatal("no ROMs given and none found in assets/")
+		}
+	}
+	if p.config, err = keymap.LoadFile(*keysPath); err != nil {
+		log.Fatal(err)
+	}
+	if err := p.load(); err != nil {
+		log.Fatal(err)
+	}
+
+	restore, err := tui.MakeRaw(os.Stdin)
+	if err != nil {
+		log.Fatalf("setting up the terminal: %v", err)
+	}
+	err = p.run()
+	p.screen.Stop(os.Stdout)
+	restore()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Loads the current ROM into a fresh machine.
+func (self *player) load() error {
+	c := &self.chip
+	c.Init()
+	if err := c.LoadGame(self.roms[self.index]); err != nil {
+		return err
+	}
+	if _, err := c.SetQuirkProfile(*quirks); err != nil {
+		return err
+	}
+	c.CyclesPerFrame = *ipf
+	c.OnInvalid = self.policy
+	self.crash = false
+	source, err := chip8.NewRandomSource(*random, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+	c.Random = source
+	km, err := self.config.Keymap(c.RomName, c.RomHash)
+	if err != nil {
+		return err
+	}
+	self.keypad = tui.NewKeypad(km)
+	self.keypad.FirstHold, self.keypad.RepeatHold = *firstHold, *repeatHold
+	return nil
+}
+
+// Plays until Escape, a signal or an error.
+func (self *player) run() error {
+	input := make(chan []byte, 16)
+	go func() {
+		for {
+			buf := make([]byte, 64)
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(input)
+				return
+			}
+			input <- buf[:n]
+		}
+	}()
+	var decoder tui.Decoder
+	waited := 0 // Frames since the decoder was left partway through a sequence
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	if err := self.start(); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(time.Second / chip8.FrameRate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-signals:
+			return nil
+		case buf, ok := <-input:
+			if !ok {
+				return nil
+			}
+			waited = 0
+			if quit, err := self.keys(decoder.Decode(buf)); quit || err != nil {
+				return err
+			}
+		case <-ticker.C:
+			// Escape on its own once the rest of a sequence hasn't come
+			if decoder.Pending() {
+				if waited++; waited >= escapeWait {
+					if quit, err := self.keys(decoder.Flush()); quit || err != nil {
+						return err
+					}
+				}
+			}
+			self.keypad.Frame(&self.chip)
+			self.chip.RunFrame()
+			if err := self.screen.Draw(os.Stdout, &self.chip); err != nil {
+				return err
+			}
+			if self.chip.Fault != nil && !self.crash {
+				self.crash = true
+				if _, err := fmt.Printf("\x1b[%d;1H\x1b[2K\x1b[31m%v\x1b[0m   [ ] switch game, Esc quits", tui.Lines+2, self.chip.Fault); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// Clears the terminal and writes the status line for the current game.
+func (self *player) start() error {
+	if err := self.screen.Start(os.Stdout); err != nil {
+		return err
+	}
+	_, err := fmt.Printf("\x1b[%d;1H%s   [ ] switch game, Esc quits", tui.Lines+2, filepath.Base(self.chip.RomName))
+	return err
+}
+
+// Handles each of codes in turn, reporting whether to quit.
+func (self *player) keys(codes []key.Code) (bool, error) {
+	for _, code := range codes {
+		if quit, err := self.key(code); quit || err != nil {
+			return quit, err
+		}
+	}
+	return false, nil
+}
+
+// Handles a key, reporting whether to quit.
+func (self *player) key(code key.Code) (bool, error) {
+	if code == key.CodeEscape {
+		return true, nil
+	}
+	if self.keypad.Press(code) {
+		return false, nil
+	}
+	switch code {
+	case key.CodeRightSquareBracket:
+		self.index = (self.index + 1) % len(self.roms)
+	case key.CodeLeftSquareBracket:
+		self.index = (self.index + len(self.roms) - 1) % len(self.roms)
+	default:
+		return false, nil
+	}
+	if err := self.load(); err != nil {
+		return false, err
+	}
+	return false, self.start()
+}
@@ -0,0 +1,79 @@
+package conformance_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/conformance"
+)
+
+var community = flag.String("community", filepath.Join("testdata", "community"), "`directory` holding the community test suite ROMs, see its README")
+
+// The ROMs of Timendus' CHIP-8 test suite, which aren't vendored, see
+// testdata/community/README.md. Their golden images are
+// testdata/community/<profile>/<name>.txt.
+var communitySuites = []struct {
+	file string
+	keys []int
+	// Picks the menu entry to run for a profile, stored at 0x1FF. 0 for
+	// ROMs without a menu.
+	menu func(profile string) byte
+}{
+	{"3-corax+.ch8", nil, nil},
+	{"4-flags.ch8", nil, nil},
+	{"5-quirks.ch8", nil, quirksMenu},
+	// EX9E with 5 and A held down
+	{"6-keypad.ch8", []int{0x5, 0xA}, func(string) byte { return 1 }},
+}
+
+// The quirks ROM tests the behaviour of CHIP-8, SUPER-CHIP or XO-CHIP.
+func quirksMenu(profile string) byte {
+	switch profile {
+	case "schip":
+		return 2
+	case "xochip":
+		return 3
+	}
+	return 1
+}
+
+func TestCommunity(t *testing.T) {
+	for _, suite := range communitySuites {
+		suite := suite
+		path := filepath.Join(*community, suite.file)
+		rom, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			t.Logf("skipping %s, see testdata/community/README.md", path)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := suite.file[:len(suite.file)-len(filepath.Ext(suite.file))]
+		for _, profile := range chip8.ProfileNames() {
+			profile := profile
+			t.Run(name+"/"+profile, func(t *testing.T) {
+				cfg := conformance.Config{
+					Profile:        profile,
+					Frames:         300,
+					CyclesPerFrame: 1000,
+					Keys:           suite.keys,
+				}
+				if suite.menu != nil {
+					cfg.Poke = map[uint16]byte{0x1FF: suite.menu(profile)}
+				}
+				c, err := conformance.Run(name, rom, cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				golden := filepath.Join("testdata", "community", profile, name+".txt")
+				if err := conformance.Check(golden, conformance.Screen(c), *update); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	}
+}
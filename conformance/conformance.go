@@ -0,0 +1,155 @@
+// Package conformance runs ROMs headlessly and checks the screens they draw
+// against golden images, so every change to the interpreter can be validated
+// against known good screens: test ROMs under each quirk profile and the
+// bundled games with scripted input.
+//
+// Golden images are DumpGfx text, one line per scan line, so a failure or an
+// update reads as a diff.
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+// Config is how a ROM is run.
+type Config struct {
+	Profile        string // Quirk profile, "auto" picks one for the ROM
+	Frames         int
+	CyclesPerFrame int   // 0 for chip8.DefaultCyclesPerFrame
+	Keys           []int // Held down the whole run
+	Script         []Step
+	Seed           int64 // For CXNN
+	// Bytes written into memory after the ROM is loaded, e.g. 0x1FF to pick
+	// a menu entry in the community test suite ROMs
+	Poke map[uint16]byte
+}
+
+// Step holds Keys down from the start of frame Frame, counting from 0, until
+// the next step. Steps are in frame order.
+type Step struct {
+	Frame int
+	Keys  []int
+}
+
+// Run loads rom into a new machine and runs it for cfg.Frames frames. The
+// machine is returned even on error, showing where it faulted.
+func Run(name string, rom []byte, cfg Config) (*chip8.Chip8, error) {
+	return run(name, rom, cfg, nil)
+}
+
+// Screens runs rom like Run, returning the screen after each of the frames
+// in at as one golden image, each headed by its frame number.
+func Screens(name string, rom []byte, cfg Config, at ...int) ([]byte, error) {
+	var buf bytes.Buffer
+	next := 0
+	_, err := run(name, rom, cfg, func(c *chip8.Chip8, frame int) {
+		if next < len(at) && frame == at[next] {
+			fmt.Fprintf(&buf, "frame %d\n", frame)
+			c.DumpGfx(&buf)
+			next++
+		}
+	})
+	if err == nil && next < len(at) {
+		err = fmt.Errorf("conformance: frame %d is after the last of %d", at[next], cfg.Frames)
+	}
+	return buf.Bytes(), err
+}
+
+// Runs rom, calling after, if not nil, with the number of frames run after
+// each one.
+func run(name string, rom []byte, cfg Config, after func(c *chip8.Chip8, frame int)) (*chip8.Chip8, error) {
+	c := &chip8.Chip8{}
+	c.Init()
+	if err := c.LoadBytes(name, rom); err != nil {
+		return c, err
+	}
+	if _, err := c.SetQuirkProfile(cfg.Profile); err != nil {
+		return c, err
+	}
+	for addr, b := range cfg.Poke {
+		c.Memory[addr] = b
+	}
+	c.CyclesPerFrame = cfg.CyclesPerFrame
+	c.SeedRandom(cfg.Seed)
+	step := 0
+	for i := 0; i < cfg.Frames; i++ {
+		for step < len(cfg.Script) && cfg.Script[step].Frame <= i {
+			c.Key = [16]byte{}
+			for _, k := range cfg.Script[step].Keys {
+				c.Key[k&0xF] = 1
+			}
+			step++
+		}
+		for _, k := range cfg.Keys {
+			c.Key[k&0xF] = 1
+		}
+		if err := c.RunFrame(); err != nil {
+			return c, err
+		}
+		if after != nil {
+			after(c, i+1)
+		}
+	}
+	return c, nil
+}
+
+// Screen is the display of c as a golden image.
+func Screen(c *chip8.Chip8) []byte {
+	var buf bytes.Buffer
+	c.DumpGfx(&buf)
+	return buf.Bytes()
+}
+
+// MismatchError is a screen that differs from its golden image.
+type MismatchError struct {
+	Path      string
+	Want, Got []byte
+}
+
+func (self *MismatchError) Error() string {
+	want := strings.Split(string(self.Want), "\n")
+	got := strings.Split(string(self.Got), "\n")
+	var b strings.Builder
+	fmt.Fprintf(&b, "conformance: screen differs from %s", self.Path)
+	for i := 0; i < len(want) || i < len(got); i++ {
+		var w, g string
+		if i < len(want) {
+			w = want[i]
+		}
+		if i < len(got) {
+			g = got[i]
+		}
+		if w != g {
+			fmt.Fprintf(&b, "\nline %2d want %s\n        got  %s", i+1, w, g)
+		}
+	}
+	return b.String()
+}
+
+// Check compares got with the golden image at path, or when update is set
+// writes got there instead, making any missing directories.
+func Check(path string, got []byte, update bool) error {
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, got, 0644)
+	}
+	want, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("conformance: no golden image %s, run the tests with -update to write it", path)
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(want, got) {
+		return &MismatchError{Path: path, Want: want, Got: got}
+	}
+	return nil
+}
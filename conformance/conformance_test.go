@@ -0,0 +1,138 @@
+package conformance_test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bomer/chip8/asm"
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/conformance"
+)
+
+var update = flag.Bool("update", false, "write the golden images instead of checking them")
+
+// The test ROMs in testdata, each assembled after testdata/report.8o. Their
+// golden images are testdata/<profile>/<name>.txt.
+var suites = []struct {
+	name string
+	keys []int
+	// Crosses expected on screen, the ROM counts them in VA
+	fails func(q chip8.Quirks) int
+}{
+	{"opcodes", nil, func(chip8.Quirks) int { return 0 }},
+	{"flags", nil, func(chip8.Quirks) int { return 0 }},
+	{"quirks", nil, quirksOff},
+	{"keypad", []int{0x5, 0xA}, func(chip8.Quirks) int { return 14 }},
+}
+
+// The quirks test ROM ticks the quirks that are on and crosses the rest.
+func quirksOff(q chip8.Quirks) int {
+	off := 0
+	for _, on := range []bool{q.VFReset, q.ShiftUsesVY, q.LoadStoreIncrementsI, q.JumpUsesVX, q.SpriteWrap, q.DisplayWait} {
+		if !on {
+			off++
+		}
+	}
+	return off
+}
+
+func assemble(t *testing.T, name string) []byte {
+	t.Helper()
+	prelude, err := os.ReadFile(filepath.Join("testdata", "report.8o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.ReadFile(filepath.Join("testdata", name+".8o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := asm.Assemble(string(prelude) + string(src))
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return prog.Rom
+}
+
+func TestConformance(t *testing.T) {
+	for _, suite := range suites {
+		suite := suite
+		rom := assemble(t, suite.name)
+		for _, profile := range chip8.ProfileNames() {
+			profile := profile
+			t.Run(suite.name+"/"+profile, func(t *testing.T) {
+				// The quirks ROM needs more than 10 instructions a frame to
+				// spot DisplayWait, and with it every sprite takes a frame
+				c, err := conformance.Run(suite.name, rom, conformance.Config{
+					Profile:        profile,
+					Frames:         120,
+					CyclesPerFrame: 100,
+					Keys:           suite.keys,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := suite.fails(c.Quirks); int(c.V[0xA]) != want {
+					t.Errorf("%d tests failed, want %d", c.V[0xA], want)
+				}
+				path := filepath.Join("testdata", profile, suite.name+".txt")
+				if err := conformance.Check(path, conformance.Screen(c), *update); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new", "screen.txt")
+	if err := conformance.Check(path, []byte("..#\n"), false); err == nil {
+		t.Error("a missing golden image should fail")
+	}
+	if err := conformance.Check(path, []byte("..#\n"), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := conformance.Check(path, []byte("..#\n"), false); err != nil {
+		t.Error(err)
+	}
+	err := conformance.Check(path, []byte(".##\n"), false)
+	var mismatch *conformance.MismatchError
+	if !errors.As(err, &mismatch) || string(mismatch.Want) != "..#\n" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestScript(t *testing.T) {
+	rom := assemble(t, "keypad")
+	// Key 3 is let go before the keypad is read, 7 is held from then on
+	script := []conformance.Step{{0, []int{3}}, {1, nil}, {2, []int{7}}}
+	screens, err := conformance.Screens("keypad", rom, conformance.Config{Frames: 60, Script: script}, 1, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(screens, []byte("frame 1\n")) || !bytes.Contains(screens, []byte("\nframe 60\n")) {
+		t.Errorf("got\n%s", screens)
+	}
+	c, _ := conformance.Run("keypad", rom, conformance.Config{Frames: 60, Script: script})
+	if c.V[0xA] != 15 || c.V[0] != 7 {
+		t.Errorf("%d tests failed and FX0A read %X, want 15 and 7", c.V[0xA], c.V[0])
+	}
+
+	if _, err := conformance.Screens("keypad", rom, conformance.Config{Frames: 10}, 20); err == nil {
+		t.Error("a frame after the end of the run should fail")
+	}
+}
+
+func TestPoke(t *testing.T) {
+	prog, err := asm.Assemble(": main i := 0x1FF load v0 loop again")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := conformance.Run("poke", prog.Rom, conformance.Config{Frames: 1, Poke: map[uint16]byte{0x1FF: 3}})
+	if err != nil || c.V[0] != 3 {
+		t.Errorf("read %d from 0x1FF, %v", c.V[0], err)
+	}
+}
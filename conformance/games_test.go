@@ -0,0 +1,58 @@
+package conformance_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bomer/chip8/conformance"
+)
+
+// Golden screens of the bundled games are taken at these frames, ten seconds
+// of play in all.
+var checkpoints = []int{120, 300, 600}
+
+// Each game plays with the keys it reads, held for a while at a time. Their
+// golden images are testdata/games/<name>.txt.
+var games = []struct {
+	name   string
+	script []conformance.Step
+}{
+	{"brix", []conformance.Step{{60, []int{4}}, {150, nil}, {200, []int{6}}, {320, nil}, {400, []int{4}}, {450, nil}}},
+	{"tetris", []conformance.Step{{40, []int{4}}, {60, nil}, {100, []int{5}}, {110, nil}, {200, []int{6}}, {230, nil}, {300, []int{7}}, {320, nil}}},
+	{"ufo", []conformance.Step{{60, []int{5}}, {70, nil}, {180, []int{4}}, {190, nil}, {300, []int{6}}, {310, nil}}},
+	{"invaders", []conformance.Step{{100, []int{5}}, {110, nil}, {200, []int{4}}, {260, []int{5}}, {270, nil}, {350, []int{6}}, {420, []int{5}}, {430, nil}}},
+	{"pong", []conformance.Step{{60, []int{1, 0xD}}, {120, nil}, {200, []int{4, 0xC}}, {260, nil}}},
+	{"joust", []conformance.Step{{60, []int{0xA}}, {70, nil}, {150, []int{0xC}}, {250, []int{3, 0xA}}, {350, nil}}},
+	{"ant", []conformance.Step{{60, []int{0xA}}, {70, nil}, {150, []int{0xC}}, {250, []int{3}}, {350, nil}}},
+	{"alien", []conformance.Step{{60, []int{0xA}}, {70, nil}, {150, []int{0xC, 0xA}}, {250, []int{3}}, {350, nil}}},
+}
+
+func TestGames(t *testing.T) {
+	for _, game := range games {
+		game := game
+		t.Run(game.name, func(t *testing.T) {
+			rom, err := os.ReadFile(filepath.Join("..", "assets", game.name+".c8"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			screens, err := conformance.Screens(game.name, rom, conformance.Config{
+				Profile: "auto",
+				Frames:  checkpoints[len(checkpoints)-1],
+				Script:  game.script,
+				Seed:    1,
+			}, checkpoints...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(screens, []byte("#")) {
+				t.Error("the screen stayed blank")
+			}
+			path := filepath.Join("testdata", "games", game.name+".txt")
+			if err := conformance.Check(path, screens, *update); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
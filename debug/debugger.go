@@ -0,0 +1,243 @@
+// Package debug wraps a Chip8 with breakpoints, watchpoints and stepping,
+// and provides a command line REPL on top of them.
+package debug
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bomer/chip8/asm"
+	"github.com/bomer/chip8/chip8"
+)
+
+// Condition compares a V register with a value, e.g. "v3 == 0x10".
+type Condition struct {
+	Reg   int
+	Op    string // ==, !=, <, <=, > or >=
+	Value byte
+}
+
+// ParseCondition parses "vX op value".
+func ParseCondition(s string) (*Condition, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("debug: condition %q should be vX op value", s)
+	}
+	reg, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(fields[0]), "v"), 16, 4)
+	if err != nil || !strings.HasPrefix(strings.ToLower(fields[0]), "v") {
+		return nil, fmt.Errorf("debug: %q is not a register", fields[0])
+	}
+	switch fields[1] {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return nil, fmt.Errorf("debug: unknown comparison %q", fields[1])
+	}
+	value, err := strconv.ParseUint(fields[2], 0, 8)
+	if err != nil {
+		return nil, fmt.Errorf("debug: %q is not a byte", fields[2])
+	}
+	return &Condition{Reg: int(reg), Op: fields[1], Value: byte(value)}, nil
+}
+
+// Holds reports whether the condition is true for c.
+func (self *Condition) Holds(c *chip8.Chip8) bool {
+	v := c.V[self.Reg]
+	switch self.Op {
+	case "==":
+		return v == self.Value
+	case "!=":
+		return v != self.Value
+	case "<":
+		return v < self.Value
+	case "<=":
+		return v <= self.Value
+	case ">":
+		return v > self.Value
+	case ">=":
+		return v >= self.Value
+	}
+	return false
+}
+
+func (self *Condition) String() string {
+	return fmt.Sprintf("V%X %s 0x%02X", self.Reg, self.Op, self.Value)
+}
+
+// Watch selects which accesses a watchpoint stops on.
+type Watch int
+
+const (
+	WatchRead Watch = 1 << iota
+	WatchWrite
+	WatchAccess = WatchRead | WatchWrite
+)
+
+func (self Watch) String() string {
+	switch self {
+	case WatchRead:
+		return "read"
+	case WatchWrite:
+		return "write"
+	}
+	return "access"
+}
+
+// Reason says why execution stopped.
+type Reason int
+
+const (
+	Stepped    Reason = iota // The requested step finished
+	Breakpoint               // Reached a breakpoint whose condition held
+	Watchpoint               // An instruction touched a watched address
+	Halted                   // The program ran 00FD
+	Limit                    // Ran the maximum number of instructions
+	Fault                    // An instruction couldn't be run, see Err
+)
+
+// Stop describes where and why execution stopped.
+type Stop struct {
+	Reason Reason
+	Pc     uint16
+	Addr   uint16 // Watched address, for Watchpoint
+	Write  bool   // Whether the watched access was a write
+	Err    error  // The *chip8.OpcodeError, for Fault
+}
+
+func (self Stop) String() string {
+	switch self.Reason {
+	case Breakpoint:
+		return fmt.Sprintf("breakpoint at 0x%03X", self.Pc)
+	case Watchpoint:
+		access := "read"
+		if self.Write {
+			access = "write"
+		}
+		return fmt.Sprintf("%s of 0x%03X, stopped at 0x%03X", access, self.Addr, self.Pc)
+	case Halted:
+		return fmt.Sprintf("halted at 0x%03X", self.Pc)
+	case Limit:
+		return fmt.Sprintf("instruction limit reached at 0x%03X", self.Pc)
+	case Fault:
+		return self.Err.Error()
+	}
+	return fmt.Sprintf("stopped at 0x%03X", self.Pc)
+}
+
+// Debugger controls a Chip8. Breakpoints are checked before an instruction
+// runs, watchpoints stop after the instruction that touched the address.
+type Debugger struct {
+	Chip        *chip8.Chip8
+	Breakpoints map[uint16]*Condition // nil condition always stops
+	Watchpoints map[uint16]Watch
+	Symbols     *asm.Symbols // Optional, for labels in listings
+	// MaxSteps stops Continue and the other run commands after this many
+	// instructions so a loop that never hits a breakpoint can't hang.
+	MaxSteps int
+
+	hit *Stop // Set by the memory hook
+}
+
+// DefaultMaxSteps is ten minutes of emulated time at the default speed.
+const DefaultMaxSteps = 10 * 60 * chip8.FrameRate * chip8.DefaultCyclesPerFrame
+
+// New attaches a debugger to c, installing its memory hook.
+func New(c *chip8.Chip8) *Debugger {
+	d := &Debugger{
+		Chip:        c,
+		Breakpoints: map[uint16]*Condition{},
+		Watchpoints: map[uint16]Watch{},
+		MaxSteps:    DefaultMaxSteps,
+	}
+	c.OnMemory = d.onMemory
+	return d
+}
+
+func (self *Debugger) onMemory(addr uint16, write bool) {
+	watch, ok := self.Watchpoints[addr]
+	if !ok || self.hit != nil {
+		return
+	}
+	if (write && watch&WatchWrite != 0) || (!write && watch&WatchRead != 0) {
+		self.hit = &Stop{Reason: Watchpoint, Addr: addr, Write: write}
+	}
+}
+
+// Runs one instruction with chip8.Chip8.Step, ticking the timers every
+// CyclesPerFrame instructions the way RunFrame does. Reports a watchpoint hit or a fault, including one
+// OnInvalid skipped over.
+func (self *Debugger) cycle() *Stop {
+	c := self.Chip
+	self.hit = nil
+	err := c.Step()
+	if err != nil {
+		return &Stop{Reason: Fault, Pc: c.Pc, Err: err}
+	}
+	if self.hit != nil {
+		self.hit.Pc = c.Pc
+		return self.hit
+	}
+	if c.Halted {
+		return &Stop{Reason: Halted, Pc: c.Pc}
+	}
+	return nil
+}
+
+func (self *Debugger) atBreakpoint() bool {
+	cond, ok := self.Breakpoints[self.Chip.Pc]
+	return ok && (cond == nil || cond.Holds(self.Chip))
+}
+
+// Runs until done reports true, checking breakpoints from the second
+// instruction on so a run can start from a breakpoint.
+func (self *Debugger) runUntil(done func() bool) Stop {
+	for i := 0; i < self.MaxSteps; i++ {
+		if c := self.Chip; c.Halted {
+			if c.Fault != nil {
+				return Stop{Reason: Fault, Pc: c.Pc, Err: c.Fault}
+			}
+			return Stop{Reason: Halted, Pc: c.Pc}
+		}
+		if i > 0 && self.atBreakpoint() {
+			return Stop{Reason: Breakpoint, Pc: self.Chip.Pc}
+		}
+		if stop := self.cycle(); stop != nil {
+			return *stop
+		}
+		if done() {
+			return Stop{Reason: Stepped, Pc: self.Chip.Pc}
+		}
+	}
+	return Stop{Reason: Limit, Pc: self.Chip.Pc}
+}
+
+// Step runs a single instruction.
+func (self *Debugger) Step() Stop {
+	return self.runUntil(func() bool { return true })
+}
+
+// StepOver runs a single instruction, but runs a whole subroutine when the
+// instruction is a call.
+func (self *Debugger) StepOver() Stop {
+	c := self.Chip
+	if chip8.Decode(c.Memory[c.Pc:]).Kind != chip8.OpCall {
+		return self.Step()
+	}
+	sp, ret := c.Sp, c.Pc+2
+	return self.runUntil(func() bool { return c.Sp == sp && c.Pc == ret })
+}
+
+// StepOut runs until the current subroutine returns.
+func (self *Debugger) StepOut() Stop {
+	c := self.Chip
+	if c.Sp == 0 {
+		return self.Continue()
+	}
+	sp := c.Sp
+	return self.runUntil(func() bool { return c.Sp < sp })
+}
+
+// Continue runs until a breakpoint, watchpoint, fault or the program halting.
+func (self *Debugger) Continue() Stop {
+	return self.runUntil(func() bool { return false })
+}
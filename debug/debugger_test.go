@@ -0,0 +1,140 @@
+package debug_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bomer/chip8/asm"
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/debug"
+)
+
+const program = `
+: main
+	v5 := 0
+	loop
+		count
+		v5 += 1
+	again
+: count
+	i := counter
+	load v0
+	v0 += 1
+	i := counter
+	save v0
+	;
+: counter
+	0
+`
+
+func newDebugger(t *testing.T) (*debug.Debugger, *asm.Symbols) {
+	t.Helper()
+	prog, err := asm.Assemble(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &chip8.Chip8{}
+	c.Init()
+	if err := c.LoadBytes("test", prog.Rom); err != nil {
+		t.Fatal(err)
+	}
+	d := debug.New(c)
+	d.Symbols = prog.Symbols
+	d.MaxSteps = 1000
+	return d, prog.Symbols
+}
+
+func TestBreakpoints(t *testing.T) {
+	d, syms := newDebugger(t)
+	count := syms.Labels["count"]
+	d.Breakpoints[count] = nil
+	if stop := d.Continue(); stop.Reason != debug.Breakpoint || stop.Pc != count {
+		t.Fatalf("expected breakpoint at %03X, got %v", count, stop)
+	}
+	// Continuing from a breakpoint runs past it
+	if stop := d.Continue(); stop.Reason != debug.Breakpoint || d.Chip.V[5] != 1 {
+		t.Fatalf("expected second hit with V5=1, got %v V5=%d", stop, d.Chip.V[5])
+	}
+
+	cond, err := debug.ParseCondition("v5 == 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Breakpoints[count] = cond
+	if stop := d.Continue(); stop.Reason != debug.Breakpoint || d.Chip.V[5] != 5 {
+		t.Errorf("conditional breakpoint stopped with V5=%d (%v)", d.Chip.V[5], stop)
+	}
+}
+
+func TestWatchpoints(t *testing.T) {
+	d, syms := newDebugger(t)
+	counter := syms.Labels["counter"]
+	d.Watchpoints[counter] = debug.WatchWrite
+	stop := d.Continue()
+	if stop.Reason != debug.Watchpoint || stop.Addr != counter || !stop.Write {
+		t.Fatalf("expected write of %03X, got %v", counter, stop)
+	}
+	if d.Chip.Memory[counter] != 1 {
+		t.Errorf("watchpoint should stop after the write, counter=%d", d.Chip.Memory[counter])
+	}
+
+	d.Watchpoints[counter] = debug.WatchRead
+	if stop := d.Continue(); stop.Reason != debug.Watchpoint || stop.Write {
+		t.Errorf("expected read of %03X, got %v", counter, stop)
+	}
+}
+
+func TestStepping(t *testing.T) {
+	d, syms := newDebugger(t)
+	d.Step() // jump main
+	d.Step() // v5 := 0
+	call := d.Chip.Pc
+	if stop := d.Step(); stop.Reason != debug.Stepped || d.Chip.Pc != syms.Labels["count"] || d.Chip.Sp != 1 {
+		t.Fatalf("step into call: PC=%03X SP=%d", d.Chip.Pc, d.Chip.Sp)
+	}
+	d.Step()
+	if stop := d.StepOut(); stop.Reason != debug.Stepped || d.Chip.Pc != call+2 || d.Chip.Sp != 0 {
+		t.Fatalf("step out: PC=%03X SP=%d (%v)", d.Chip.Pc, d.Chip.Sp, stop)
+	}
+	d.Step() // v5 += 1
+	d.Step() // again
+	if stop := d.StepOver(); stop.Reason != debug.Stepped || d.Chip.Pc != call+2 || d.Chip.Memory[syms.Labels["counter"]] != 2 {
+		t.Errorf("step over: PC=%03X (%v)", d.Chip.Pc, stop)
+	}
+}
+
+func TestREPL(t *testing.T) {
+	d, _ := newDebugger(t)
+	var out bytes.Buffer
+	in := strings.NewReader("break count\ncontinue\ninfo\nx counter 1\nq\n")
+	if err := d.Run(in, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"breakpoint at 0x", "count:\n*> 0x", "break 0x", "V5=00", "Stack: 204"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("output missing %q:\n%s", s, out.String())
+		}
+	}
+}
+
+func TestFault(t *testing.T) {
+	c := &chip8.Chip8{}
+	c.Init()
+	if err := c.LoadBytes("test", []byte{0x60, 0x01, 0xF0, 0xFF}); err != nil {
+		t.Fatal(err)
+	}
+	d := debug.New(c)
+	stop := d.Continue()
+	if stop.Reason != debug.Fault || stop.Pc != 0x202 || !errors.Is(stop.Err, chip8.ErrUnknownOpcode) {
+		t.Fatalf("got %v", stop)
+	}
+	if stop.String() != "chip8: unknown opcode 0xF0FF at 0x202" {
+		t.Errorf("stop reads %q", stop)
+	}
+	// Stays stopped on the fault
+	if stop := d.Step(); stop.Reason != debug.Fault {
+		t.Errorf("step after fault: %v", stop)
+	}
+}
@@ -0,0 +1,262 @@
+package debug
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/disasm"
+)
+
+const help = `commands:
+  s, step [n]           run n instructions (default 1)
+  n, next               step over calls
+  o, out                run until the current subroutine returns
+  c, continue           run until a breakpoint or watchpoint
+  b, break ADDR [vX op N]  stop at ADDR, optionally only when the condition holds
+  w, watch ADDR [read|write|access]  stop after an instruction touches ADDR
+  d, delete ADDR        remove the breakpoint and watchpoint at ADDR
+  i, info               list breakpoints and watchpoints
+  r, regs               show the disassembly around PC and the registers
+  x ADDR [n]            dump n bytes of memory (default 16)
+  q, quit
+An empty line repeats the last command. ADDR is hex or a label from the
+symbol file.`
+
+// WriteState writes the disassembly around Pc, the registers, timers and
+// stack.
+func (self *Debugger) WriteState(w io.Writer) {
+	c := self.Chip
+
+	// Instructions are 2 bytes, so back up a few from Pc. That can land mid
+	// instruction after data or a long load, good enough for a glance.
+	start := int(c.Pc) - 6
+	if start < 0 {
+		start = int(c.Pc) % 2
+	}
+	for addr, n := start, 0; n < 8 && addr < chip8.MemorySize; n++ {
+		if name, ok := self.label(uint16(addr)); ok {
+			fmt.Fprintf(w, "%s:\n", name)
+		}
+		op := disasm.Decode(c.Memory[addr:], disasm.XOChip)
+		marker := "  "
+		if addr == int(c.Pc) {
+			marker = "=>"
+		}
+		if _, ok := self.Breakpoints[uint16(addr)]; ok {
+			marker = "*" + marker[1:]
+		}
+		fmt.Fprintf(w, "%s 0x%03X: %04X  %s\n", marker, addr, op.Opcode, op.Text)
+		addr += op.Size
+	}
+
+	for i, v := range c.V {
+		sep := " "
+		if i%8 == 7 {
+			sep = "\n"
+		}
+		fmt.Fprintf(w, "V%X=%02X%s", i, v, sep)
+	}
+	fmt.Fprintf(w, "I=%04X DT=%02X ST=%02X SP=%X\n", c.Index, c.Delay_timer, c.Sound_timer, c.Sp)
+	fmt.Fprint(w, "Stack:")
+	for i := 0; i < int(c.Sp) && i < len(c.Stack); i++ {
+		fmt.Fprintf(w, " %03X", c.Stack[i])
+	}
+	fmt.Fprintln(w)
+}
+
+func (self *Debugger) label(addr uint16) (string, bool) {
+	if self.Symbols == nil {
+		return "", false
+	}
+	return self.Symbols.Label(addr)
+}
+
+// Parses a label or hex address.
+func (self *Debugger) address(s string) (uint16, error) {
+	if self.Symbols != nil {
+		if addr, ok := self.Symbols.Labels[s]; ok {
+			return addr, nil
+		}
+	}
+	addr, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("debug: %q is not an address or label", s)
+	}
+	return uint16(addr), nil
+}
+
+// Run reads commands from in until quit or end of input, writing results to
+// out.
+func (self *Debugger) Run(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	last := ""
+	self.WriteState(out)
+	for {
+		fmt.Fprint(out, "(chip8) ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			line = last
+		}
+		last = line
+		if line == "" {
+			continue
+		}
+		quit, err := self.command(line, out)
+		if err != nil {
+			fmt.Fprintln(out, err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// Runs one REPL command, reporting whether to quit.
+func (self *Debugger) command(line string, out io.Writer) (bool, error) {
+	args := strings.Fields(line)
+	cmd, args := args[0], args[1:]
+	stopped := func(stop Stop) {
+		if stop.Reason != Stepped {
+			fmt.Fprintln(out, stop)
+		}
+		self.WriteState(out)
+	}
+
+	switch cmd {
+	case "s", "step":
+		n := 1
+		if len(args) > 0 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				return false, fmt.Errorf("debug: bad step count %q", args[0])
+			}
+		}
+		var stop Stop
+		for i := 0; i < n; i++ {
+			if stop = self.Step(); stop.Reason != Stepped {
+				break
+			}
+		}
+		stopped(stop)
+	case "n", "next":
+		stopped(self.StepOver())
+	case "o", "out":
+		stopped(self.StepOut())
+	case "c", "continue":
+		stopped(self.Continue())
+	case "b", "break":
+		if len(args) != 1 && len(args) != 4 {
+			return false, fmt.Errorf("debug: usage: break ADDR [vX op N]")
+		}
+		addr, err := self.address(args[0])
+		if err != nil {
+			return false, err
+		}
+		var cond *Condition
+		if len(args) == 4 {
+			if cond, err = ParseCondition(strings.Join(args[1:], " ")); err != nil {
+				return false, err
+			}
+		}
+		self.Breakpoints[addr] = cond
+	case "w", "watch":
+		if len(args) != 1 && len(args) != 2 {
+			return false, fmt.Errorf("debug: usage: watch ADDR [read|write|access]")
+		}
+		addr, err := self.address(args[0])
+		if err != nil {
+			return false, err
+		}
+		watch := WatchAccess
+		if len(args) == 2 {
+			switch args[1] {
+			case "read":
+				watch = WatchRead
+			case "write":
+				watch = WatchWrite
+			case "access":
+			default:
+				return false, fmt.Errorf("debug: watch %q should be read, write or access", args[1])
+			}
+		}
+		self.Watchpoints[addr] = watch
+	case "d", "delete":
+		if len(args) != 1 {
+			return false, fmt.Errorf("debug: usage: delete ADDR")
+		}
+		addr, err := self.address(args[0])
+		if err != nil {
+			return false, err
+		}
+		delete(self.Breakpoints, addr)
+		delete(self.Watchpoints, addr)
+	case "i", "info":
+		self.writeInfo(out)
+	case "r", "regs":
+		self.WriteState(out)
+	case "x":
+		if len(args) != 1 && len(args) != 2 {
+			return false, fmt.Errorf("debug: usage: x ADDR [n]")
+		}
+		addr, err := self.address(args[0])
+		if err != nil {
+			return false, err
+		}
+		n := 16
+		if len(args) == 2 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return false, fmt.Errorf("debug: bad byte count %q", args[1])
+			}
+		}
+		for i := 0; i < n && int(addr)+i < chip8.MemorySize; i++ {
+			if i%16 == 0 {
+				if i > 0 {
+					fmt.Fprintln(out)
+				}
+				fmt.Fprintf(out, "%04X:", int(addr)+i)
+			}
+			fmt.Fprintf(out, " %02X", self.Chip.Memory[int(addr)+i])
+		}
+		fmt.Fprintln(out)
+	case "h", "help":
+		fmt.Fprintln(out, help)
+	case "q", "quit":
+		return true, nil
+	default:
+		return false, fmt.Errorf("debug: unknown command %q, try help", cmd)
+	}
+	return false, nil
+}
+
+func (self *Debugger) writeInfo(out io.Writer) {
+	var addrs []int
+	for addr := range self.Breakpoints {
+		addrs = append(addrs, int(addr))
+	}
+	sort.Ints(addrs)
+	for _, addr := range addrs {
+		fmt.Fprintf(out, "break 0x%03X", addr)
+		if cond := self.Breakpoints[uint16(addr)]; cond != nil {
+			fmt.Fprintf(out, " if %v", cond)
+		}
+		fmt.Fprintln(out)
+	}
+
+	addrs = addrs[:0]
+	for addr := range self.Watchpoints {
+		addrs = append(addrs, int(addr))
+	}
+	sort.Ints(addrs)
+	for _, addr := range addrs {
+		fmt.Fprintf(out, "watch 0x%03X %v\n", addr, self.Watchpoints[uint16(addr)])
+	}
+}
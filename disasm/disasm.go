@@ -0,0 +1,253 @@
+// Package disasm turns CHIP-8 programs back into readable instruction
+// listings, using the mnemonics from Cowgod's technical reference.
+package disasm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+// Syntax picks which instruction set is recognised. Each one is a superset of
+// the one before.
+type Syntax int
+
+const (
+	Chip8 Syntax = iota
+	SuperChip
+	XOChip
+)
+
+var syntaxNames = map[string]Syntax{
+	"chip8":  Chip8,
+	"schip":  SuperChip,
+	"xochip": XOChip,
+}
+
+// ParseSyntax looks a syntax up by name: chip8, schip or xochip.
+func ParseSyntax(name string) (Syntax, error) {
+	s, ok := syntaxNames[name]
+	if !ok {
+		return 0, fmt.Errorf("disasm: unknown syntax %q", name)
+	}
+	return s, nil
+}
+
+func (self Syntax) String() string {
+	for name, s := range syntaxNames {
+		if s == self {
+			return name
+		}
+	}
+	return fmt.Sprintf("Syntax(%d)", int(self))
+}
+
+// Flow describes how an instruction affects the program counter.
+type Flow int
+
+const (
+	Next   Flow = iota // Carries on to the next instruction
+	Skip               // May skip the next instruction
+	Jump               // Always continues at Target
+	Call               // Calls Target then returns to the next instruction
+	Return             // Returns from a subroutine
+	Stop               // Never continues, e.g. 00FD exit
+	Branch             // Jumps to an address computed at runtime (BNNN)
+)
+
+// Op is a single decoded instruction.
+type Op struct {
+	Opcode uint16
+	Size   int    // 2, or 4 for XO-CHIP F000 NNNN
+	Text   string // Mnemonic and operands, e.g. "LD V3, 0x12"
+	Flow   Flow
+	Target uint16 // Jump, call or I address, if HasTarget
+	// HasTarget is set when Target is an address in the program: a jump or
+	// call destination, or data loaded into I.
+	HasTarget bool
+	Valid     bool
+}
+
+// Decode decodes the instruction at the start of mem. mem should hold at
+// least 4 bytes for XO-CHIP long loads, shorter slices are padded with 0.
+func Decode(mem []byte, syntax Syntax) Op {
+	in := chip8.Decode(mem)
+	op := Op{Opcode: in.Opcode, Size: in.Size, Valid: true}
+	vx := fmt.Sprintf("V%X", in.X)
+	vy := fmt.Sprintf("V%X", in.Y)
+
+	set := func(text string, args ...interface{}) {
+		op.Text = fmt.Sprintf(text, args...)
+	}
+	target := func(flow Flow, addr uint16) {
+		op.Flow = flow
+		op.Target = addr
+		op.HasTarget = true
+	}
+
+	switch in.Kind {
+	case chip8.OpClear:
+		set("CLS")
+	case chip8.OpReturn:
+		set("RET")
+		op.Flow = Return
+	case chip8.OpScrollDown:
+		set("SCD %d", in.N)
+	case chip8.OpScrollUp:
+		set("SCU %d", in.N)
+	case chip8.OpScrollRight:
+		set("SCR")
+	case chip8.OpScrollLeft:
+		set("SCL")
+	case chip8.OpExit:
+		set("EXIT")
+		op.Flow = Stop
+	case chip8.OpLowRes:
+		set("LOW")
+	case chip8.OpHighRes:
+		set("HIGH")
+	case chip8.OpJump:
+		set("JP 0x%03X", in.NNN)
+		target(Jump, in.NNN)
+	case chip8.OpCall:
+		set("CALL 0x%03X", in.NNN)
+		target(Call, in.NNN)
+	case chip8.OpSkipEqual:
+		set("SE %s, 0x%02X", vx, in.NN)
+		op.Flow = Skip
+	case chip8.OpSkipNotEqual:
+		set("SNE %s, 0x%02X", vx, in.NN)
+		op.Flow = Skip
+	case chip8.OpSkipEqualReg:
+		set("SE %s, %s", vx, vy)
+		op.Flow = Skip
+	case chip8.OpSaveRange:
+		set("LD [I], %s-%s", vx, vy)
+	case chip8.OpLoadRange:
+		set("LD %s-%s, [I]", vx, vy)
+	case chip8.OpSet:
+		set("LD %s, 0x%02X", vx, in.NN)
+	case chip8.OpAdd:
+		set("ADD %s, 0x%02X", vx, in.NN)
+	case chip8.OpMove, chip8.OpOr, chip8.OpAnd, chip8.OpXor, chip8.OpAddReg,
+		chip8.OpSub, chip8.OpShiftRight, chip8.OpSubReverse, chip8.OpShiftLeft:
+		set("%s %s, %s", arithmeticNames[in.Kind], vx, vy)
+	case chip8.OpSkipNotEqualReg:
+		set("SNE %s, %s", vx, vy)
+		op.Flow = Skip
+	case chip8.OpSetIndex:
+		set("LD I, 0x%03X", in.NNN)
+		op.Target = in.NNN
+		op.HasTarget = true
+	case chip8.OpJumpOffset:
+		set("JP V0, 0x%03X", in.NNN)
+		target(Branch, in.NNN)
+	case chip8.OpRandom:
+		set("RND %s, 0x%02X", vx, in.NN)
+	case chip8.OpDraw:
+		set("DRW %s, %s, %d", vx, vy, in.N)
+	case chip8.OpSkipKey:
+		set("SKP %s", vx)
+		op.Flow = Skip
+	case chip8.OpSkipNotKey:
+		set("SKNP %s", vx)
+		op.Flow = Skip
+	case chip8.OpSetIndexLong:
+		set("LD I, 0x%04X", in.Long)
+		op.Target = in.Long
+		op.HasTarget = true
+	case chip8.OpPlane:
+		set("PLANE %d", in.X)
+	case chip8.OpAudio:
+		set("AUDIO")
+	case chip8.OpGetDelay:
+		set("LD %s, DT", vx)
+	case chip8.OpWaitKey:
+		set("LD %s, K", vx)
+	case chip8.OpSetDelay:
+		set("LD DT, %s", vx)
+	case chip8.OpSetSound:
+		set("LD ST, %s", vx)
+	case chip8.OpAddIndex:
+		set("ADD I, %s", vx)
+	case chip8.OpFont:
+		set("LD F, %s", vx)
+	case chip8.OpBigFont:
+		set("LD HF, %s", vx)
+	case chip8.OpBCD:
+		set("LD B, %s", vx)
+	case chip8.OpPitch:
+		set("PITCH %s", vx)
+	case chip8.OpStore:
+		set("LD [I], %s", vx)
+	case chip8.OpLoad:
+		set("LD %s, [I]", vx)
+	case chip8.OpSaveFlags:
+		set("LD R, %s", vx)
+	case chip8.OpLoadFlags:
+		set("LD %s, R", vx)
+	}
+
+	// The emulator runs 5XYN and 9XYN whatever N is, but only 0 is meant
+	skipN := (in.Kind == chip8.OpSkipEqualReg || in.Kind == chip8.OpSkipNotEqualReg) && in.N != 0
+	if in.Kind == chip8.OpInvalid || in.Kind.Set() > syntax.set() || skipN {
+		op = Op{Opcode: in.Opcode, Size: 2, Flow: Stop}
+		if in.Opcode&0xF000 == 0 {
+			// Machine code routine on the original hardware, no one emulates these
+			set("SYS 0x%03X", in.NNN)
+		} else {
+			set("DW 0x%04X", in.Opcode)
+		}
+	}
+	return op
+}
+
+var arithmeticNames = map[chip8.Kind]string{chip8.OpMove: "LD", chip8.OpOr: "OR", chip8.OpAnd: "AND",
+	chip8.OpXor: "XOR", chip8.OpAddReg: "ADD", chip8.OpSub: "SUB", chip8.OpShiftRight: "SHR",
+	chip8.OpSubReverse: "SUBN", chip8.OpShiftLeft: "SHL"}
+
+// The instruction set of the emulator matching the syntax.
+func (self Syntax) set() chip8.InstructionSet {
+	switch self {
+	case SuperChip:
+		return chip8.SetSuperChip
+	case XOChip:
+		return chip8.SetXOChip
+	}
+	return chip8.SetChip8
+}
+
+// Line is one line of a listing, either an instruction or a byte of data.
+type Line struct {
+	Addr  uint16
+	Bytes []byte
+	Label string // Label defined at Addr, if any
+	Op    *Op    // nil for data
+}
+
+func (self Line) String() string {
+	var sb strings.Builder
+	if self.Label != "" {
+		sb.WriteString(self.Label + ":\n")
+	}
+	hex := ""
+	for _, b := range self.Bytes {
+		hex += fmt.Sprintf("%02X", b)
+	}
+	if self.Op != nil {
+		fmt.Fprintf(&sb, "0x%03X: %-8s  %s", self.Addr, hex, self.Op.Text)
+	} else {
+		// Show data bits so sprites are recognisable
+		bits := ""
+		for i := 7; i >= 0; i-- {
+			if self.Bytes[0]&(1<<uint(i)) != 0 {
+				bits += "#"
+			} else {
+				bits += "."
+			}
+		}
+		fmt.Fprintf(&sb, "0x%03X: %-8s  DB 0x%s  ; %s", self.Addr, hex, hex, bits)
+	}
+	return sb.String()
+}
@@ -0,0 +1,97 @@
+package disasm_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bomer/chip8/disasm"
+)
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		bytes  []byte
+		syntax disasm.Syntax
+		text   string
+		valid  bool
+	}{
+		{[]byte{0x00, 0xE0}, disasm.Chip8, "CLS", true},
+		{[]byte{0x63, 0x12}, disasm.Chip8, "LD V3, 0x12", true},
+		{[]byte{0xD0, 0x15}, disasm.Chip8, "DRW V0, V1, 5", true},
+		{[]byte{0x22, 0xA4}, disasm.Chip8, "CALL 0x2A4", true},
+		{[]byte{0x8A, 0xBE}, disasm.Chip8, "SHL VA, VB", true},
+		{[]byte{0xF3, 0x65}, disasm.Chip8, "LD V3, [I]", true},
+		{[]byte{0x00, 0xFF}, disasm.Chip8, "SYS 0x0FF", false},
+		{[]byte{0x00, 0xFF}, disasm.SuperChip, "HIGH", true},
+		{[]byte{0x00, 0xC4}, disasm.SuperChip, "SCD 4", true},
+		{[]byte{0x00, 0xD4}, disasm.SuperChip, "SYS 0x0D4", false},
+		{[]byte{0x00, 0xD4}, disasm.XOChip, "SCU 4", true},
+		{[]byte{0xF2, 0x30}, disasm.SuperChip, "LD HF, V2", true},
+		{[]byte{0x51, 0x32}, disasm.SuperChip, "DW 0x5132", false},
+		{[]byte{0x51, 0x32}, disasm.XOChip, "LD [I], V1-V3", true},
+		{[]byte{0xF0, 0x00, 0xBE, 0xEF}, disasm.XOChip, "LD I, 0xBEEF", true},
+		{[]byte{0xF2, 0x01}, disasm.XOChip, "PLANE 2", true},
+	}
+	for _, c := range cases {
+		op := disasm.Decode(c.bytes, c.syntax)
+		if op.Text != c.text || op.Valid != c.valid {
+			t.Errorf("% X (%v): got %q valid=%v, want %q valid=%v", c.bytes, c.syntax, op.Text, op.Valid, c.text, c.valid)
+		}
+	}
+	if op := disasm.Decode([]byte{0xF0, 0x00, 0x12, 0x34}, disasm.XOChip); op.Size != 4 {
+		t.Errorf("long load should be 4 bytes, got %d", op.Size)
+	}
+}
+
+func TestDisassembleSeparatesData(t *testing.T) {
+	rom := []byte{
+		0xA2, 0x0A, // 0x200 LD I, 0x20A
+		0x22, 0x08, // 0x202 CALL 0x208
+		0x30, 0x00, // 0x204 SE V0, 0x00
+		0x12, 0x04, // 0x206 JP 0x204
+		0x00, 0xEE, // 0x208 RET
+		0xFF, 0x81, // 0x20A sprite data, would decode as an invalid instruction
+	}
+	listing := disasm.Disassemble(rom, disasm.Options{})
+
+	var code, data int
+	for _, line := range listing.Lines {
+		if line.Op != nil {
+			code++
+		} else {
+			data++
+		}
+	}
+	if code != 5 || data != 2 {
+		t.Errorf("expected 5 instructions and 2 data bytes, got %d and %d", code, data)
+	}
+	want := map[uint16]string{0x204: "lbl_204", 0x208: "sub_208", 0x20A: "data_20A"}
+	for addr, name := range want {
+		if listing.Labels[addr] != name {
+			t.Errorf("label at %03X: got %q want %q", addr, listing.Labels[addr], name)
+		}
+	}
+
+	var out bytes.Buffer
+	listing.WriteTo(&out)
+	for _, s := range []string{"sub_208:\n0x208: 00EE", "0x20A: FF        DB 0xFF  ; ########"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("listing missing %q:\n%s", s, out.String())
+		}
+	}
+}
+
+func TestDisassembleFollowsSkips(t *testing.T) {
+	rom := []byte{
+		0x30, 0x00, // 0x200 SE V0, 0x00
+		0x00, 0xFD, // 0x202 EXIT
+		0x60, 0x01, // 0x204 LD V0, 0x01, only reachable by skipping
+		0x00, 0xFD, // 0x206 EXIT
+	}
+	listing := disasm.Disassemble(rom, disasm.Options{Syntax: disasm.SuperChip})
+	for _, line := range listing.Lines {
+		if line.Op == nil {
+			t.Errorf("0x%03X should be code", line.Addr)
+		}
+	}
+}
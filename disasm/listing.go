@@ -0,0 +1,136 @@
+package disasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Options control Disassemble.
+type Options struct {
+	Syntax  Syntax
+	Origin  uint16   // Address of the first byte, 0x200 if 0
+	Entries []uint16 // Where execution starts, Origin if empty
+}
+
+// Listing is a disassembled program.
+type Listing struct {
+	Lines  []Line
+	Labels map[uint16]string
+}
+
+// Disassemble separates code from data by following every path execution can
+// take from the entry points, the way a recursive descent disassembler does.
+// Anything never reached is listed as data.
+func Disassemble(mem []byte, opts Options) *Listing {
+	origin := opts.Origin
+	if origin == 0 {
+		origin = 0x200
+	}
+	work := append([]uint16(nil), opts.Entries...)
+	if len(work) == 0 {
+		work = append(work, origin)
+	}
+
+	ops := map[uint16]Op{}
+	isCode := make([]bool, len(mem))
+	labels := map[uint16]string{}
+	ranks := map[uint16]int{}
+	inRange := func(addr uint16, size int) bool {
+		off := int(addr) - int(origin)
+		return off >= 0 && off+size <= len(mem)
+	}
+	// Subroutines (rank 3) beat jump targets (2) beat data (1)
+	label := func(addr uint16, name string, rank int) {
+		if !inRange(addr, 1) || ranks[addr] >= rank {
+			return
+		}
+		ranks[addr] = rank
+		labels[addr] = fmt.Sprintf("%s_%03X", name, addr)
+	}
+
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+		for inRange(addr, 2) {
+			if _, seen := ops[addr]; seen {
+				break
+			}
+			off := int(addr - origin)
+			if isCode[off] || isCode[off+1] {
+				break // Runs into the middle of an instruction already found
+			}
+			op := Decode(mem[off:], opts.Syntax)
+			if !op.Valid || !inRange(addr, op.Size) {
+				break
+			}
+			ops[addr] = op
+			for i := 0; i < op.Size; i++ {
+				isCode[off+i] = true
+			}
+
+			if op.HasTarget {
+				switch op.Flow {
+				case Call:
+					label(op.Target, "sub", 3)
+					work = append(work, op.Target)
+				case Jump, Branch:
+					label(op.Target, "lbl", 2)
+					work = append(work, op.Target)
+				default:
+					label(op.Target, "data", 1)
+				}
+			}
+
+			next := addr + uint16(op.Size)
+			if op.Flow == Skip && inRange(next, 2) {
+				// Both the next instruction and the one after it can run
+				skipped := Decode(mem[int(next-origin):], opts.Syntax)
+				work = append(work, next+uint16(skipped.Size))
+			}
+			if op.Flow == Jump || op.Flow == Branch || op.Flow == Return || op.Flow == Stop {
+				break
+			}
+			addr = next
+		}
+	}
+
+	listing := &Listing{Labels: labels}
+	for off := 0; off < len(mem); {
+		addr := origin + uint16(off)
+		line := Line{Addr: addr, Label: labels[addr]}
+		if op, ok := ops[addr]; ok {
+			line.Op = &op
+			line.Bytes = mem[off : off+op.Size]
+			off += op.Size
+		} else {
+			line.Bytes = mem[off : off+1]
+			off++
+		}
+		listing.Lines = append(listing.Lines, line)
+	}
+	return listing
+}
+
+// WriteTo writes the listing, one instruction or data byte per line with the
+// labels above the lines they mark.
+func (self *Listing) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	for _, line := range self.Lines {
+		c, _ := fmt.Fprintln(bw, line)
+		n += int64(c)
+	}
+	return n, bw.Flush()
+}
+
+// Addresses returns the addresses with labels in order.
+func (self *Listing) Addresses() []uint16 {
+	addrs := make([]uint16, 0, len(self.Labels))
+	for addr := range self.Labels {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+	return addrs
+}
@@ -2,4 +2,4 @@ module github.com/bomer/chip8
 
 go 1.17
 
-require golang.org/x/mobile v0.0.0-20220518205345-8578da9835fd // indirect
+require golang.org/x/mobile v0.0.0-20220518205345-8578da9835fd
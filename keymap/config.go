@@ -0,0 +1,162 @@
+package keymap
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mobile/event/key"
+)
+
+// Bindings lists the host keys for each CHIP-8 key, keyed "0" to "F".
+type Bindings map[string][]string
+
+// Config is the keyboard setup saved in a JSON file:
+//
+//	{
+//		"keys": {"1": ["1"], "4": ["Q"], ...},
+//		"roms": {"joust.c8": {"3": ["LeftArrow"]}}
+//	}
+//
+// ROM overrides are keyed by file name or SHA-1 in hex and replace the host
+// keys of the CHIP-8 keys they list, leaving the rest alone.
+type Config struct {
+	Keys Bindings            `json:"keys"`
+	Roms map[string]Bindings `json:"roms,omitempty"`
+}
+
+// DefaultConfig is the usual QWERTY layout, with the left hand block standing
+// in for the COSMAC VIP keypad:
+//
+//	1 2 3 C      1 2 3 4
+//	4 5 6 D      Q W E R
+//	7 8 9 E  ->  A S D F
+//	A 0 B F      Z X C V
+//
+// joust.c8 and ant.c8 move with 3 and C and act with A, which are also bound
+// to the arrow keys and space.
+func DefaultConfig() *Config {
+	arrows := Bindings{"3": {"3", "LeftArrow"}, "C": {"4", "RightArrow"}, "A": {"Z", "Spacebar", "UpArrow"}}
+	return &Config{
+		Keys: Bindings{
+			"1": {"1"}, "2": {"2"}, "3": {"3"}, "C": {"4"},
+			"4": {"Q"}, "5": {"W"}, "6": {"E"}, "D": {"R"},
+			"7": {"A"}, "8": {"S"}, "9": {"D"}, "E": {"F"},
+			"A": {"Z"}, "0": {"X"}, "B": {"C"}, "F": {"V"},
+		},
+		Roms: map[string]Bindings{"joust.c8": arrows, "ant.c8": arrows},
+	}
+}
+
+// Load reads a config written by Save, checking every key name.
+func Load(r io.Reader) (*Config, error) {
+	config := &Config{}
+	if err := json.NewDecoder(r).Decode(config); err != nil {
+		return nil, fmt.Errorf("keymap: %v", err)
+	}
+	if err := config.Keys.apply(New()); err != nil {
+		return nil, err
+	}
+	for rom, bindings := range config.Roms {
+		if err := bindings.apply(New()); err != nil {
+			return nil, fmt.Errorf("%v (in %q)", err, rom)
+		}
+	}
+	return config, nil
+}
+
+// LoadFile reads a config file, giving DefaultConfig if it doesn't exist.
+func LoadFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return DefaultConfig(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Save writes the config as indented JSON.
+func (self *Config) Save(w io.Writer) error {
+	b, err := json.MarshalIndent(self, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// SaveFile writes the config to path.
+func (self *Config) SaveFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := self.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Keymap builds the keymap for a ROM, applying any override for its file
+// name or hash on top of the main bindings.
+func (self *Config) Keymap(romName string, romHash [20]byte) (*Keymap, error) {
+	km := New()
+	if err := self.Keys.apply(km); err != nil {
+		return nil, err
+	}
+	for _, id := range []string{filepath.Base(romName), hex.EncodeToString(romHash[:])} {
+		if bindings, ok := self.Roms[id]; ok {
+			if err := bindings.apply(km); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return km, nil
+}
+
+// Binds every key listed, replacing the host keys of each CHIP-8 key. A host
+// key listed under two CHIP-8 keys is an error, rather than whichever map
+// iteration reached last winning.
+func (self Bindings) apply(km *Keymap) error {
+	var chipKeys []byte
+	codes := map[key.Code]byte{}
+	names := map[key.Code]string{}
+	for name, hostKeys := range self {
+		chipKey, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(name), "0x"), 16, 4)
+		if err != nil {
+			return fmt.Errorf("keymap: %q is not a CHIP-8 key, use 0 to F", name)
+		}
+		chipKeys = append(chipKeys, byte(chipKey))
+		for _, hostKey := range hostKeys {
+			code, err := ParseKey(hostKey)
+			if err != nil {
+				return err
+			}
+			if other, ok := names[code]; ok && codes[code] != byte(chipKey) {
+				return fmt.Errorf("keymap: %s is bound to both %s and %s", hostKey, other, name)
+			}
+			codes[code], names[code] = byte(chipKey), name
+		}
+	}
+
+	// Unbinding first means one key's new binding can't be undone by
+	// replacing another's
+	for _, chipKey := range chipKeys {
+		km.Unbind(chipKey)
+	}
+	for code, chipKey := range codes {
+		km.Bind(code, chipKey)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Package keymap maps host keyboard keys onto the 16 key CHIP-8 keypad.
+//
+// Host keys are named after the golang.org/x/mobile key codes without the
+// Code prefix, e.g. "Q", "1", "Spacebar" or "LeftArrow". Names are not case
+// sensitive.
+package keymap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bomer/chip8/chip8"
+	"golang.org/x/mobile/event/key"
+)
+
+// Keymap turns host key events into CHIP-8 key state. Several host keys can
+// drive the same CHIP-8 key, which stays down while any of them is held.
+type Keymap struct {
+	keys map[key.Code]byte
+	held map[key.Code]bool
+}
+
+// New makes an empty keymap.
+func New() *Keymap {
+	return &Keymap{keys: map[key.Code]byte{}, held: map[key.Code]bool{}}
+}
+
+// Bind makes code press the CHIP-8 key chipKey (0-F), replacing anything
+// code was bound to before.
+func (self *Keymap) Bind(code key.Code, chipKey byte) {
+	self.keys[code] = chipKey & 0xF
+}
+
+// Unbind removes every host key bound to chipKey.
+func (self *Keymap) Unbind(chipKey byte) {
+	for code, k := range self.keys {
+		if k == chipKey {
+			delete(self.keys, code)
+			delete(self.held, code)
+		}
+	}
+}
+
+// Lookup returns the CHIP-8 key code is bound to.
+func (self *Keymap) Lookup(code key.Code) (byte, bool) {
+	k, ok := self.keys[code]
+	return k, ok
+}
+
+// Codes returns the host keys bound to chipKey in name order.
+func (self *Keymap) Codes(chipKey byte) []key.Code {
+	var codes []key.Code
+	for code, k := range self.keys {
+		if k == chipKey {
+			codes = append(codes, code)
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool { return KeyName(codes[i]) < KeyName(codes[j]) })
+	return codes
+}
+
+// Handle applies a key event to c.Key, reporting false for keys that aren't
+// bound so the caller can use them for something else.
+func (self *Keymap) Handle(c *chip8.Chip8, e key.Event) bool {
+	chipKey, ok := self.keys[e.Code]
+	if !ok {
+		return false
+	}
+	switch e.Direction {
+	case key.DirPress:
+		self.held[e.Code] = true
+	case key.DirRelease:
+		delete(self.held, e.Code)
+	default:
+		return true // Auto-repeat, already down
+	}
+
+	var down byte
+	for code := range self.held {
+		if self.keys[code] == chipKey {
+			down = 1
+		}
+	}
+	c.Key[chipKey] = down
+	return true
+}
+
+// Host key names by code, built from the x/mobile code names.
+var (
+	codeNames = map[key.Code]string{}
+	nameCodes = map[string]key.Code{}
+)
+
+func init() {
+	for code := key.Code(1); code <= key.CodeCompose; code++ {
+		name := code.String()
+		if !strings.HasPrefix(name, "Code") || strings.HasPrefix(name, "Code(") {
+			continue
+		}
+		name = strings.TrimPrefix(name, "Code")
+		codeNames[code] = name
+		nameCodes[strings.ToLower(name)] = code
+	}
+}
+
+// KeyName is the name of a host key used in config files.
+func KeyName(code key.Code) string {
+	if name, ok := codeNames[code]; ok {
+		return name
+	}
+	return code.String()
+}
+
+// ParseKey looks up a host key by name.
+func ParseKey(name string) (key.Code, error) {
+	code, ok := nameCodes[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("keymap: unknown key %q", name)
+	}
+	return code, nil
+}
@@ -0,0 +1,98 @@
+package keymap_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/keymap"
+	"golang.org/x/mobile/event/key"
+)
+
+func press(km *keymap.Keymap, c *chip8.Chip8, code key.Code, dir key.Direction) bool {
+	return km.Handle(c, key.Event{Code: code, Direction: dir})
+}
+
+func TestDefaultLayout(t *testing.T) {
+	km, err := keymap.DefaultConfig().Keymap("brix.c8", [20]byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for code, want := range map[key.Code]byte{key.Code1: 0x1, key.Code4: 0xC, key.CodeQ: 0x4, key.CodeX: 0x0, key.CodeV: 0xF} {
+		if got, ok := km.Lookup(code); !ok || got != want {
+			t.Errorf("%v: got %X, %v want %X", code, got, ok, want)
+		}
+	}
+	var c chip8.Chip8
+	if press(km, &c, key.CodeLeftArrow, key.DirPress) {
+		t.Error("arrows should be unbound outside joust and ant")
+	}
+	press(km, &c, key.CodeW, key.DirPress)
+	if c.Key[5] != 1 {
+		t.Error("W should press 5")
+	}
+	press(km, &c, key.CodeW, key.DirRelease)
+	if c.Key[5] != 0 {
+		t.Error("releasing W should release 5")
+	}
+}
+
+func TestSeveralKeysForOneChipKey(t *testing.T) {
+	km, err := keymap.DefaultConfig().Keymap("assets/joust.c8", [20]byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c chip8.Chip8
+	press(km, &c, key.CodeLeftArrow, key.DirPress)
+	press(km, &c, key.Code3, key.DirPress)
+	press(km, &c, key.CodeLeftArrow, key.DirRelease)
+	if c.Key[3] != 1 {
+		t.Error("3 should stay down while one of its keys is held")
+	}
+	press(km, &c, key.Code3, key.DirRelease)
+	if c.Key[3] != 0 {
+		t.Error("3 should be up once all its keys are released")
+	}
+}
+
+func TestConfigRoundTrip(t *testing.T) {
+	config := keymap.DefaultConfig()
+	config.Roms["0123456789abcdef0123456789abcdef01234567"] = keymap.Bindings{"5": {"UpArrow", "k"}}
+	var buf bytes.Buffer
+	if err := config.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := keymap.Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := [20]byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0x01, 0x23, 0x45, 0x67}
+	km, err := loaded.Keymap("renamed.ch8", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k, ok := km.Lookup(key.CodeK); !ok || k != 5 {
+		t.Error("override by hash not applied")
+	}
+	if _, ok := km.Lookup(key.CodeW); ok {
+		t.Error("override should replace the keys of 5")
+	}
+	if k, ok := km.Lookup(key.CodeQ); !ok || k != 4 {
+		t.Error("keys not overridden should keep their defaults")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, src := range []string{
+		`{"keys": {"G": ["Q"]}}`,
+		`{"keys": {"1": ["NoSuchKey"]}}`,
+		`{"keys": {}, "roms": {"x.c8": {"1": ["Nope"]}}}`,
+		`{"keys": {"1": ["Q"], "2": ["W", "Q"]}}`,
+		`not json`,
+	} {
+		if _, err := keymap.Load(strings.NewReader(src)); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
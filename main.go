@@ -1,8 +1,17 @@
 package main
 
 import (
+	"github.com/bomer/chip8/audio"
+	"github.com/bomer/chip8/capture"
 	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/keymap"
+	"github.com/bomer/chip8/movie"
+	"github.com/bomer/chip8/rewind"
+	"github.com/bomer/chip8/runner"
+	"github.com/bomer/chip8/touchpad"
+	"github.com/bomer/chip8/trace"
 	"golang.org/x/mobile/app"
+	"golang.org/x/mobile/asset"
 	"golang.org/x/mobile/event/key"
 	"golang.org/x/mobile/event/lifecycle"
 	"golang.org/x/mobile/event/paint"
@@ -15,10 +24,14 @@ import (
 	"golang.org/x/mobile/gl"
 
 	"encoding/binary"
+	"flag"
 	"fmt"
 	"image"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"time"
 )
 
@@ -37,28 +50,153 @@ var (
 	img    glutil.Image
 )
 
-var myChip8 chip8.Chip8
+// The emulator, running on its own goroutine. Everything that touches the
+// machine goes through it, as does the movie, rewind and clip state below
+// that changes along with the machine.
+var emu *runner.Runner
 
-func main() {
-	myChip8.Init()
-	// Doesnt exist yet
+// The crash last reported, so it is only logged once.
+var crashed *chip8.OpcodeError
+
+// Host keys for the keypad, rebuilt for each game from keyConfig.
+var (
+	keyConfig *keymap.Config
+	keys      *keymap.Keymap
+)
+
+// The on-screen keypad, nil when it is turned off. Rebuilt for each game so
+// it only shows the keys the game uses.
+var pad *touchpad.Pad
+
+// The buzzer, played while the sound timer is running.
+var (
+	synth *audio.Synth
+	sink  audio.AudioSink = audio.NullSink{}
+)
+
+// Recent frames, played backwards while the rewind key is held.
+var (
+	history   *rewind.Buffer
+	rewinding bool
+)
+
+// Movies of the first game, from -record and -replay.
+var (
+	recording *movie.Movie
+	replaying *movie.Movie
+)
+
+// Colours for each Gfx value. Plain CHIP-8 only uses the first two, XO-CHIP
+// games draw to two bitplanes so a pixel can be any of the four.
+// Set with -palette, which screenshots and clips use too.
+var palette = capture.DefaultPalette
+
+// The clip being recorded for an animated GIF, nil when not recording.
+var clip *capture.Clip
+
+// The -trace of every game played, nil when not tracing.
+var tracer *trace.Tracer
+
+// Bundled games, cycled with the arrow/volume keys. Loaded through the asset
+// package so they work on mobile too.
+var (
+	games     = []string{"brix.c8", "tetris.c8", "ufo.c8", "invaders.c8", "pong.c8", "joust.c8", "ant.c8", "alien.c8"}
+	gameIndex int
+	romPath   string
+)
 
-	// argsWithoutProg := os.Args[1:]
-	// if len(argsWithoutProg) > 0 {
-	// 	myChip8.LoadGame(argsWithoutProg[0])
-	// } else {
-	// 	myChip8.LoadGame("brix.c8")
-	// }
+var (
+	quirkProfile   = flag.String("quirks", "auto", "quirk profile: auto, vip, chip48, schip or xochip")
+	cyclesPerFrame = flag.Int("ipf", chip8.DefaultCyclesPerFrame, "instructions executed per 60Hz frame")
+	saveDir        = flag.String("saves", "saves", "directory for save states")
+	rewindSeconds  = flag.Int("rewind", 10, "seconds of play kept for rewinding")
+	toneFrequency  = flag.Float64("freq", audio.DefaultConfig.Frequency, "buzzer frequency in Hz")
+	toneVolume     = flag.Float64("volume", audio.DefaultConfig.Volume, "buzzer volume from 0 to 1")
+	toneWaveform   = flag.String("wave", "square", "buzzer waveform: square, triangle, sawtooth or sine")
+	keysPath       = flag.String("keys", "keys.json", "keyboard mapping config `file`, defaults are used if it doesn't exist")
+	writeKeys      = flag.Bool("writekeys", false, "write the keyboard mapping to the -keys file for editing, then exit")
+	randomSource   = flag.String("random", "seeded", "random numbers for CXNN: seeded or vip")
+	recordPath     = flag.String("record", "", "record the keys pressed in the first game to a movie `file`")
+	replayPath     = flag.String("replay", "", "replay a movie `file` recorded with -record")
+	shotDir        = flag.String("shots", "screenshots", "directory for screenshots and GIF clips")
+	shotScale      = flag.Int("scale", 8, "screenshot and clip pixels per CHIP-8 pixel")
+	clipFrames     = flag.Int("clip", 10*chip8.FrameRate, "longest GIF clip in frames")
+	colours        = flag.String("palette", capture.DefaultPalette.String(), "display colours, four hex RGB values for off, plane 1, plane 2 and both")
+	touchMode      = flag.String("touchpad", "auto", "on-screen keypad: on, off or auto (on for Android)")
+	invalidPolicy  = flag.String("invalid", "halt", "on unknown opcodes: halt, skip them, or sys to only run 0NNN as a no-op")
+	speed          = flag.Float64("speed", 1, "emulation speed, 2 runs twice as fast as the real thing")
+	fastForward    = flag.Float64("ff", 4, "speed while Tab is held")
+	traceOut       = flag.String("trace", "", "trace every instruction to `file` (- for stdout)")
+	traceFormat    = flag.String("trace-format", trace.JSON, "trace format: jsonl or binary")
+	tracePc        = flag.String("trace-pc", "", "only trace instructions in a hex address `range`, e.g. 200-2FF")
+	traceOps       = flag.String("trace-ops", "", "only trace opcodes starting with these hex `digits`, e.g. 8,D")
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() > 0 {
+		romPath = flag.Arg(0)
+	}
+	var err error
+	if keyConfig, err = keymap.LoadFile(*keysPath); err != nil {
+		log.Fatal(err)
+	}
+	if *writeKeys {
+		if err := keyConfig.SaveFile(*keysPath); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *touchMode != "on" && *touchMode != "off" && *touchMode != "auto" {
+		log.Fatalf("-touchpad %q should be on, off or auto", *touchMode)
+	}
+	if _, err := chip8.NewRandomSource(*randomSource, 0); err != nil {
+		log.Fatal(err)
+	}
+	if palette, err = capture.ParsePalette(*colours); err != nil {
+		log.Fatal(err)
+	}
+	machine := &chip8.Chip8{CyclesPerFrame: *cyclesPerFrame}
+	if machine.OnInvalid, err = chip8.ParseInvalidPolicy(*invalidPolicy); err != nil {
+		log.Fatal(err)
+	}
+	if *traceOut != "" {
+		filter, err := trace.ParseFilter(*tracePc, *traceOps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if tracer, err = trace.Create(*traceOut, *traceFormat); err != nil {
+			log.Fatal(err)
+		}
+		tracer.Filter = filter
+		tracer.Attach(machine)
+	}
+	emu = runner.New(machine)
+	emu.Tick = runFrame
+	emu.SetSpeed(*speed)
+	history = rewind.New(*rewindSeconds * chip8.FrameRate)
+	waveform, err := audio.ParseWaveform(*toneWaveform)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := startAudio(waveform); err != nil {
+		log.Print(err)
+	}
+	if err := loadGame(); err != nil {
+		log.Fatal(err)
+	}
+	emu.Do(func(c *chip8.Chip8) {
+		err = startMovie(c)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	//Run emulator on another go-routine
 	//Else emulator runs to slow on main thread.
-	go func() {
-		emuticker := time.NewTicker(time.Second / 360)
-		for {
-			myChip8.EmulateCycle()
-			<-emuticker.C
-		}
-	}()
+	//One frame per tick keeps the timers at 60Hz whatever -ipf is.
+	stop := make(chan struct{})
+	go emu.Run(stop)
 
 	app.Main(func(a app.App) {
 		var glctx gl.Context
@@ -87,120 +225,450 @@ func main() {
 					continue
 				}
 				onPaint(glctx, sz)
-				if myChip8.Draw_flag {
-					// drawGraphics() //for debugging
-
-					myChip8.Draw_flag = false
-				}
 
 				a.Publish()
 				// Drive the animation by preparing to paint the next frame
 				// after this one is shown.
 				a.Send(paint.Event{})
 			case key.Event:
-				fmt.Printf("You pressed key - ", e.Code)
+				fmt.Printf("You pressed key - %v\n", e.Code)
 				if e.Code == key.CodeEscape {
+					close(stop)
+					emu.Do(finish)
 					os.Exit(0)
+				}
+
+				//Input for emu. Keys the game uses win over the other bindings
+				var handled bool
+				emu.Do(func(c *chip8.Chip8) {
+					handled = keys.Handle(c, e)
+				})
+				if handled {
 					break
 				}
 
 				//Swap games on mobile
 				if (e.Code == key.CodeVolumeUp || e.Code == key.CodeRightArrow) && e.Direction == key.DirRelease {
-					myChip8.GameIndex += 1
-					if myChip8.GameIndex > len(myChip8.Games)-1 {
-						myChip8.GameIndex = 0
+					gameIndex += 1
+					if gameIndex > len(games)-1 {
+						gameIndex = 0
+					}
+					romPath = ""
+					if err := loadGame(); err != nil {
+						log.Print(err)
 					}
-					myChip8.Init()
 					break
 				}
 
 				if (e.Code == key.CodeVolumeDown || e.Code == key.CodeLeftArrow) && e.Direction == key.DirRelease {
-					myChip8.GameIndex -= 1
-					if myChip8.GameIndex < 0 {
-						myChip8.GameIndex = len(myChip8.Games) - 1
+					gameIndex -= 1
+					if gameIndex < 0 {
+						gameIndex = len(games) - 1
+					}
+					romPath = ""
+					if err := loadGame(); err != nil {
+						log.Print(err)
 					}
-					myChip8.Init()
 					break
 				}
-				//Input for emu
-				var keydown byte
-				keydown = 0
 
-				if e.Direction == key.DirPress {
-					keydown = 1
+				//Hold backspace to rewind
+				if e.Code == key.CodeDeleteBackspace {
+					emu.Do(func(c *chip8.Chip8) {
+						//The movie can't follow play going backwards
+						stopRecording()
+						rewinding = e.Direction != key.DirRelease
+					})
+					break
 				}
-				if e.Code == key.Code1 {
-					myChip8.Key[0x1] = keydown
-				} else if e.Code == key.Code2 {
-					myChip8.Key[0x2] = keydown
-				} else if e.Code == key.Code3 {
-					myChip8.Key[0x3] = keydown
-				} else if e.Code == key.Code4 {
-					myChip8.Key[0xC] = keydown
-				} else if e.Code == key.CodeQ {
-					myChip8.Key[0x4] = keydown
-				} else if e.Code == key.CodeW {
-					myChip8.Key[0x5] = keydown
-				} else if e.Code == key.CodeE {
-					myChip8.Key[0x6] = keydown
-				} else if e.Code == key.CodeR {
-					myChip8.Key[0xD] = keydown
-				} else if e.Code == key.CodeA {
-					myChip8.Key[0x7] = keydown
-				} else if e.Code == key.CodeS {
-					myChip8.Key[0x8] = keydown
-				} else if e.Code == key.CodeD {
-					myChip8.Key[0x9] = keydown
-				} else if e.Code == key.CodeF {
-					myChip8.Key[0xE] = keydown
-				} else if e.Code == key.CodeZ {
-					myChip8.Key[0xA] = keydown
-				} else if e.Code == key.CodeX {
-					myChip8.Key[0x0] = keydown
-				} else if e.Code == key.CodeC {
-					myChip8.Key[0xB] = keydown
-				} else if e.Code == key.CodeV {
-					myChip8.Key[0xF] = keydown
+
+				//Hold Tab to fast forward
+				if e.Code == key.CodeTab {
+					if e.Direction == key.DirRelease {
+						emu.SetSpeed(*speed)
+					} else {
+						emu.SetSpeed(*fastForward)
+					}
+					break
 				}
 
-			case touch.Event:
-				touchX = e.X
-				touchY = e.Y
+				//F5 pauses, F6 then advances a frame, or an instruction with
+				//Shift, and F7 starts the game over
+				if e.Direction == key.DirPress {
+					if handled, err := control(e); handled {
+						if err != nil {
+							log.Print(err)
+						}
+						break
+					}
+				}
 
-				if int(touchX) < sz.WidthPx/3 { //Left side
-					fmt.Printf("Touched - Q\n")
-					if e.Type == touch.TypeBegin {
-						myChip8.Key[0x4] = 1 //Q down,
-					} else if e.Type == touch.TypeEnd {
-						myChip8.Key[0x4] = 0 //Q down,
+				//Save states: Shift+F1-F4 saves to a slot, F1-F4 loads it
+				if slot, ok := saveSlots[e.Code]; ok {
+					if e.Direction != key.DirPress {
+						break
 					}
-					myChip8.Key[0x5] = 0 //E up
-					myChip8.Key[0x6] = 0 //E up
-					break
-				} else if int(touchX) < int(sz.WidthPx-sz.WidthPx/3) {
-					fmt.Printf("Touched - W\n")
-					if e.Type == touch.TypeBegin {
-						myChip8.Key[0x5] = 1 //W Down
-					} else if e.Type == touch.TypeEnd {
-						myChip8.Key[0x5] = 0 //W down,
+					var err error
+					emu.Do(func(c *chip8.Chip8) {
+						if e.Modifiers&key.ModShift != 0 {
+							err = saveState(c, slot)
+						} else {
+							err = loadState(c, slot)
+						}
+					})
+					if err != nil {
+						log.Print(err)
 					}
-					myChip8.Key[0x4] = 0 //Q down,
-					myChip8.Key[0x6] = 0 //Q down,
 					break
-				} else {
-					fmt.Printf("Touched - E\n")
-					if e.Type == touch.TypeBegin {
-						myChip8.Key[0x6] = 1 //E Down
-					} else if e.Type == touch.TypeEnd {
-						myChip8.Key[0x6] = 0 //Q down,
+				}
+
+				//F12 takes a screenshot, Shift+F12 starts and stops a GIF clip
+				if e.Code == key.CodeF12 && e.Direction == key.DirPress {
+					var err error
+					emu.Do(func(c *chip8.Chip8) {
+						if e.Modifiers&key.ModShift == 0 {
+							err = screenshot(c)
+						} else if clip == nil {
+							clip = capture.NewClip(palette, *shotScale)
+							fmt.Println("Recording clip")
+						} else {
+							stopClip(c)
+						}
+					})
+					if err != nil {
+						log.Print(err)
 					}
-					myChip8.Key[0x4] = 0 //Q down,
-					myChip8.Key[0x5] = 0 //Q down,
 					break
 				}
+
+			case touch.Event:
+				touchX = e.X
+				touchY = e.Y
+
+				//Fingers on the keypad press its keys
+				if pad != nil {
+					emu.Do(func(c *chip8.Chip8) {
+						pad.Handle(c, e)
+					})
+				}
 			}
 		}
 	})
+
+	close(stop)
+	emu.Do(finish)
+}
+
+// Writes out the movie, clip and trace being recorded and closes the audio
+// device, before quitting.
+func finish(c *chip8.Chip8) {
+	stopRecording()
+	if clip != nil {
+		stopClip(c)
+	}
+	if tracer != nil {
+		if err := tracer.Close(); err != nil {
+			log.Print(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		log.Print(err)
+	}
+	sink = audio.NullSink{}
+}
+
+// One frame of the game, or a step back while the rewind key is held. Run
+// by emu, on its goroutine.
+func runFrame(c *chip8.Chip8) error {
+	if rewinding {
+		if _, err := history.StepBack(c); err != nil {
+			log.Print(err)
+		}
+		return nil
+	}
+	if replaying != nil && !replaying.Apply(c) {
+		fmt.Println("Replay finished")
+		replaying = nil
+	}
+	if recording != nil {
+		recording.Capture(c)
+	}
+	//Report a crash once, skipped opcodes are what -invalid asked for
+	err := c.RunFrame()
+	if err != nil && c.Fault != crashed {
+		crashed = c.Fault
+		if crashed != nil {
+			log.Print(err)
+		}
+	}
+	if err := history.Capture(c); err != nil {
+		log.Print(err)
+	}
+	if err := sink.WriteSamples(synth.Frame(c)); err != nil {
+		log.Print(err)
+	}
+	if clip != nil {
+		clip.Add(c)
+		if clip.Len() >= *clipFrames {
+			stopClip(c)
+		}
+	}
+	return err
+}
+
+// Handles the keys that pause, step and reset the emulator, reporting
+// whether e was one of them.
+func control(e key.Event) (bool, error) {
+	switch e.Code {
+	case key.CodeF5:
+		if emu.Paused() {
+			emu.Resume()
+			fmt.Println("Resumed")
+		} else {
+			emu.Pause()
+			fmt.Println("Paused, F6 steps")
+		}
+		return true, nil
+	case key.CodeF6:
+		if !emu.Paused() {
+			return true, nil
+		}
+		if e.Modifiers&key.ModShift == 0 {
+			return true, emu.StepFrame()
+		}
+		//The movie only knows about whole frames
+		emu.Do(func(c *chip8.Chip8) {
+			stopRecording()
+		})
+		err := emu.Step()
+		emu.Do(func(c *chip8.Chip8) {
+			fmt.Printf("0x%03X: %04X\n", c.Pc, c.Opcode)
+		})
+		return true, err
+	case key.CodeF7:
+		return true, emu.Reset()
+	}
+	return false, nil
+}
+
+// Reset the machine and load either the ROM given on the command line or the
+// current bundled game. F7 runs setupGame again to start the game over.
+func loadGame() error {
+	return emu.Load(setupGame)
+}
+
+func setupGame(c *chip8.Chip8) error {
+	stopRecording()
+	replaying = nil
+	rewinding = false
+	history.Reset()
+	if err := loadRom(c); err != nil {
+		return err
+	}
+	random, err := chip8.NewRandomSource(*randomSource, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+	c.Random = random
+	profile, err := c.SetQuirkProfile(*quirkProfile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Using %s quirks\n", profile)
+	if showTouchpad() {
+		pad = touchpad.New(touchpad.LayoutFor(c.RomName, c.RomHash))
+	}
+	keys, err = keyConfig.Keymap(c.RomName, c.RomHash)
+	return err
+}
+
+func showTouchpad() bool {
+	switch *touchMode {
+	case "on":
+		return true
+	case "off":
+		return false
+	}
+	return runtime.GOOS == "android"
+}
+
+func loadRom(c *chip8.Chip8) error {
+	if romPath != "" {
+		fmt.Printf("Loading Game %s\n", romPath)
+		return c.LoadGame(romPath)
+	}
+
+	name := games[gameIndex]
+	fmt.Printf("Loading Game %s\n", name)
+	f, err := asset.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.LoadReader(name, f)
+}
+
+// Starts recording or replaying the game just loaded, as asked for by the
+// flags.
+func startMovie(c *chip8.Chip8) error {
+	if *replayPath != "" {
+		f, err := os.Open(*replayPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		m, err := movie.Read(f)
+		if err != nil {
+			return err
+		}
+		if err := m.Start(c); err != nil {
+			return err
+		}
+		fmt.Printf("Replaying %d frames\n", m.Len())
+		replaying = m
+	}
+	if *recordPath != "" {
+		// Recording a replay carries on from it
+		random, seed := *randomSource, time.Now().UnixNano()
+		if replaying != nil {
+			random, seed = replaying.Random, replaying.Seed
+		}
+		m, err := movie.Record(c, random, seed)
+		if err != nil {
+			return err
+		}
+		recording = m
+	}
+	return nil
+}
+
+// Writes the movie being recorded to the -record file and stops recording.
+// Called through emu.
+func stopRecording() {
+	if recording == nil {
+		return
+	}
+	m := recording
+	recording = nil
+	f, err := os.Create(*recordPath)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	defer f.Close()
+	if _, err := m.WriteTo(f); err != nil {
+		log.Print(err)
+		return
+	}
+	fmt.Printf("Recorded %d frames to %s\n", m.Len(), *recordPath)
+}
+
+// Sets up the buzzer, leaving the null sink in place if there is no sound
+// device.
+func startAudio(waveform audio.Waveform) error {
+	config := audio.DefaultConfig
+	config.Frequency = *toneFrequency
+	config.Volume = *toneVolume
+	config.Waveform = waveform
+	synth = audio.NewSynth(config)
+
+	realtime, err := audio.NewRealtimeSink(config.SampleRate)
+	if err != nil {
+		return err
+	}
+	sink = realtime
+	return nil
+}
+
+// Save state slots by hotkey.
+var saveSlots = map[key.Code]int{key.CodeF1: 1, key.CodeF2: 2, key.CodeF3: 3, key.CodeF4: 4}
+
+// Save files are named after the ROM hash so a state is never loaded into
+// the wrong game.
+func statePath(c *chip8.Chip8, slot int) string {
+	return filepath.Join(*saveDir, fmt.Sprintf("%x.%d.state", c.RomHash, slot))
+}
+
+func saveState(c *chip8.Chip8, slot int) error {
+	if err := os.MkdirAll(*saveDir, 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(statePath(c, slot))
+	if err != nil {
+		return err
+	}
+	if err := c.SaveState(f); err != nil {
+		f.Close()
+		return err
+	}
+	fmt.Printf("Saved slot %d\n", slot)
+	return f.Close()
+}
+
+func loadState(c *chip8.Chip8, slot int) error {
+	f, err := os.Open(statePath(c, slot))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := c.LoadState(f); err != nil {
+		return err
+	}
+	history.Reset()
+	stopRecording()
+	replaying = nil
+	fmt.Printf("Loaded slot %d\n", slot)
+	return nil
+}
+
+// Screenshots and clips are named after the game and the time they were
+// taken.
+func capturePath(c *chip8.Chip8, ext string) (string, error) {
+	if err := os.MkdirAll(*shotDir, 0755); err != nil {
+		return "", err
+	}
+	name := strings.TrimSuffix(filepath.Base(c.RomName), filepath.Ext(c.RomName))
+	return filepath.Join(*shotDir, name+time.Now().Format("-20060102-150405.000")+ext), nil
+}
+
+// Writes a PNG of the display. Called through emu.
+func screenshot(c *chip8.Chip8) error {
+	path, err := capturePath(c, ".png")
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := palette.WritePNG(f, c, *shotScale); err != nil {
+		f.Close()
+		return err
+	}
+	fmt.Printf("Saved %s\n", path)
+	return f.Close()
+}
+
+// Writes the clip being recorded and stops recording. Called through emu.
+func stopClip(c *chip8.Chip8) {
+	frames := clip
+	clip = nil
+	path, err := capturePath(c, ".gif")
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	defer f.Close()
+	if err := frames.WriteGIF(f); err != nil {
+		log.Print(err)
+		return
+	}
+	fmt.Printf("Saved %d frames to %s\n", frames.Len(), path)
 }
 
 func onStart(glctx gl.Context) {
@@ -221,10 +689,6 @@ func onStart(glctx gl.Context) {
 
 	images = glutil.NewImages(glctx)
 	fps = debug.NewFPS(images)
-
-	//Draw Buffer
-	img = *images.NewImage(64, 32)
-
 }
 
 func onStop(glctx gl.Context) {
@@ -243,21 +707,39 @@ func onPaint(glctx gl.Context, sz size.Event) {
 
 	glctx.BindBuffer(gl.ARRAY_BUFFER, buf)
 
+	//Draw Buffer, sized from the current resolution as SUPER-CHIP games can switch
+	frame := emu.Frame()
+	w, h := frame.Width, frame.Height
+	gfx := frame.Gfx
+	img = *images.NewImage(w, h)
+
+	//Turn the screen red once the game has crashed, so it doesn't look like
+	//it is just waiting for a key, and dim it while paused
+	colours := palette
+	for i := range colours {
+		c := &colours[i]
+		if frame.Fault != nil {
+			c.R, c.G, c.B = c.R/2+0x80, c.G/2, c.B/2
+		} else if frame.Paused {
+			c.R, c.G, c.B = c.R/2, c.G/2, c.B/2
+		}
+	}
+
 	//Draw Pixels onto screen
-	for i := 0; i < 64; i++ {
-		for j := 0; j < 32; j++ {
-			if myChip8.Gfx[(j*64)+i] == 0 {
-				img.RGBA.Set(i, j, image.Black)
-			}
+	for i := 0; i < w && len(gfx) == w*h; i++ {
+		for j := 0; j < h; j++ {
+			img.RGBA.SetRGBA(i, j, colours[gfx[(j*w)+i]&chip8.AllPlanes])
 
 		}
 	}
 
-	//Draw over whole screen
-	//Changed to widthPT which gives the real edge of the screen instead of pixels.
-	tl := geom.Point{0, 0}
-	tr := geom.Point{geom.Pt(sz.WidthPt), 0}
-	bl := geom.Point{0, geom.Pt(sz.HeightPt)}
+	//Draw over whole screen, or beside the keypad when it is showing
+	game := image.Rect(0, 0, sz.WidthPx, sz.HeightPx)
+	var padArea image.Rectangle
+	if pad != nil {
+		game, padArea = touchpad.Arrange(sz.WidthPx, sz.HeightPx)
+	}
+	tl, tr, bl := corners(sz, game)
 	img.Upload()
 
 	// Set up the texture
@@ -271,8 +753,25 @@ func onPaint(glctx gl.Context, sz size.Event) {
 
 	//cleanup every  frame
 	img.Release()
-	img = *images.NewImage(64, 32)
 
+	if pad != nil && !padArea.Empty() {
+		pad.Bounds = padArea
+		padImg := images.NewImage(padArea.Dx(), padArea.Dy())
+		pad.Draw(padImg.RGBA)
+		padImg.Upload()
+		tl, tr, bl := corners(sz, padArea)
+		padImg.Draw(sz, tl, tr, bl, padImg.RGBA.Bounds())
+		padImg.Release()
+	}
+}
+
+// The corners of a rectangle of screen pixels in points, as glutil draws in.
+func corners(sz size.Event, r image.Rectangle) (tl, tr, bl geom.Point) {
+	pt := func(px int) geom.Pt { return geom.Pt(float32(px) / sz.PixelsPerPt) }
+	tl = geom.Point{X: pt(r.Min.X), Y: pt(r.Min.Y)}
+	tr = geom.Point{X: pt(r.Max.X), Y: pt(r.Min.Y)}
+	bl = geom.Point{X: pt(r.Min.X), Y: pt(r.Max.Y)}
+	return tl, tr, bl
 }
 
 const squareoffset = 0.057
@@ -306,22 +805,3 @@ uniform vec4 color;
 void main() {
 	gl_FragColor = color;
 }`
-
-//Temporarily draw straight to terminal, replce with a OPEN GL draw later. Pref with goMobile package.
-func drawGraphics() {
-	fmt.Printf("\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n")
-	//y loop, 32 scan lines,x 64 pixels in each scan line
-	for y := 0; y < 32; y++ {
-		for x := 0; x < 64; x++ {
-			if myChip8.Gfx[(y*64)+x] == 0 {
-				//Black pixel
-				fmt.Printf("x")
-			} else {
-				// White pixel
-				fmt.Printf("o")
-			}
-		}
-		fmt.Printf("\n")
-	}
-
-}
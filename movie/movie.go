@@ -0,0 +1,274 @@
+// Package movie records the keys pressed in each frame of a game so the
+// session can be replayed exactly, for reproducing bugs and as regression
+// tests.
+//
+// A movie starts from power on: the ROM just loaded, with the quirks, speed,
+// random number source and seed it was recorded with. Given those the machine is
+// deterministic, so the held keys are all that has to be stored per frame.
+package movie
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+// Movie files are a header followed by the frames:
+//
+//	magic           [4]byte  "C8MV"
+//	version         uint16   Version
+//	RomHash         [20]byte
+//	name length     uint16, then RomName
+//	Quirks          uint32, chip8.Quirks.Bits
+//	CyclesPerFrame  uint32
+//	Seed            int64
+//	Random          uint16 length, then the chip8.NewRandomSource name
+//	frames          uint32 number of frames
+//	runs            a uvarint count of frames and the uint16 keys held in
+//	                them, bit k for key k, until every frame is covered
+//
+// Integers are big endian. Version 1 stored Quirks as a byte per field of
+// chip8.Quirks as it was then, 1 if set, which broke whenever a field was
+// added. Versions 1 and 2 have no Random, they always used "seeded".
+const (
+	Version   = 3
+	magic     = "C8MV"
+	maxFrames = 24 * 60 * 60 * chip8.FrameRate // A day's play
+)
+
+// Reasons a movie can fail to load or play.
+var (
+	ErrInvalid  = errors.New("not a movie")
+	ErrVersion  = errors.New("movie needs a newer version")
+	ErrWrongRom = errors.New("movie was recorded with a different ROM")
+	ErrStarted  = errors.New("movies play from power on, the machine has already run")
+)
+
+// Movie is a recorded session.
+type Movie struct {
+	RomName        string
+	RomHash        [sha1.Size]byte
+	Quirks         chip8.Quirks
+	CyclesPerFrame int
+	Seed           int64
+	Random         string   // Name of the random number source, for chip8.NewRandomSource
+	Keys           []uint16 // Keys held in each frame, bit k for key k
+}
+
+// Record starts a movie of c, which should have just loaded its ROM. c's
+// random numbers come from the source random, as named for
+// chip8.NewRandomSource, started from seed. Call Capture before every
+// RunFrame.
+func Record(c *chip8.Chip8, random string, seed int64) (*Movie, error) {
+	source, err := chip8.NewRandomSource(random, seed)
+	if err != nil {
+		return nil, err
+	}
+	c.Random = source
+	return &Movie{
+		RomName:        c.RomName,
+		RomHash:        c.RomHash,
+		Quirks:         c.Quirks,
+		CyclesPerFrame: c.CyclesPerFrame,
+		Seed:           seed,
+		Random:         random,
+	}, nil
+}
+
+// Capture records the keys held for the frame c is about to run.
+func (self *Movie) Capture(c *chip8.Chip8) {
+	var keys uint16
+	for k, held := range c.Key {
+		if held != 0 {
+			keys |= 1 << uint(k)
+		}
+	}
+	self.Keys = append(self.Keys, keys)
+}
+
+// Len is the number of frames recorded.
+func (self *Movie) Len() int {
+	return len(self.Keys)
+}
+
+// Start sets up c, freshly loaded with the movie's ROM, to replay it. Call
+// Apply before every RunFrame.
+func (self *Movie) Start(c *chip8.Chip8) error {
+	if c.RomHash != self.RomHash {
+		return fmt.Errorf("movie: %w: %s", ErrWrongRom, self.RomName)
+	}
+	if c.Frame != 0 {
+		return fmt.Errorf("movie: %w", ErrStarted)
+	}
+	source, err := chip8.NewRandomSource(self.Random, self.Seed)
+	if err != nil {
+		return fmt.Errorf("movie: %v", err)
+	}
+	c.Quirks = self.Quirks
+	c.CyclesPerFrame = self.CyclesPerFrame
+	c.Random = source
+	return nil
+}
+
+// Apply sets the keys recorded for the frame c is about to run, reporting
+// false once the movie is over.
+func (self *Movie) Apply(c *chip8.Chip8) bool {
+	if c.Frame >= uint64(len(self.Keys)) {
+		return false
+	}
+	keys := self.Keys[c.Frame]
+	for k := range c.Key {
+		c.Key[k] = byte(keys >> uint(k) & 1)
+	}
+	return true
+}
+
+// Play replays the whole movie on c, freshly loaded with the movie's ROM.
+// It stops early if the machine halts, returning its Fault along with the
+// frame it happened in, or nil if the program exited.
+func (self *Movie) Play(c *chip8.Chip8) error {
+	if err := self.Start(c); err != nil {
+		return err
+	}
+	for self.Apply(c) {
+		frame := c.Frame
+		c.RunFrame()
+		if c.Halted {
+			if c.Fault == nil {
+				return nil
+			}
+			return fmt.Errorf("movie: frame %d: %w", frame, c.Fault)
+		}
+	}
+	return nil
+}
+
+// WriteTo writes the movie in the format read by Read.
+func (self *Movie) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	put := func(v interface{}) {
+		binary.Write(&out, binary.BigEndian, v)
+	}
+	out.WriteString(magic)
+	put(uint16(Version))
+	put(self.RomHash)
+	put(uint16(len(self.RomName)))
+	out.WriteString(self.RomName)
+	put(self.Quirks.Bits())
+	put(uint32(self.CyclesPerFrame))
+	put(self.Seed)
+	put(uint16(len(self.Random)))
+	out.WriteString(self.Random)
+	put(uint32(len(self.Keys)))
+
+	var n [binary.MaxVarintLen64]byte
+	for i := 0; i < len(self.Keys); {
+		run := 1
+		for i+run < len(self.Keys) && self.Keys[i+run] == self.Keys[i] {
+			run++
+		}
+		out.Write(n[:binary.PutUvarint(n[:], uint64(run))])
+		put(self.Keys[i])
+		i += run
+	}
+	return out.WriteTo(w)
+}
+
+// Read reads a movie written by WriteTo.
+func Read(r io.Reader) (*Movie, error) {
+	in := bufio.NewReader(r)
+	var head [6]byte
+	if _, err := io.ReadFull(in, head[:]); err != nil || string(head[:4]) != magic {
+		return nil, fmt.Errorf("movie: %w", ErrInvalid)
+	}
+	version := binary.BigEndian.Uint16(head[4:])
+	if version > Version {
+		return nil, fmt.Errorf("movie: %w: format %d, this reads up to %d", ErrVersion, version, Version)
+	}
+
+	m := &Movie{}
+	var nameLen uint16
+	var cycles, frames uint32
+	for _, v := range []interface{}{&m.RomHash, &nameLen} {
+		if err := binary.Read(in, binary.BigEndian, v); err != nil {
+			return nil, truncated(err)
+		}
+	}
+	var err error
+	if m.RomName, err = readString(in, nameLen); err != nil {
+		return nil, err
+	}
+	var quirks uint32
+	if version == 1 {
+		var fields [6]byte
+		if _, err := io.ReadFull(in, fields[:]); err != nil {
+			return nil, truncated(err)
+		}
+		for i, set := range fields {
+			quirks |= uint32(set&1) << uint(i) // The fields were in Bits order
+		}
+	} else if err := binary.Read(in, binary.BigEndian, &quirks); err != nil {
+		return nil, truncated(err)
+	}
+	m.Quirks = chip8.QuirksFromBits(quirks)
+	for _, v := range []interface{}{&cycles, &m.Seed} {
+		if err := binary.Read(in, binary.BigEndian, v); err != nil {
+			return nil, truncated(err)
+		}
+	}
+	m.Random = "seeded"
+	if version >= 3 {
+		if err := binary.Read(in, binary.BigEndian, &nameLen); err != nil {
+			return nil, truncated(err)
+		}
+		if m.Random, err = readString(in, nameLen); err != nil {
+			return nil, err
+		}
+	}
+	if err := binary.Read(in, binary.BigEndian, &frames); err != nil {
+		return nil, truncated(err)
+	}
+	m.CyclesPerFrame = int(cycles)
+	if frames > maxFrames {
+		return nil, fmt.Errorf("movie: %w: %d frames is too long", ErrInvalid, frames)
+	}
+
+	for len(m.Keys) < int(frames) {
+		run, err := binary.ReadUvarint(in)
+		if err != nil {
+			return nil, truncated(err)
+		}
+		var keys uint16
+		if err := binary.Read(in, binary.BigEndian, &keys); err != nil {
+			return nil, truncated(err)
+		}
+		if run == 0 || run > uint64(int(frames)-len(m.Keys)) {
+			return nil, fmt.Errorf("movie: %w: bad run of %d frames", ErrInvalid, run)
+		}
+		for ; run > 0; run-- {
+			m.Keys = append(m.Keys, keys)
+		}
+	}
+	return m, nil
+}
+
+func readString(in io.Reader, n uint16) (string, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(in, b); err != nil {
+		return "", truncated(err)
+	}
+	return string(b), nil
+}
+
+func truncated(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("movie: %w: %v", ErrInvalid, err)
+}
@@ -0,0 +1,171 @@
+package movie_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/movie"
+)
+
+func load(t *testing.T, rom string) *chip8.Chip8 {
+	t.Helper()
+	c := &chip8.Chip8{}
+	c.Init()
+	if err := c.LoadGame(rom); err != nil {
+		t.Fatal(err)
+	}
+	c.SetQuirkProfile("auto")
+	return c
+}
+
+// Plays brix for a few seconds, moving the paddle back and forth.
+func record(t *testing.T) (*movie.Movie, *chip8.Chip8) {
+	t.Helper()
+	return recordWith(t, "seeded")
+}
+
+func recordWith(t *testing.T, random string) (*movie.Movie, *chip8.Chip8) {
+	t.Helper()
+	c := load(t, "../assets/brix.c8")
+	m, err := movie.Record(c, random, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for frame := 0; frame < 300; frame++ {
+		c.Key[4] = byte(frame / 40 % 2)
+		c.Key[6] = 1 - c.Key[4]
+		m.Capture(c)
+		c.RunFrame()
+	}
+	return m, c
+}
+
+func TestReplay(t *testing.T) {
+	for _, random := range []string{"seeded", "vip"} {
+		t.Run(random, func(t *testing.T) {
+			testReplay(t, random)
+		})
+	}
+}
+
+func testReplay(t *testing.T, random string) {
+	m, recorded := recordWith(t, random)
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 200 {
+		t.Errorf("300 frames took %d bytes", buf.Len())
+	}
+
+	read, err := movie.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, read) {
+		t.Fatalf("read %+v, wrote %+v", read, m)
+	}
+
+	c := load(t, "../assets/brix.c8")
+	if err := read.Play(c); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, recorded) {
+		t.Error("replay ended in a different state from the recording")
+	}
+}
+
+func TestReplayChecks(t *testing.T) {
+	m, _ := record(t)
+	if err := m.Start(load(t, "../assets/pong.c8")); !errors.Is(err, movie.ErrWrongRom) {
+		t.Errorf("replaying on another ROM: %v", err)
+	}
+	c := load(t, "../assets/brix.c8")
+	c.RunFrame()
+	if err := m.Start(c); !errors.Is(err, movie.ErrStarted) {
+		t.Errorf("replaying on a running game: %v", err)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	m, _ := record(t)
+	var buf bytes.Buffer
+	m.WriteTo(&buf)
+	good := buf.Bytes()
+	newer := append([]byte(nil), good...)
+	newer[5] = movie.Version + 1
+
+	cases := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, movie.ErrInvalid},
+		{"not a movie", []byte("C8SS save state"), movie.ErrInvalid},
+		{"truncated", good[:len(good)-1], movie.ErrInvalid},
+		{"newer", newer, movie.ErrVersion},
+	}
+	for _, tc := range cases {
+		if _, err := movie.Read(bytes.NewReader(tc.data)); !errors.Is(err, tc.err) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
+
+// Version 1 movies stored the quirks a byte per field
+func TestReadVersion1(t *testing.T) {
+	f, err := os.Open("testdata/brix-v1.c8m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	old, err := movie.Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, recorded := record(t)
+	if !reflect.DeepEqual(old, m) {
+		t.Fatalf("read %+v, want %+v", old, m)
+	}
+	c := load(t, "../assets/brix.c8")
+	if err := old.Play(c); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, recorded) {
+		t.Error("version 1 movie replayed differently")
+	}
+}
+
+// Waits for key 1, then runs FFFF
+var crashProgram = []byte{0x60, 0x01, 0xE0, 0x9E, 0x12, 0x02, 0xFF, 0xFF}
+
+func TestPlayStopsAtFault(t *testing.T) {
+	c := &chip8.Chip8{}
+	c.Init()
+	c.LoadBytes("crash", crashProgram)
+	m, err := movie.Record(c, "seeded", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for frame := 0; frame < 20; frame++ {
+		c.Key[1] = byte(frame / 5 % 2)
+		m.Capture(c)
+		c.RunFrame()
+	}
+
+	c.Init()
+	c.LoadBytes("crash", crashProgram)
+	err = m.Play(c)
+	var opErr *chip8.OpcodeError
+	if !errors.As(err, &opErr) || !errors.Is(err, chip8.ErrUnknownOpcode) || opErr.Pc != 0x206 {
+		t.Fatalf("got %v", err)
+	}
+	if c.Frame != 6 || !strings.Contains(err.Error(), "frame 5") {
+		t.Errorf("stopped after frame %d: %v", c.Frame, err)
+	}
+}
@@ -0,0 +1,169 @@
+// Package rewind records the recent history of a Chip8 so it can be played
+// backwards a frame at a time.
+//
+// Every captured frame is stored as a save state delta-compressed against
+// the one after it. Only the newest state is kept whole, stepping back
+// applies the newest delta to it, so no keyframes are needed and the oldest
+// frames can simply be dropped when the buffer is full.
+package rewind
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+// Buffer is a bounded ring of per-frame snapshots.
+type Buffer struct {
+	latest []byte   // The newest state, whole
+	deltas [][]byte // Ring of deltas, each turning a state into the one before
+	head   int      // Index of the oldest delta
+	count  int
+	size   int // Bytes held by deltas
+}
+
+var errCorrupt = errors.New("rewind: corrupt delta")
+
+// New makes a buffer that can step back up to frames frames.
+func New(frames int) *Buffer {
+	if frames < 1 {
+		frames = 1
+	}
+	return &Buffer{deltas: make([][]byte, frames)}
+}
+
+// Len is the number of frames that can be stepped back.
+func (self *Buffer) Len() int {
+	return self.count
+}
+
+// Size is the memory used by the compressed history in bytes.
+func (self *Buffer) Size() int {
+	return self.size + len(self.latest)
+}
+
+// Reset forgets the history, for when a different game is loaded.
+func (self *Buffer) Reset() {
+	for i := range self.deltas {
+		self.deltas[i] = nil
+	}
+	self.latest, self.head, self.count, self.size = nil, 0, 0, 0
+}
+
+// Capture records the state of c, normally once a frame after RunFrame.
+func (self *Buffer) Capture(c *chip8.Chip8) error {
+	var buf bytes.Buffer
+	if err := c.SaveState(&buf); err != nil {
+		return err
+	}
+	state := buf.Bytes()
+	if self.latest != nil {
+		delta := encode(state, self.latest)
+		if self.count == len(self.deltas) {
+			self.size -= len(self.deltas[self.head])
+			self.deltas[self.head] = nil
+			self.head = (self.head + 1) % len(self.deltas)
+			self.count--
+		}
+		self.deltas[(self.head+self.count)%len(self.deltas)] = delta
+		self.count++
+		self.size += len(delta)
+	}
+	self.latest = state
+	return nil
+}
+
+// StepBack drops the newest frame and restores c to the one captured before
+// it. It reports false, leaving c alone, when there is no earlier frame.
+// Running on from there and capturing again replaces the dropped frames.
+func (self *Buffer) StepBack(c *chip8.Chip8) (bool, error) {
+	if self.count == 0 {
+		return false, nil
+	}
+	i := (self.head + self.count - 1) % len(self.deltas)
+	prev, err := decode(self.latest, self.deltas[i])
+	if err != nil {
+		return false, err
+	}
+	if err := c.LoadState(bytes.NewReader(prev)); err != nil {
+		return false, err
+	}
+	self.size -= len(self.deltas[i])
+	self.deltas[i] = nil
+	self.count--
+	self.latest = prev
+	return true, nil
+}
+
+// Deltas are the length of the older state followed by runs of the two
+// states XORed together: a count of zero bytes, a count of literal bytes and
+// the literal bytes, each count a uvarint. Consecutive frames mostly differ
+// in a few registers and bytes of memory so the zero runs dominate.
+func encode(newer, older []byte) []byte {
+	var out []byte
+	var tmp [binary.MaxVarintLen64]byte
+	uvarint := func(v int) {
+		out = append(out, tmp[:binary.PutUvarint(tmp[:], uint64(v))]...)
+	}
+	uvarint(len(older))
+	at := func(b []byte, i int) byte {
+		if i < len(b) {
+			return b[i]
+		}
+		return 0
+	}
+	n := len(older)
+	if len(newer) > n {
+		n = len(newer)
+	}
+	for i := 0; i < n; {
+		zeros := i
+		for i < n && at(newer, i) == at(older, i) {
+			i++
+		}
+		start := i
+		// Short runs of matches are cheaper kept in the literal
+		for i < n && (at(newer, i) != at(older, i) || (i+1 < n && at(newer, i+1) != at(older, i+1))) {
+			i++
+		}
+		uvarint(start - zeros)
+		uvarint(i - start)
+		for j := start; j < i; j++ {
+			out = append(out, at(newer, j)^at(older, j))
+		}
+	}
+	return out
+}
+
+// Turns newer back into the older state encode was given.
+func decode(newer, delta []byte) ([]byte, error) {
+	r := bytes.NewReader(delta)
+	size, err := binary.ReadUvarint(r)
+	if err != nil || size > 1<<24 {
+		return nil, errCorrupt
+	}
+	n := int(size)
+	if len(newer) > n {
+		n = len(newer)
+	}
+	out := make([]byte, n)
+	copy(out, newer)
+	for i := 0; r.Len() > 0; {
+		zeros, err1 := binary.ReadUvarint(r)
+		literal, err2 := binary.ReadUvarint(r)
+		if err1 != nil || err2 != nil || uint64(i)+zeros+literal > uint64(n) {
+			return nil, errCorrupt
+		}
+		i += int(zeros)
+		for end := i + int(literal); i < end; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, errCorrupt
+			}
+			out[i] ^= b
+		}
+	}
+	return out[:size], nil
+}
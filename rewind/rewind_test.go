@@ -0,0 +1,93 @@
+package rewind_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/rewind"
+)
+
+func state(t *testing.T, c *chip8.Chip8) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := c.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newGame(t *testing.T) *chip8.Chip8 {
+	t.Helper()
+	c := &chip8.Chip8{}
+	c.Init()
+	if err := c.LoadGame("../assets/brix.c8"); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestStepBackRestoresEveryFrame(t *testing.T) {
+	c := newGame(t)
+	b := rewind.New(100)
+	var history [][]byte
+	for i := 0; i < 150; i++ {
+		c.RunFrame()
+		if err := b.Capture(c); err != nil {
+			t.Fatal(err)
+		}
+		history = append(history, state(t, c))
+	}
+	if b.Len() != 100 {
+		t.Fatalf("buffer should hold 100 frames, has %d", b.Len())
+	}
+	if full := len(history[0]) * 100; b.Size() > full/10 {
+		t.Errorf("history takes %d bytes, expected deltas well under %d", b.Size(), full/10)
+	}
+
+	for i := len(history) - 2; i >= len(history)-101; i-- {
+		ok, err := b.StepBack(c)
+		if err != nil || !ok {
+			t.Fatalf("step back to frame %d: %v %v", i, ok, err)
+		}
+		if !bytes.Equal(state(t, c), history[i]) {
+			t.Fatalf("frame %d not restored exactly", i)
+		}
+	}
+	if ok, _ := b.StepBack(c); ok {
+		t.Error("stepped back past the oldest frame")
+	}
+}
+
+func TestResumeAfterRewind(t *testing.T) {
+	// A program with no random numbers: counts up in V0 and memory forever
+	rom := []byte{
+		0x70, 0x01, // 0x200 V0 += 1
+		0xA3, 0x00, // 0x202 I := 0x300
+		0xF0, 0x55, // 0x204 save V0
+		0x12, 0x00, // 0x206 jump 0x200
+	}
+	c := &chip8.Chip8{}
+	c.Init()
+	c.LoadBytes("count", rom)
+	b := rewind.New(60)
+	var history [][]byte
+	for i := 0; i < 60; i++ {
+		c.RunFrame()
+		b.Capture(c)
+		history = append(history, state(t, c))
+	}
+	for i := 0; i < 20; i++ {
+		b.StepBack(c)
+	}
+	for i := 40; i < 60; i++ {
+		c.RunFrame()
+		b.Capture(c)
+		if !bytes.Equal(state(t, c), history[i]) {
+			t.Fatalf("frame %d differs after resuming", i)
+		}
+	}
+	if b.Len() != 59 {
+		t.Errorf("expected 59 frames of history, got %d", b.Len())
+	}
+}
@@ -0,0 +1,220 @@
+// Package runner drives a Chip8 at 60 frames a second on its own goroutine
+// while other goroutines control it and draw it.
+//
+// The Runner owns the machine. Everything else reaches it through Do, or the
+// pause, step, reset and speed methods, which all take the same lock as the
+// run loop, and draws from the Frame snapshots the loop publishes, which are
+// never modified once published.
+package runner
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+// Frame is a copy of the display and the state a renderer needs, taken after
+// each frame and after anything else that changes the machine.
+type Frame struct {
+	Gfx           []byte // Width*Height pixels, see chip8.Chip8.Gfx
+	Width, Height int
+	Number        uint64 // chip8.Chip8.Frame when it was taken
+	Paused        bool
+	Halted        bool
+	Fault         *chip8.OpcodeError
+}
+
+// Speeds outside these are clamped by SetSpeed.
+const (
+	MinSpeed = 0.1
+	MaxSpeed = 16
+)
+
+// Runner runs a Chip8, see the package comment.
+type Runner struct {
+	// Tick runs one frame, c.RunFrame if nil. Hosts use it to record, play
+	// movies or rewind alongside the machine. Set it before Run.
+	Tick func(c *chip8.Chip8) error
+
+	mu     sync.Mutex
+	chip   *chip8.Chip8
+	load   func(c *chip8.Chip8) error // The last Load, for Reset
+	paused bool
+	speed  float64
+
+	frame atomic.Value // *Frame
+}
+
+// New makes a runner for c, running at normal speed. Only the runner may
+// touch c afterwards.
+func New(c *chip8.Chip8) *Runner {
+	self := &Runner{chip: c, speed: 1}
+	self.publish()
+	return self
+}
+
+// Run runs frames until stop is closed, CyclesPerFrame instructions and a
+// timer tick every 60th of a second at normal speed. Nothing runs while
+// paused.
+func (self *Runner) Run(stop <-chan struct{}) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	next := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+
+		self.mu.Lock()
+		if !self.paused {
+			self.tick()
+		}
+		next = next.Add(time.Duration(float64(time.Second) / chip8.FrameRate / self.speed))
+		self.mu.Unlock()
+
+		// Don't race to catch up after falling far behind, e.g. after the
+		// computer slept
+		wait := time.Until(next)
+		if wait < -time.Second/4 {
+			next, wait = time.Now(), 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+// Runs a frame and publishes it. Called with mu held.
+func (self *Runner) tick() error {
+	var err error
+	if self.Tick != nil {
+		err = self.Tick(self.chip)
+	} else {
+		err = self.chip.RunFrame()
+	}
+	self.publish()
+	return err
+}
+
+// Takes a new snapshot of the machine. Called with mu held.
+func (self *Runner) publish() {
+	c := self.chip
+	self.frame.Store(&Frame{
+		Gfx:    append([]byte(nil), c.Gfx...),
+		Width:  c.Width(),
+		Height: c.Height(),
+		Number: c.Frame,
+		Paused: self.paused,
+		Halted: c.Halted,
+		Fault:  c.Fault,
+	})
+}
+
+// Frame returns the latest snapshot. It is shared, so must not be modified.
+func (self *Runner) Frame() *Frame {
+	return self.frame.Load().(*Frame)
+}
+
+// Do calls f with the machine, between frames, then publishes a new
+// snapshot in case f changed it. f must not keep c or call back into the
+// runner.
+func (self *Runner) Do(f func(c *chip8.Chip8)) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	f(self.chip)
+	self.publish()
+}
+
+// Load initialises the machine and calls load to set it up, e.g. to load a
+// ROM and pick its quirks. Reset calls it again. It is called with the
+// runner locked, so like Do's f it must not call back into the runner.
+func (self *Runner) Load(load func(c *chip8.Chip8) error) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.load = load
+	return self.reset()
+}
+
+// Reset starts the game over with the last Load, or just initialises the
+// machine if there hasn't been one. Pausing is unaffected.
+func (self *Runner) Reset() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.reset()
+}
+
+func (self *Runner) reset() error {
+	self.chip.Init()
+	var err error
+	if self.load != nil {
+		err = self.load(self.chip)
+	}
+	self.publish()
+	return err
+}
+
+// Pause stops Run running frames until Resume.
+func (self *Runner) Pause() {
+	self.setPaused(true)
+}
+
+// Resume carries on after Pause.
+func (self *Runner) Resume() {
+	self.setPaused(false)
+}
+
+func (self *Runner) setPaused(paused bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.paused = paused
+	self.publish()
+}
+
+// Paused reports whether the runner is paused.
+func (self *Runner) Paused() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.paused
+}
+
+// Step runs a single instruction with chip8.Chip8.Step, ticking the timers
+// after every CyclesPerFrame steps the way a frame does. Meant for use while
+// paused, Tick isn't called.
+func (self *Runner) Step() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	err := self.chip.Step()
+	self.publish()
+	return err
+}
+
+// StepFrame runs a single frame through Tick, for frame advance while
+// paused.
+func (self *Runner) StepFrame() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.tick()
+}
+
+// SetSpeed runs frames speed times faster than normal, e.g. 2 to fast
+// forward or 0.5 for slow motion. The machine runs exactly as it would at
+// normal speed, only the time between frames changes.
+func (self *Runner) SetSpeed(speed float64) {
+	if !(speed >= MinSpeed) { // NaN too
+		speed = MinSpeed
+	} else if speed > MaxSpeed {
+		speed = MaxSpeed
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.speed = speed
+}
+
+// Speed returns the speed set by SetSpeed, 1 by default.
+func (self *Runner) Speed() float64 {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.speed
+}
@@ -0,0 +1,174 @@
+package runner_test
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/runner"
+)
+
+// 6000 7001 A20A D015 1202 with the sprite byte after it: counts up in V0
+// and draws every frame
+var program = []byte{0x60, 0x00, 0x70, 0x01, 0xA2, 0x0A, 0xD0, 0x15, 0x12, 0x02, 0xF0}
+
+func newRunner(t *testing.T) *runner.Runner {
+	t.Helper()
+	r := runner.New(&chip8.Chip8{})
+	if err := r.Load(func(c *chip8.Chip8) error {
+		return c.LoadBytes("test", program)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestStepFrame(t *testing.T) {
+	r := newRunner(t)
+	r.Pause()
+	if !r.Frame().Paused {
+		t.Error("snapshot should show the pause")
+	}
+	before := r.Frame()
+	if err := r.StepFrame(); err != nil {
+		t.Fatal(err)
+	}
+	after := r.Frame()
+	if before.Number != 0 || after.Number != 1 {
+		t.Errorf("frames %d then %d", before.Number, after.Number)
+	}
+	if len(after.Gfx) != after.Width*after.Height || after.Width != chip8.LoResWidth {
+		t.Errorf("snapshot is %dx%d with %d pixels", after.Width, after.Height, len(after.Gfx))
+	}
+	// Snapshots are copies, the machine moving on doesn't change them
+	if lit(after) == 0 || lit(before) != 0 {
+		t.Errorf("%d pixels lit before the frame, %d after", lit(before), lit(after))
+	}
+}
+
+func lit(f *runner.Frame) int {
+	n := 0
+	for _, p := range f.Gfx {
+		if p != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestStep(t *testing.T) {
+	r := newRunner(t)
+	r.Pause()
+	r.Do(func(c *chip8.Chip8) {
+		c.CyclesPerFrame = 3
+		c.Delay_timer = 5
+	})
+	for i := 0; i < 3; i++ {
+		if err := r.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.Do(func(c *chip8.Chip8) {
+		if c.Pc != 0x206 || c.V[0] != 1 {
+			t.Errorf("Pc %03X V0 %d after 3 steps", c.Pc, c.V[0])
+		}
+		if c.Delay_timer != 4 {
+			t.Errorf("timers should tick every CyclesPerFrame steps, delay %d", c.Delay_timer)
+		}
+	})
+}
+
+func TestStepReportsFault(t *testing.T) {
+	r := runner.New(&chip8.Chip8{})
+	r.Load(func(c *chip8.Chip8) error {
+		return c.LoadBytes("test", []byte{0xFF, 0xFF})
+	})
+	if err := r.Step(); err == nil || r.Frame().Fault == nil || !r.Frame().Halted {
+		t.Errorf("got %v, frame %+v", err, r.Frame())
+	}
+}
+
+func TestReset(t *testing.T) {
+	loads := 0
+	r := runner.New(&chip8.Chip8{})
+	r.Load(func(c *chip8.Chip8) error {
+		loads++
+		return c.LoadBytes("test", program)
+	})
+	r.StepFrame()
+	r.StepFrame()
+	if err := r.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if loads != 2 || r.Frame().Number != 0 {
+		t.Errorf("%d loads, frame %d after reset", loads, r.Frame().Number)
+	}
+}
+
+func TestSetSpeed(t *testing.T) {
+	r := newRunner(t)
+	if r.Speed() != 1 {
+		t.Errorf("default speed %v", r.Speed())
+	}
+	for _, c := range []struct{ set, want float64 }{{2, 2}, {0, runner.MinSpeed}, {1000, runner.MaxSpeed}, {math.NaN(), runner.MinSpeed}} {
+		r.SetSpeed(c.set)
+		if got := r.Speed(); got != c.want {
+			t.Errorf("SetSpeed(%v) gave %v, want %v", c.set, got, c.want)
+		}
+	}
+}
+
+// Run with go test -race: everything a frontend does from its own goroutines
+// while the runner is running frames.
+func TestConcurrentUse(t *testing.T) {
+	r := newRunner(t)
+	r.SetSpeed(runner.MaxSpeed)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		r.Run(stop)
+		close(done)
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() { // Renderer
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			lit(r.Frame())
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	go func() { // Keyboard
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			r.Do(func(c *chip8.Chip8) {
+				c.Key[i%16] ^= 1
+			})
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	go func() { // Controls
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			r.Pause()
+			r.Step()
+			r.StepFrame()
+			r.Resume()
+			r.SetSpeed(float64(i + 1))
+			if i == 5 {
+				r.Reset()
+			}
+			time.Sleep(2 * time.Millisecond)
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	<-done
+
+	if r.Frame().Number == 0 {
+		t.Error("no frames ran")
+	}
+}
@@ -0,0 +1,179 @@
+// Package touchpad is an on-screen CHIP-8 keypad for touch screens. It
+// lays the buttons out, tracks every finger separately so several keys can
+// be held at once, and draws the pad with pressed buttons highlighted.
+package touchpad
+
+import (
+	"encoding/hex"
+	"image"
+	"image/color"
+	"path/filepath"
+
+	"github.com/bomer/chip8/chip8"
+	"golang.org/x/mobile/event/touch"
+)
+
+// Layout is a grid of buttons, one row of CHIP-8 keys per entry.
+type Layout [][]byte
+
+// HexLayout is the full keypad as laid out on the COSMAC VIP.
+var HexLayout = Layout{
+	{0x1, 0x2, 0x3, 0xC},
+	{0x4, 0x5, 0x6, 0xD},
+	{0x7, 0x8, 0x9, 0xE},
+	{0xA, 0x0, 0xB, 0xF},
+}
+
+// RomLayouts are smaller pads with just the keys a game uses, by ROM file
+// name or SHA-1 in hex, so each button can be bigger.
+var RomLayouts = map[string]Layout{
+	"brix.c8":     {{0x4, 0x6}},
+	"ufo.c8":      {{0x4, 0x5, 0x6}},
+	"invaders.c8": {{0x4, 0x5, 0x6}},
+	"tetris.c8":   {{0x4, 0x7}, {0x5, 0x6}},
+	"pong.c8":     {{0x1, 0xC}, {0x4, 0xD}},
+	"joust.c8":    {{0x3, 0xA, 0xC}},
+	"ant.c8":      {{0x3, 0xA, 0xC}},
+	"alien.c8":    {{0x3, 0xA, 0xC}},
+}
+
+// LayoutFor picks the layout for a ROM, HexLayout unless it has its own.
+func LayoutFor(romName string, romHash [20]byte) Layout {
+	if layout, ok := RomLayouts[filepath.Base(romName)]; ok {
+		return layout
+	}
+	if layout, ok := RomLayouts[hex.EncodeToString(romHash[:])]; ok {
+		return layout
+	}
+	return HexLayout
+}
+
+// Colours of the pad.
+var (
+	Background = color.RGBA{0x20, 0x20, 0x20, 0xFF}
+	Button     = color.RGBA{0x50, 0x50, 0x50, 0xFF}
+	Pressed    = color.RGBA{0xFF, 0x66, 0x00, 0xFF}
+	Label      = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+// Pad is a keypad occupying Bounds of the screen, in pixels.
+type Pad struct {
+	Layout  Layout
+	Bounds  image.Rectangle
+	fingers map[touch.Sequence]byte // Key held by each finger on a button
+}
+
+// New makes a pad with the given layout. Set Bounds before use.
+func New(layout Layout) *Pad {
+	return &Pad{Layout: layout, fingers: map[touch.Sequence]byte{}}
+}
+
+// KeyAt returns the key of the button at screen position (x, y).
+func (self *Pad) KeyAt(x, y float32) (byte, bool) {
+	p := image.Pt(int(x), int(y))
+	if !p.In(self.Bounds) || len(self.Layout) == 0 {
+		return 0, false
+	}
+	row := (p.Y - self.Bounds.Min.Y) * len(self.Layout) / self.Bounds.Dy()
+	keys := self.Layout[row]
+	col := (p.X - self.Bounds.Min.X) * len(keys) / self.Bounds.Dx()
+	return keys[col], true
+}
+
+// Pressed reports whether any finger is on the button for key.
+func (self *Pad) Pressed(key byte) bool {
+	for _, k := range self.fingers {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle applies a touch event to c.Key, reporting whether it touched the
+// pad. Fingers sliding from one button to another move the key press with
+// them.
+func (self *Pad) Handle(c *chip8.Chip8, e touch.Event) bool {
+	old, had := self.fingers[e.Sequence]
+	key, on := self.KeyAt(e.X, e.Y)
+	if e.Type == touch.TypeEnd || !on {
+		delete(self.fingers, e.Sequence)
+	} else {
+		self.fingers[e.Sequence] = key
+	}
+
+	if had && !self.Pressed(old) {
+		c.Key[old] = 0
+	}
+	if on && e.Type != touch.TypeEnd {
+		c.Key[key] = 1
+	}
+	return on || had
+}
+
+// Draw renders the pad into img, which should be the size of Bounds.
+func (self *Pad) Draw(img *image.RGBA) {
+	r := img.Bounds()
+	fill(img, r, Background)
+	for row, keys := range self.Layout {
+		for col, key := range keys {
+			cell := image.Rect(
+				r.Min.X+col*r.Dx()/len(keys), r.Min.Y+row*r.Dy()/len(self.Layout),
+				r.Min.X+(col+1)*r.Dx()/len(keys), r.Min.Y+(row+1)*r.Dy()/len(self.Layout))
+			gap := min(cell.Dx(), cell.Dy()) / 16
+			cell = cell.Inset(gap + 1)
+			if self.Pressed(key) {
+				fill(img, cell, Pressed)
+			} else {
+				fill(img, cell, Button)
+			}
+			drawGlyph(img, cell, key)
+		}
+	}
+}
+
+// Draws the 4x5 CHIP-8 font character for key in the middle of cell.
+func drawGlyph(img *image.RGBA, cell image.Rectangle, key byte) {
+	scale := min(cell.Dx()/4, cell.Dy()/5) / 3
+	if scale < 1 {
+		scale = 1
+	}
+	origin := image.Pt(cell.Min.X+(cell.Dx()-4*scale)/2, cell.Min.Y+(cell.Dy()-5*scale)/2)
+	for y := 0; y < 5; y++ {
+		row := chip8.Chip8_fontset[int(key)*5+y]
+		for x := 0; x < 4; x++ {
+			if row&(0x80>>uint(x)) != 0 {
+				p := origin.Add(image.Pt(x*scale, y*scale))
+				fill(img, image.Rect(p.X, p.Y, p.X+scale, p.Y+scale), Label)
+			}
+		}
+	}
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Arrange splits a screen of w by h pixels between the game and the pad:
+// below the game when the screen is taller than it is wide, to the right of
+// it otherwise.
+func Arrange(w, h int) (game, pad image.Rectangle) {
+	if h > w {
+		split := w / 2 // The display is twice as wide as it is tall
+		return image.Rect(0, 0, w, split), image.Rect(0, split, w, h)
+	}
+	split := w - min(h, w/3)
+	return image.Rect(0, 0, split, h), image.Rect(split, 0, w, h)
+}
@@ -0,0 +1,100 @@
+package touchpad_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/touchpad"
+	"golang.org/x/mobile/event/touch"
+)
+
+func newPad() *touchpad.Pad {
+	pad := touchpad.New(touchpad.HexLayout)
+	pad.Bounds = image.Rect(0, 100, 400, 500) // 100 pixel buttons
+	return pad
+}
+
+func TestKeyAt(t *testing.T) {
+	pad := newPad()
+	cases := []struct {
+		x, y float32
+		key  byte
+		ok   bool
+	}{
+		{50, 150, 0x1, true},
+		{350, 150, 0xC, true},
+		{150, 450, 0x0, true},
+		{399, 499, 0xF, true},
+		{50, 50, 0, false},
+	}
+	for _, c := range cases {
+		if key, ok := pad.KeyAt(c.x, c.y); key != c.key || ok != c.ok {
+			t.Errorf("(%v, %v): got %X %v, want %X %v", c.x, c.y, key, ok, c.key, c.ok)
+		}
+	}
+}
+
+func TestMultiTouch(t *testing.T) {
+	pad := newPad()
+	var c chip8.Chip8
+	pad.Handle(&c, touch.Event{Sequence: 1, Type: touch.TypeBegin, X: 50, Y: 250})  // 4
+	pad.Handle(&c, touch.Event{Sequence: 2, Type: touch.TypeBegin, X: 250, Y: 250}) // 6
+	if c.Key[4] != 1 || c.Key[6] != 1 {
+		t.Fatal("both fingers should hold their keys")
+	}
+
+	// Finger 1 slides onto 5
+	pad.Handle(&c, touch.Event{Sequence: 1, Type: touch.TypeMove, X: 150, Y: 250})
+	if c.Key[4] != 0 || c.Key[5] != 1 || !pad.Pressed(5) || pad.Pressed(4) {
+		t.Error("sliding should move the press from 4 to 5")
+	}
+
+	pad.Handle(&c, touch.Event{Sequence: 2, Type: touch.TypeEnd, X: 250, Y: 250})
+	if c.Key[6] != 0 || c.Key[5] != 1 {
+		t.Error("lifting one finger should only release its key")
+	}
+
+	// Sliding off the pad lets go
+	if !pad.Handle(&c, touch.Event{Sequence: 1, Type: touch.TypeMove, X: 150, Y: 20}) || c.Key[5] != 0 {
+		t.Error("sliding off the pad should release the key")
+	}
+	if pad.Handle(&c, touch.Event{Sequence: 3, Type: touch.TypeBegin, X: 150, Y: 20}) {
+		t.Error("touches outside the pad aren't handled")
+	}
+}
+
+func TestLayoutFor(t *testing.T) {
+	if layout := touchpad.LayoutFor("assets/brix.c8", [20]byte{}); len(layout) != 1 || len(layout[0]) != 2 {
+		t.Errorf("brix should get its two button layout, got %v", layout)
+	}
+	if layout := touchpad.LayoutFor("unknown.ch8", [20]byte{}); len(layout) != 4 {
+		t.Errorf("unknown ROMs should get the hex keypad, got %v", layout)
+	}
+}
+
+func TestDrawShowsPressedKeys(t *testing.T) {
+	pad := newPad()
+	var c chip8.Chip8
+	pad.Handle(&c, touch.Event{Sequence: 1, Type: touch.TypeBegin, X: 50, Y: 150})
+	img := image.NewRGBA(image.Rect(0, 0, 400, 400))
+	pad.Draw(img)
+	// Near the corner of a button, clear of the gap and the label
+	if got := img.RGBAAt(15, 15); got != touchpad.Pressed {
+		t.Errorf("pressed button drawn as %v", got)
+	}
+	if got := img.RGBAAt(115, 15); got != touchpad.Button {
+		t.Errorf("released button drawn as %v", got)
+	}
+}
+
+func TestArrange(t *testing.T) {
+	game, pad := touchpad.Arrange(1080, 1920)
+	if game != image.Rect(0, 0, 1080, 540) || pad != image.Rect(0, 540, 1080, 1920) {
+		t.Errorf("portrait: game %v pad %v", game, pad)
+	}
+	game, pad = touchpad.Arrange(1920, 1080)
+	if game != image.Rect(0, 0, 1280, 1080) || pad != image.Rect(1280, 0, 1920, 1080) {
+		t.Errorf("landscape: game %v pad %v", game, pad)
+	}
+}
@@ -0,0 +1,333 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/disasm"
+)
+
+// Difference is one way two machine states differ, e.g. {"V3", "0x05",
+// "0x06"}.
+type Difference struct {
+	What string
+	A, B string
+}
+
+// How many differing bytes of memory are listed before summing up the rest.
+const maxMemoryDifferences = 8
+
+// Compare lists the differences between a and b: registers, timers, stack,
+// memory and the display.
+func Compare(a, b *chip8.Chip8) []Difference {
+	var diffs []Difference
+	add := func(what string, va, vb interface{}, format string) {
+		diffs = append(diffs, Difference{what, fmt.Sprintf(format, va), fmt.Sprintf(format, vb)})
+	}
+	diffs = append(diffs, compareRegisters(RecordOf(a), RecordOf(b))...)
+	if a.Opcode != b.Opcode {
+		add("Opcode", a.Opcode, b.Opcode, "%04X")
+	}
+	for i := 0; i < int(a.Sp) && i < int(b.Sp) && i < len(a.Stack); i++ {
+		if a.Stack[i] != b.Stack[i] {
+			add(fmt.Sprintf("Stack[%d]", i), a.Stack[i], b.Stack[i], "0x%03X")
+		}
+	}
+	if a.Halted != b.Halted {
+		add("Halted", a.Halted, b.Halted, "%v")
+	}
+	if a.HiRes != b.HiRes {
+		add("HiRes", a.HiRes, b.HiRes, "%v")
+	}
+	if a.Plane != b.Plane {
+		add("Plane", a.Plane, b.Plane, "%d")
+	}
+
+	listed, more := 0, 0
+	for addr := range a.Memory {
+		if a.Memory[addr] == b.Memory[addr] {
+			continue
+		}
+		if listed < maxMemoryDifferences {
+			add(fmt.Sprintf("Memory[0x%03X]", addr), a.Memory[addr], b.Memory[addr], "0x%02X")
+			listed++
+		} else {
+			more++
+		}
+	}
+	if more > 0 {
+		diffs = append(diffs, Difference{"Memory", fmt.Sprintf("%d more bytes differ", more), ""})
+	}
+
+	if len(a.Gfx) == len(b.Gfx) {
+		pixels, first := 0, -1
+		for i := range a.Gfx {
+			if a.Gfx[i] != b.Gfx[i] {
+				if first < 0 {
+					first = i
+				}
+				pixels++
+			}
+		}
+		if pixels > 0 {
+			w := a.Width()
+			diffs = append(diffs, Difference{"Gfx", fmt.Sprintf("%d pixels differ, the first at %d,%d", pixels, first%w, first/w), ""})
+		}
+	}
+	return diffs
+}
+
+// The differences between the registers traced in two records.
+func compareRegisters(a, b *Record) []Difference {
+	var diffs []Difference
+	add := func(what string, va, vb interface{}, format string) {
+		diffs = append(diffs, Difference{what, fmt.Sprintf(format, va), fmt.Sprintf(format, vb)})
+	}
+	if a.Frame != b.Frame {
+		add("Frame", a.Frame, b.Frame, "%d")
+	}
+	if a.Pc != b.Pc {
+		add("Pc", a.Pc, b.Pc, "0x%03X")
+	}
+	for i := range a.V {
+		if a.V[i] != b.V[i] {
+			add(fmt.Sprintf("V%X", i), a.V[i], b.V[i], "0x%02X")
+		}
+	}
+	if a.Index != b.Index {
+		add("Index", a.Index, b.Index, "0x%03X")
+	}
+	if a.Sp != b.Sp {
+		add("Sp", a.Sp, b.Sp, "%d")
+	}
+	if a.Delay != b.Delay {
+		add("Delay", a.Delay, b.Delay, "%d")
+	}
+	if a.Sound != b.Sound {
+		add("Sound", a.Sound, b.Sound, "%d")
+	}
+	return diffs
+}
+
+// RecordOf records the registers of c as they are now, with the opcode at
+// Pc.
+func RecordOf(c *chip8.Chip8) *Record {
+	return &Record{
+		Frame:  c.Frame,
+		Pc:     c.Pc,
+		Opcode: uint16(c.Memory[c.Pc])<<8 | uint16(c.Memory[c.Pc+1]),
+		V:      c.V,
+		Index:  c.Index,
+		Sp:     c.Sp,
+		Delay:  c.Delay_timer,
+		Sound:  c.Sound_timer,
+	}
+}
+
+// Input sets the keys held in each frame, as movie.Movie does.
+type Input interface {
+	Apply(c *chip8.Chip8) bool
+}
+
+// Machine is a Chip8 run an instruction at a time with the frame structure
+// RunFrame would give it, so two machines can be compared after every
+// instruction.
+type Machine struct {
+	Chip  *chip8.Chip8
+	Input Input // Applied at the start of every frame, if not nil
+	Name  string
+
+	cycle   uint64 // Instructions run
+	applied uint64 // Frames Input has been applied for
+	recent  []Record
+	next    int // Oldest in recent, once it is full
+}
+
+// Step runs one instruction, ticking the timers after every CyclesPerFrame
+// and remembering the last context instructions.
+func (self *Machine) Step(context int) {
+	c := self.Chip
+	if self.Input != nil && c.Frame >= self.applied {
+		self.Input.Apply(c)
+		self.applied = c.Frame + 1
+	}
+	self.cycle++
+	if context > 0 {
+		r := *RecordOf(c)
+		r.Cycle = self.cycle
+		if len(self.recent) < context {
+			self.recent = append(self.recent, r)
+		} else {
+			self.recent[self.next] = r
+			self.next = (self.next + 1) % len(self.recent)
+		}
+	}
+	c.Step()
+}
+
+// Recent returns the last instructions Step ran, oldest first.
+func (self *Machine) Recent() []Record {
+	return append(append([]Record(nil), self.recent[self.next:]...), self.recent[:self.next]...)
+}
+
+// Divergence is where two runs first differ.
+type Divergence struct {
+	// Instructions run before the states differed, the last of them is the
+	// one that made the difference. 0 if they differed from the start.
+	Cycle       uint64
+	Names       [2]string
+	Differences []Difference
+	// The last instructions each side ran, oldest first. Reference traces
+	// only hold what was traced.
+	Recent [2][]Record
+	// Instructions from where each side has got to, for machines only
+	Next [2][]disasm.Line
+}
+
+// DefaultContext is how many instructions either side of a divergence are
+// shown.
+const DefaultContext = 8
+
+// Lockstep runs a and b an instruction at a time for up to cycles
+// instructions, returning where their states first differ or nil if they
+// never do.
+func Lockstep(a, b *Machine, cycles uint64, context int) *Divergence {
+	if diffs := Compare(a.Chip, b.Chip); len(diffs) > 0 {
+		return diverged(a, b, diffs, context)
+	}
+	for i := uint64(0); i < cycles; i++ {
+		a.Step(context)
+		b.Step(context)
+		if diffs := Compare(a.Chip, b.Chip); len(diffs) > 0 {
+			return diverged(a, b, diffs, context)
+		}
+	}
+	return nil
+}
+
+func diverged(a, b *Machine, diffs []Difference, context int) *Divergence {
+	d := &Divergence{
+		Cycle:       a.cycle,
+		Names:       [2]string{a.Name, b.Name},
+		Differences: diffs,
+		Recent:      [2][]Record{a.Recent(), b.Recent()},
+	}
+	for i, m := range []*Machine{a, b} {
+		d.Next[i] = listing(m.Chip, context)
+	}
+	return d
+}
+
+// Disassembles count instructions from Pc on.
+func listing(c *chip8.Chip8, count int) []disasm.Line {
+	var lines []disasm.Line
+	for addr := int(c.Pc); len(lines) < count && addr < len(c.Memory)-1; {
+		op := disasm.Decode(c.Memory[addr:], disasm.XOChip)
+		if addr+op.Size > len(c.Memory) {
+			break
+		}
+		bytes := append([]byte(nil), c.Memory[addr:addr+op.Size]...)
+		lines = append(lines, disasm.Line{Addr: uint16(addr), Bytes: bytes, Op: &op})
+		addr += op.Size
+	}
+	return lines
+}
+
+// Replay runs m against a reference trace, comparing the registers before
+// each instruction with the record for that cycle. Records the trace
+// filtered out aren't compared, the machine just runs through them. Stops
+// after cycles instructions or at the end of the trace, returning how many
+// instructions were checked. A record that doesn't come after the one
+// before it is an error.
+func Replay(m *Machine, ref Reader, cycles uint64, context int) (*Divergence, uint64, error) {
+	var recent []Record
+	for m.cycle < cycles {
+		r, err := ref.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, m.cycle, err
+		}
+		for m.cycle+1 < r.Cycle && m.cycle < cycles {
+			m.Step(context)
+		}
+		if m.cycle == cycles {
+			break
+		}
+		if m.cycle+1 != r.Cycle {
+			return nil, m.cycle, fmt.Errorf("trace: record for cycle %d is out of order after cycle %d", r.Cycle, m.cycle)
+		}
+		mine := RecordOf(m.Chip)
+		if diffs := compareRegisters(mine, r); len(diffs) > 0 || mine.Opcode != r.Opcode {
+			if mine.Opcode != r.Opcode {
+				diffs = append(diffs, Difference{"Opcode", fmt.Sprintf("%04X", mine.Opcode), fmt.Sprintf("%04X", r.Opcode)})
+			}
+			return &Divergence{
+				Cycle:       m.cycle,
+				Names:       [2]string{m.Name, "reference"},
+				Differences: diffs,
+				Recent:      [2][]Record{m.Recent(), recent},
+				Next:        [2][]disasm.Line{listing(m.Chip, context)},
+			}, m.cycle, nil
+		}
+		if context > 0 {
+			if len(recent) == context {
+				recent = recent[1:]
+			}
+			recent = append(recent, *r)
+		}
+		m.Step(context)
+	}
+	return nil, m.cycle, nil
+}
+
+// WriteTo writes a report of the divergence.
+func (self *Divergence) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	printf := func(format string, args ...interface{}) {
+		c, _ := fmt.Fprintf(bw, format, args...)
+		n += int64(c)
+	}
+
+	if self.Cycle == 0 {
+		printf("%s and %s differ from the start\n", self.Names[0], self.Names[1])
+	} else {
+		printf("%s and %s differ after cycle %d\n", self.Names[0], self.Names[1], self.Cycle)
+	}
+	printf("\n  %-16s %-12s %s\n", "", self.Names[0], self.Names[1])
+	for _, d := range self.Differences {
+		if d.B == "" {
+			printf("  %-16s %s\n", d.What, d.A) // A summary rather than two values
+		} else {
+			printf("  %-16s %-12s %s\n", d.What, d.A, d.B)
+		}
+	}
+	for i, name := range self.Names {
+		if len(self.Recent[i]) > 0 {
+			printf("\n%s ran:\n", name)
+			for _, r := range self.Recent[i] {
+				printf("  %8d  0x%03X: %04X      %s\n", r.Cycle, r.Pc, r.Opcode, mnemonic(r))
+			}
+		}
+		if len(self.Next[i]) > 0 {
+			printf("\n%s goes on to:\n", name)
+			for _, line := range self.Next[i] {
+				printf("  %8s  %s\n", "", line)
+			}
+		}
+	}
+	return n, bw.Flush()
+}
+
+// The record's mnemonic, decoded from the opcode if the trace didn't have
+// one. XO-CHIP long loads only show their first word.
+func mnemonic(r Record) string {
+	if r.Mnemonic != "" {
+		return r.Mnemonic
+	}
+	return disasm.Decode([]byte{byte(r.Opcode >> 8), byte(r.Opcode)}, disasm.XOChip).Text
+}
@@ -0,0 +1,136 @@
+package trace_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/trace"
+)
+
+// 6F05 6001 6102 8011 1208: OR with VF set, which VFReset clears
+var orProgram = []byte{0x6F, 0x05, 0x60, 0x01, 0x61, 0x02, 0x80, 0x11, 0x12, 0x08}
+
+func newMachine(t *testing.T, name string, vfReset bool) *trace.Machine {
+	t.Helper()
+	c := &chip8.Chip8{}
+	c.Init()
+	if err := c.LoadBytes("test", orProgram); err != nil {
+		t.Fatal(err)
+	}
+	c.Quirks.VFReset = vfReset
+	return &trace.Machine{Chip: c, Name: name}
+}
+
+func TestLockstepSame(t *testing.T) {
+	if d := trace.Lockstep(newMachine(t, "a", true), newMachine(t, "b", true), 100, 4); d != nil {
+		t.Errorf("identical machines diverged: %+v", d)
+	}
+}
+
+func TestLockstepDiverges(t *testing.T) {
+	d := trace.Lockstep(newMachine(t, "a", false), newMachine(t, "b", true), 100, 2)
+	if d == nil {
+		t.Fatal("machines with different quirks never diverged")
+	}
+	if d.Cycle != 4 || len(d.Differences) != 1 || d.Differences[0] != (trace.Difference{"VF", "0x05", "0x00"}) {
+		t.Errorf("got cycle %d, %+v", d.Cycle, d.Differences)
+	}
+	if len(d.Recent[0]) != 2 || d.Recent[0][1].Opcode != 0x8011 || d.Recent[0][1].Cycle != 4 {
+		t.Errorf("recent instructions %+v", d.Recent[0])
+	}
+	if len(d.Next[1]) != 2 || d.Next[1][0].Addr != 0x208 {
+		t.Errorf("next instructions %+v", d.Next[1])
+	}
+
+	var out bytes.Buffer
+	if _, err := d.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"a and b differ after cycle 4", "OR V0, V1", "JP 0x208"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, &out)
+		}
+	}
+}
+
+func TestCompareMemoryAndDisplay(t *testing.T) {
+	a, b := newMachine(t, "a", false).Chip, newMachine(t, "b", false).Chip
+	for addr := 0x300; addr < 0x30A; addr++ {
+		b.Memory[addr] = 1
+	}
+	b.Gfx[2*a.Width()+3] = 1
+	diffs := trace.Compare(a, b)
+	if len(diffs) != 10 {
+		t.Fatalf("got %d differences: %+v", len(diffs), diffs)
+	}
+	if diffs[0].What != "Memory[0x300]" || diffs[8].A != "2 more bytes differ" || diffs[9].A != "1 pixels differ, the first at 3,2" {
+		t.Errorf("got %+v", diffs)
+	}
+}
+
+// Records a JSON trace of three frames of the program with vfReset.
+func reference(t *testing.T, vfReset bool) trace.Reader {
+	t.Helper()
+	c := newMachine(t, "", vfReset).Chip
+	var buf bytes.Buffer
+	w, _ := trace.NewWriter(&buf, trace.JSON)
+	tr := trace.New(w)
+	tr.Attach(c)
+	for i := 0; i < 3; i++ {
+		c.RunFrame()
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := trace.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestReplay(t *testing.T) {
+	d, n, err := trace.Replay(newMachine(t, "a", true), reference(t, true), 100, 4)
+	if err != nil || d != nil || n != 30 {
+		t.Errorf("same configuration: %+v, %d instructions, %v", d, n, err)
+	}
+	if _, n, _ := trace.Replay(newMachine(t, "a", true), reference(t, true), 12, 4); n != 12 {
+		t.Errorf("checked %d instructions, want 12", n)
+	}
+
+	d, _, err = trace.Replay(newMachine(t, "a", false), reference(t, true), 100, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Found in the registers the fifth record was traced with
+	if d == nil || d.Cycle != 4 || d.Names[1] != "reference" || d.Differences[0].What != "VF" {
+		t.Fatalf("got %+v", d)
+	}
+	if len(d.Recent[1]) != 4 || d.Recent[1][3].Opcode != 0x8011 {
+		t.Errorf("reference context %+v", d.Recent[1])
+	}
+}
+
+// Reads records from a slice.
+type records []trace.Record
+
+func (self *records) Read() (*trace.Record, error) {
+	if len(*self) == 0 {
+		return nil, io.EOF
+	}
+	r := &(*self)[0]
+	*self = (*self)[1:]
+	return r, nil
+}
+
+func TestReplayOutOfOrder(t *testing.T) {
+	m := newMachine(t, "a", true)
+	ref := &records{*trace.RecordOf(m.Chip), *trace.RecordOf(m.Chip)}
+	(*ref)[0].Cycle, (*ref)[1].Cycle = 1, 1
+	if _, n, err := trace.Replay(m, ref, 100, 4); err == nil || n != 1 {
+		t.Errorf("a repeated cycle should fail after 1 instruction, got %d, %v", n, err)
+	}
+}
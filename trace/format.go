@@ -0,0 +1,236 @@
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Traces are written as JSON Lines, one Record object per line with the
+// field names in its tags, or in a compact binary format:
+//
+//	magic    [4]byte  "C8TR"
+//	version  uint16   Version
+//	records  Cycle, Frame uint64, Pc, Opcode uint16, V [16]byte,
+//	         Index, Sp uint16, Delay, Sound byte, 42 bytes each
+//
+// Integers are big endian. Binary traces leave out Mnemonic, it can be
+// decoded again from Opcode.
+const (
+	Version    = 1
+	magic      = "C8TR"
+	recordSize = 8 + 8 + 2 + 2 + 16 + 2 + 2 + 1 + 1
+)
+
+// Formats for NewWriter.
+const (
+	JSON   = "jsonl"
+	Binary = "binary"
+)
+
+// Reasons a trace can fail to read.
+var (
+	ErrInvalid = errors.New("not a trace")
+	ErrVersion = errors.New("trace needs a newer version")
+)
+
+// Writer stores records. Writes are buffered until Flush.
+type Writer interface {
+	Write(r *Record) error
+	Flush() error
+	// Mnemonics reports whether Write stores Record.Mnemonic, so the tracer
+	// can skip decoding it when it doesn't.
+	Mnemonics() bool
+}
+
+// NewWriter makes a writer for format, JSON or Binary.
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	if err := checkFormat(format); err != nil {
+		return nil, err
+	}
+	if format == Binary {
+		return NewBinaryWriter(w), nil
+	}
+	return NewJSONWriter(w), nil
+}
+
+func checkFormat(format string) error {
+	if format != JSON && format != Binary {
+		return fmt.Errorf("trace: unknown format %q, want %s or %s", format, JSON, Binary)
+	}
+	return nil
+}
+
+// JSONWriter writes JSON Lines.
+type JSONWriter struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+// NewJSONWriter makes a JSON Lines writer buffering to w.
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: bufio.NewWriter(w)}
+}
+
+// Write appends the line by hand, encoding/json is several times slower
+// and traces run to millions of lines.
+func (self *JSONWriter) Write(r *Record) error {
+	b := self.buf[:0]
+	b = append(b, `{"cycle":`...)
+	b = strconv.AppendUint(b, r.Cycle, 10)
+	b = append(b, `,"frame":`...)
+	b = strconv.AppendUint(b, r.Frame, 10)
+	b = append(b, `,"pc":`...)
+	b = strconv.AppendUint(b, uint64(r.Pc), 10)
+	b = append(b, `,"op":`...)
+	b = strconv.AppendUint(b, uint64(r.Opcode), 10)
+	b = append(b, `,"asm":`...)
+	b = strconv.AppendQuoteToASCII(b, r.Mnemonic) // Mnemonics are plain ASCII, quoted the same in Go and JSON
+	b = append(b, `,"v":[`...)
+	for i, v := range r.V {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendUint(b, uint64(v), 10)
+	}
+	b = append(b, `],"i":`...)
+	b = strconv.AppendUint(b, uint64(r.Index), 10)
+	b = append(b, `,"sp":`...)
+	b = strconv.AppendUint(b, uint64(r.Sp), 10)
+	b = append(b, `,"dt":`...)
+	b = strconv.AppendUint(b, uint64(r.Delay), 10)
+	b = append(b, `,"st":`...)
+	b = strconv.AppendUint(b, uint64(r.Sound), 10)
+	b = append(b, "}\n"...)
+	self.buf = b
+	_, err := self.w.Write(b)
+	return err
+}
+
+func (self *JSONWriter) Flush() error {
+	return self.w.Flush()
+}
+
+func (self *JSONWriter) Mnemonics() bool {
+	return true
+}
+
+// BinaryWriter writes the binary format.
+type BinaryWriter struct {
+	w   *bufio.Writer
+	buf [recordSize]byte
+}
+
+// NewBinaryWriter makes a binary writer buffering to w, starting with the
+// header.
+func NewBinaryWriter(w io.Writer) *BinaryWriter {
+	self := &BinaryWriter{w: bufio.NewWriter(w)}
+	// Any error comes back from the first Write or Flush
+	self.w.WriteString(magic)
+	binary.Write(self.w, binary.BigEndian, uint16(Version))
+	return self
+}
+
+func (self *BinaryWriter) Write(r *Record) error {
+	b := self.buf[:]
+	binary.BigEndian.PutUint64(b[0:], r.Cycle)
+	binary.BigEndian.PutUint64(b[8:], r.Frame)
+	binary.BigEndian.PutUint16(b[16:], r.Pc)
+	binary.BigEndian.PutUint16(b[18:], r.Opcode)
+	copy(b[20:36], r.V[:])
+	binary.BigEndian.PutUint16(b[36:], r.Index)
+	binary.BigEndian.PutUint16(b[38:], r.Sp)
+	b[40], b[41] = r.Delay, r.Sound
+	_, err := self.w.Write(b)
+	return err
+}
+
+func (self *BinaryWriter) Flush() error {
+	return self.w.Flush()
+}
+
+func (self *BinaryWriter) Mnemonics() bool {
+	return false
+}
+
+// Reader reads records back, returning io.EOF after the last one.
+type Reader interface {
+	Read() (*Record, error)
+}
+
+// NewReader reads a trace in either format, telling them apart by the
+// binary magic.
+func NewReader(r io.Reader) (Reader, error) {
+	in := bufio.NewReader(r)
+	head, err := in.Peek(len(magic) + 2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.HasPrefix(head, []byte(magic)) {
+		return &jsonReader{in: in}, nil
+	}
+	if len(head) < len(magic)+2 {
+		return nil, fmt.Errorf("trace: %w", ErrInvalid)
+	}
+	if version := binary.BigEndian.Uint16(head[len(magic):]); version > Version {
+		return nil, fmt.Errorf("trace: %w: format %d, this reads up to %d", ErrVersion, version, Version)
+	}
+	in.Discard(len(head))
+	return &binaryReader{in: in}, nil
+}
+
+type jsonReader struct {
+	in   *bufio.Reader
+	line int
+}
+
+func (self *jsonReader) Read() (*Record, error) {
+	for {
+		line, err := self.in.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err == nil {
+				self.line++
+				continue
+			}
+			return nil, err
+		}
+		self.line++
+		r := &Record{}
+		if err := json.Unmarshal(line, r); err != nil {
+			return nil, fmt.Errorf("trace: line %d: %w: %v", self.line, ErrInvalid, err)
+		}
+		return r, nil
+	}
+}
+
+type binaryReader struct {
+	in  *bufio.Reader
+	buf [recordSize]byte
+}
+
+func (self *binaryReader) Read() (*Record, error) {
+	b := self.buf[:]
+	if _, err := io.ReadFull(self.in, b); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("trace: %w: truncated record", ErrInvalid)
+		}
+		return nil, err
+	}
+	r := &Record{
+		Cycle:  binary.BigEndian.Uint64(b[0:]),
+		Frame:  binary.BigEndian.Uint64(b[8:]),
+		Pc:     binary.BigEndian.Uint16(b[16:]),
+		Opcode: binary.BigEndian.Uint16(b[18:]),
+		Index:  binary.BigEndian.Uint16(b[36:]),
+		Sp:     binary.BigEndian.Uint16(b[38:]),
+		Delay:  b[40],
+		Sound:  b[41],
+	}
+	copy(r.V[:], b[20:36])
+	return r, nil
+}
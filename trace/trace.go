@@ -0,0 +1,205 @@
+// Package trace logs every instruction a Chip8 runs, with the registers as
+// they were before it ran, for finding where two emulators or two versions
+// of this one diverge.
+//
+// Attach a Tracer to a machine to start tracing. Nothing is traced, and
+// EmulateCycle only pays for a nil check, until then.
+package trace
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/disasm"
+)
+
+// Record is the machine state at one instruction.
+type Record struct {
+	Cycle    uint64   `json:"cycle"` // Instructions run since tracing started
+	Frame    uint64   `json:"frame"`
+	Pc       uint16   `json:"pc"`
+	Opcode   uint16   `json:"op"`
+	Mnemonic string   `json:"asm"` // Only in JSON traces, empty when read from binary
+	V        [16]byte `json:"v"`
+	Index    uint16   `json:"i"`
+	Sp       uint16   `json:"sp"`
+	Delay    byte     `json:"dt"`
+	Sound    byte     `json:"st"`
+}
+
+// Filter picks the instructions to trace. The zero Filter traces them all.
+type Filter struct {
+	From, To uint16 // Pc range, inclusive. To 0 has no upper bound.
+	// Bit n set traces opcodes whose first hex digit is n, e.g. 1<<0xD for
+	// DXYN. 0 traces them all.
+	Classes uint16
+}
+
+// Match reports whether the instruction at pc should be traced.
+func (self Filter) Match(pc, opcode uint16) bool {
+	if pc < self.From || (self.To != 0 && pc > self.To) {
+		return false
+	}
+	return self.Classes == 0 || self.Classes&(1<<(opcode>>12)) != 0
+}
+
+// ParseFilter makes a filter from a ParseRange range and ParseClasses
+// classes, as given on the command line.
+func ParseFilter(pcRange, classes string) (Filter, error) {
+	var f Filter
+	var err error
+	if f.From, f.To, err = ParseRange(pcRange); err != nil {
+		return f, err
+	}
+	f.Classes, err = ParseClasses(classes)
+	return f, err
+}
+
+// ParseRange parses a Pc range of two hex addresses, e.g. "200-2FF". An
+// empty string is all of memory.
+func ParseRange(s string) (from, to uint16, err error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("trace: range %q should be from-to", s)
+	}
+	a, err := parseAddr(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := parseAddr(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	if b < a {
+		return 0, 0, fmt.Errorf("trace: range %q ends before it starts", s)
+	}
+	return a, b, nil
+}
+
+func parseAddr(s string) (uint16, error) {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "0x")
+	n, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("trace: %q is not a hex address", s)
+	}
+	return uint16(n), nil
+}
+
+// ParseClasses parses a comma separated list of opcode first digits, e.g.
+// "8,D,F". An empty string is every class.
+func ParseClasses(s string) (uint16, error) {
+	var classes uint16
+	if s == "" {
+		return 0, nil
+	}
+	for _, c := range strings.Split(s, ",") {
+		n, err := strconv.ParseUint(strings.TrimSpace(c), 16, 4)
+		if err != nil {
+			return 0, fmt.Errorf("trace: %q is not an opcode class, want a hex digit", c)
+		}
+		classes |= 1 << n
+	}
+	return classes, nil
+}
+
+// Tracer writes a Record for each instruction that passes Filter.
+type Tracer struct {
+	Filter Filter
+	// Mnemonics are decoded with this syntax, XO-CHIP by default as it
+	// covers every instruction the emulator runs.
+	Syntax disasm.Syntax
+
+	w     Writer
+	file  io.Closer // Opened by Create
+	cycle uint64
+	err   error
+}
+
+// New makes a tracer writing to w.
+func New(w Writer) *Tracer {
+	return &Tracer{w: w, Syntax: disasm.XOChip}
+}
+
+// Create makes a tracer writing format to a new file at path, or to stdout
+// for "-".
+func Create(path, format string) (*Tracer, error) {
+	if err := checkFormat(format); err != nil {
+		return nil, err
+	}
+	if path == "-" {
+		w, _ := NewWriter(os.Stdout, format)
+		return New(w), nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, _ := NewWriter(f, format)
+	self := New(w)
+	self.file = f
+	return self, nil
+}
+
+// Attach starts tracing c.
+func (self *Tracer) Attach(c *chip8.Chip8) {
+	c.Trace = self.trace
+}
+
+// Detach stops tracing c.
+func (self *Tracer) Detach(c *chip8.Chip8) {
+	c.Trace = nil
+}
+
+func (self *Tracer) trace(c *chip8.Chip8, in chip8.Instruction) {
+	self.cycle++
+	if self.err != nil || !self.Filter.Match(c.Pc, in.Opcode) {
+		return
+	}
+	r := Record{
+		Cycle:  self.cycle,
+		Frame:  c.Frame,
+		Pc:     c.Pc,
+		Opcode: in.Opcode,
+		V:      c.V,
+		Index:  c.Index,
+		Sp:     c.Sp,
+		Delay:  c.Delay_timer,
+		Sound:  c.Sound_timer,
+	}
+	if self.w.Mnemonics() {
+		r.Mnemonic = disasm.Decode(c.Memory[c.Pc:], self.Syntax).Text
+	}
+	self.err = self.w.Write(&r)
+}
+
+// Err returns the first error writing the trace. Tracing stops after it.
+func (self *Tracer) Err() error {
+	return self.err
+}
+
+// Flush writes out buffered records and returns any error from writing
+// them.
+func (self *Tracer) Flush() error {
+	if self.err != nil {
+		return self.err
+	}
+	return self.w.Flush()
+}
+
+// Close flushes the trace and closes the file Create opened.
+func (self *Tracer) Close() error {
+	err := self.Flush()
+	if self.file != nil {
+		if cerr := self.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,184 @@
+package trace_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/trace"
+)
+
+// 6005 A300 7001 1202: set V0 and I, then add to V0 forever
+var program = []byte{0x60, 0x05, 0xA3, 0x00, 0x70, 0x01, 0x12, 0x04}
+
+func newChip(t *testing.T) *chip8.Chip8 {
+	t.Helper()
+	c := &chip8.Chip8{}
+	c.Init()
+	if err := c.LoadBytes("test", program); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// Runs the program under a tracer writing format, returning the trace.
+func run(t *testing.T, format string, filter trace.Filter, cycles int) []byte {
+	t.Helper()
+	c := newChip(t)
+	var buf bytes.Buffer
+	w, err := trace.NewWriter(&buf, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := trace.New(w)
+	tr.Filter = filter
+	tr.Attach(c)
+	for i := 0; i < cycles; i++ {
+		c.EmulateCycle()
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readAll(t *testing.T, b []byte) []*trace.Record {
+	t.Helper()
+	r, err := trace.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []*trace.Record
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	out := run(t, trace.JSON, trace.Filter{}, 3)
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	want := `{"cycle":3,"frame":0,"pc":516,"op":28673,"asm":"ADD V0, 0x01","v":[5,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0],"i":768,"sp":0,"dt":0,"st":0}`
+	if len(lines) != 3 || lines[2] != want {
+		t.Fatalf("got\n%s\nwant the last line\n%s", out, want)
+	}
+
+	records := readAll(t, out)
+	if len(records) != 3 || records[0].Pc != 0x200 || records[0].Mnemonic != "LD V0, 0x05" || records[2].V[0] != 5 || records[2].Index != 0x300 {
+		t.Errorf("read back %+v", records)
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	jsonRecords := readAll(t, run(t, trace.JSON, trace.Filter{}, 20))
+	out := run(t, trace.Binary, trace.Filter{}, 20)
+	if len(out) != 6+20*42 {
+		t.Errorf("binary trace is %d bytes", len(out))
+	}
+	records := readAll(t, out)
+	if len(records) != len(jsonRecords) {
+		t.Fatalf("%d binary records, %d JSON", len(records), len(jsonRecords))
+	}
+	for i, r := range records {
+		want := *jsonRecords[i]
+		want.Mnemonic = ""
+		if *r != want {
+			t.Errorf("record %d: got %+v, want %+v", i, *r, want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	records := readAll(t, run(t, trace.JSON, trace.Filter{From: 0x202, To: 0x204}, 8))
+	for _, r := range records {
+		if r.Pc < 0x202 || r.Pc > 0x204 {
+			t.Errorf("traced %03X outside the range", r.Pc)
+		}
+	}
+	// A300, then 7001 three times, the cycle counting everything
+	if len(records) != 4 || records[1].Cycle != 3 || records[3].Cycle != 7 {
+		t.Errorf("got %d records", len(records))
+	}
+
+	classes, err := trace.ParseClasses("1, 6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records = readAll(t, run(t, trace.JSON, trace.Filter{Classes: classes}, 8))
+	if len(records) != 4 || records[0].Opcode != 0x6005 || records[1].Opcode != 0x1204 {
+		t.Errorf("class filter traced %d records", len(records))
+	}
+}
+
+func TestParse(t *testing.T) {
+	if from, to, err := trace.ParseRange("0x200-2fF"); err != nil || from != 0x200 || to != 0x2FF {
+		t.Errorf("got %03X-%03X %v", from, to, err)
+	}
+	for _, bad := range []string{"200", "300-200", "200-xyz"} {
+		if _, _, err := trace.ParseRange(bad); err == nil {
+			t.Errorf("range %q should fail", bad)
+		}
+	}
+	if c, err := trace.ParseClasses("D,f"); err != nil || c != 1<<0xD|1<<0xF {
+		t.Errorf("got %016b %v", c, err)
+	}
+	if _, err := trace.ParseClasses("10"); err == nil {
+		t.Error("class 10 should fail")
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	if _, err := trace.NewReader(strings.NewReader("C8TR\x00\x09")); !errors.Is(err, trace.ErrVersion) {
+		t.Errorf("newer version: %v", err)
+	}
+	r, _ := trace.NewReader(strings.NewReader("C8TR\x00\x01short"))
+	if _, err := r.Read(); !errors.Is(err, trace.ErrInvalid) {
+		t.Errorf("truncated record: %v", err)
+	}
+	r, _ = trace.NewReader(strings.NewReader("{\"pc\":512}\nnot json\n"))
+	r.Read()
+	if _, err := r.Read(); !errors.Is(err, trace.ErrInvalid) || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("bad line: %v", err)
+	}
+}
+
+// Tracing costs nothing until a tracer is attached
+func TestDisabledDoesNotAllocate(t *testing.T) {
+	c := newChip(t)
+	allocs := testing.AllocsPerRun(100, func() {
+		c.EmulateCycle()
+	})
+	if allocs != 0 {
+		t.Errorf("EmulateCycle allocates %v times without a tracer", allocs)
+	}
+}
+
+func BenchmarkTrace(b *testing.B) {
+	for _, format := range []string{"", trace.JSON, trace.Binary} {
+		name := format
+		if name == "" {
+			name = "off"
+		}
+		b.Run(name, func(b *testing.B) {
+			c := &chip8.Chip8{}
+			c.Init()
+			c.LoadBytes("test", program)
+			if format != "" {
+				w, _ := trace.NewWriter(io.Discard, format)
+				trace.New(w).Attach(c)
+			}
+			for i := 0; i < b.N; i++ {
+				c.EmulateCycle()
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package tui
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/bomer/chip8/keymap"
+	"golang.org/x/mobile/event/key"
+)
+
+// Keys that don't follow from their character.
+var specialKeys = map[byte]key.Code{
+	0x03: key.CodeEscape, // Ctrl-C, raw mode doesn't turn it into a signal
+	0x04: key.CodeEscape, // Ctrl-D
+	'\r': key.CodeReturnEnter,
+	'\n': key.CodeReturnEnter,
+	'\t': key.CodeTab,
+	' ':  key.CodeSpacebar,
+	0x7F: key.CodeDeleteBackspace,
+	0x08: key.CodeDeleteBackspace,
+	'[':  key.CodeLeftSquareBracket,
+	']':  key.CodeRightSquareBracket,
+	'-':  key.CodeHyphenMinus,
+	'=':  key.CodeEqualSign,
+	',':  key.CodeComma,
+	'.':  key.CodeFullStop,
+	'/':  key.CodeSlash,
+	';':  key.CodeSemicolon,
+	'\'': key.CodeApostrophe,
+}
+
+// Final bytes of the arrow key escape sequences.
+var arrowKeys = map[byte]key.Code{
+	'A': key.CodeUpArrow,
+	'B': key.CodeDownArrow,
+	'C': key.CodeRightArrow,
+	'D': key.CodeLeftArrow,
+}
+
+// Decode turns bytes read from a terminal in raw mode into key codes. Escape
+// on its own, Ctrl-C and Ctrl-D all come out as CodeEscape. Anything else not
+// on a standard keyboard is dropped. buf should hold whole escape sequences,
+// use a Decoder for reads that might split them.
+func Decode(buf []byte) []key.Code {
+	var d Decoder
+	return append(d.Decode(buf), d.Flush()...)
+}
+
+// Decoder decodes key codes like Decode from a series of reads. An escape
+// sequence split between reads, as often happens over SSH, is kept until the
+// next one, so its start isn't taken for the Escape key.
+type Decoder struct {
+	pending []byte // The start of an escape sequence at the end of the last read
+}
+
+// Decode returns the keys in buf, keeping any escape sequence it ends partway
+// through for the next call.
+func (self *Decoder) Decode(buf []byte) []key.Code {
+	if len(self.pending) > 0 {
+		buf = append(self.pending, buf...)
+		self.pending = nil
+	}
+	var codes []key.Code
+	for i := 0; i < len(buf); i++ {
+		b := buf[i]
+		if b == 0x1B {
+			if i+1 == len(buf) {
+				self.pending = []byte{0x1B}
+				break
+			}
+			if buf[i+1] != '[' && buf[i+1] != 'O' {
+				codes = append(codes, key.CodeEscape)
+				continue
+			}
+			// CSI or SS3 sequence: parameters, then a final byte
+			start := i
+			i += 2
+			for i < len(buf) && (buf[i] < 0x40 || buf[i] > 0x7E) {
+				i++
+			}
+			if i == len(buf) {
+				self.pending = append([]byte(nil), buf[start:]...)
+				break
+			}
+			if code, ok := arrowKeys[buf[i]]; ok {
+				codes = append(codes, code)
+			}
+			continue
+		}
+		if code, ok := specialKeys[b]; ok {
+			codes = append(codes, code)
+			continue
+		}
+		if ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') {
+			if code, err := keymap.ParseKey(string(b)); err == nil {
+				codes = append(codes, code)
+			}
+		}
+	}
+	return codes
+}
+
+// Pending reports whether the last read ended partway through an escape
+// sequence.
+func (self *Decoder) Pending() bool {
+	return len(self.pending) > 0
+}
+
+// Flush gives up waiting for the rest of an escape sequence, for when no more
+// input has come for a while. A lone escape byte was the Escape key, the
+// start of a longer sequence is dropped.
+func (self *Decoder) Flush() []key.Code {
+	lone := len(self.pending) == 1
+	self.pending = nil
+	if lone {
+		return []key.Code{key.CodeEscape}
+	}
+	return nil
+}
+
+// MakeRaw puts the terminal on f into raw mode, returning a function that
+// restores it. It runs stty, so works on any Unix terminal.
+func MakeRaw(f *os.File) (func() error, error) {
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = f
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := stty(saved)
+		return err
+	}, nil
+}
@@ -0,0 +1,62 @@
+package tui
+
+import (
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/keymap"
+	"golang.org/x/mobile/event/key"
+)
+
+// Frames a key is held for by default. The first press has to outlast the
+// keyboard's delay before it starts repeating, after that each repeat only
+// has to last until the next.
+const (
+	DefaultFirstHold  = 30
+	DefaultRepeatHold = 5
+)
+
+// Keypad emulates key releases. Terminals only send a key when it is pressed
+// and again as it auto-repeats, never when it is let go, so a key counts as
+// held until it has not repeated for a while.
+type Keypad struct {
+	Keymap     *keymap.Keymap
+	FirstHold  int // Frames held after a key is first pressed
+	RepeatHold int // Frames held after each repeat
+	left       [16]int
+}
+
+// NewKeypad makes a keypad with the default hold times.
+func NewKeypad(km *keymap.Keymap) *Keypad {
+	return &Keypad{Keymap: km, FirstHold: DefaultFirstHold, RepeatHold: DefaultRepeatHold}
+}
+
+// Press handles a key arriving from the terminal, reporting false if it
+// isn't bound to the keypad.
+func (self *Keypad) Press(code key.Code) bool {
+	k, ok := self.Keymap.Lookup(code)
+	if !ok {
+		return false
+	}
+	if self.left[k] > 0 {
+		self.left[k] = self.RepeatHold
+	} else {
+		self.left[k] = self.FirstHold
+	}
+	return true
+}
+
+// Frame sets the keys of c for the frame about to run and counts the holds
+// down.
+func (self *Keypad) Frame(c *chip8.Chip8) {
+	for k := range self.left {
+		c.Key[k] = 0
+		if self.left[k] > 0 {
+			c.Key[k] = 1
+			self.left[k]--
+		}
+	}
+}
+
+// Release lets go of every key.
+func (self *Keypad) Release() {
+	self.left = [16]int{}
+}
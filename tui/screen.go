@@ -0,0 +1,113 @@
+// Package tui plays a Chip8 in a terminal, for when there is no OpenGL, e.g.
+// over SSH. The display is drawn with Unicode half blocks in 24 bit ANSI
+// colour, and the keypad is read from the raw keyboard.
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+
+	"github.com/bomer/chip8/chip8"
+)
+
+// Escape sequences switching to the alternate screen with the cursor hidden
+// and back, and bracketing a frame for terminals with synchronized output.
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l\x1b[2J"
+	leaveScreen = "\x1b[0m\x1b[?25h\x1b[?1049l"
+	beginFrame  = "\x1b[?2026h"
+	endFrame    = "\x1b[?2026l"
+)
+
+// Upper half block, the top pixel in the foreground colour and the bottom
+// one in the background colour.
+const halfBlock = "▀"
+
+// DefaultPalette matches the OpenGL frontend.
+var DefaultPalette = [4]color.RGBA{
+	{0x00, 0x00, 0x00, 0xFF}, // Off
+	{0xFF, 0xFF, 0xFF, 0xFF}, // Plane 1
+	{0xFF, 0x66, 0x00, 0xFF}, // Plane 2
+	{0x66, 0x22, 0x00, 0xFF}, // Both
+}
+
+// Screen draws the display two pixel rows to a line of text, 32 lines for
+// SUPER-CHIP high resolution. Each frame is drawn over the last with a
+// single write and only when it has changed, so nothing flickers.
+type Screen struct {
+	Palette [4]color.RGBA
+	prev    []byte // Gfx as last drawn
+	width   int
+	buf     bytes.Buffer
+}
+
+// NewScreen makes a screen drawing in DefaultPalette.
+func NewScreen() *Screen {
+	return &Screen{Palette: DefaultPalette}
+}
+
+// Start switches w to the alternate screen and hides the cursor.
+func (self *Screen) Start(w io.Writer) error {
+	self.prev, self.width = nil, 0
+	_, err := io.WriteString(w, enterScreen)
+	return err
+}
+
+// Stop puts the terminal back the way Start found it.
+func (self *Screen) Stop(w io.Writer) error {
+	_, err := io.WriteString(w, leaveScreen)
+	return err
+}
+
+// Lines is the most lines of text the display takes up, in high resolution.
+const Lines = chip8.HiResHeight / 2
+
+// Draw writes the display of c to w, unless it is the same as last time.
+func (self *Screen) Draw(w io.Writer, c *chip8.Chip8) error {
+	width, height := c.Width(), c.Height()
+	if len(c.Gfx) != width*height || bytes.Equal(c.Gfx, self.prev) {
+		return nil
+	}
+
+	// Terminals that support synchronized output show the frame all at once
+	out := &self.buf
+	out.Reset()
+	out.WriteString(beginFrame)
+	if width != self.width {
+		// Clear what high resolution leaves behind below low resolution
+		for row := (height + 1) / 2; row < Lines; row++ {
+			fmt.Fprintf(out, "\x1b[%d;1H\x1b[K", row+1)
+		}
+		self.width = width
+	}
+	for row := 0; row < (height+1)/2; row++ {
+		fmt.Fprintf(out, "\x1b[%d;1H", row+1)
+		fg, bg := -1, -1
+		for x := 0; x < width; x++ {
+			top := int(c.Gfx[2*row*width+x] & chip8.AllPlanes)
+			bottom := 0
+			if 2*row+1 < height {
+				bottom = int(c.Gfx[(2*row+1)*width+x] & chip8.AllPlanes)
+			}
+			if top != fg {
+				p := self.Palette[top]
+				fmt.Fprintf(out, "\x1b[38;2;%d;%d;%dm", p.R, p.G, p.B)
+				fg = top
+			}
+			if bottom != bg {
+				p := self.Palette[bottom]
+				fmt.Fprintf(out, "\x1b[48;2;%d;%d;%dm", p.R, p.G, p.B)
+				bg = bottom
+			}
+			out.WriteString(halfBlock)
+		}
+		out.WriteString("\x1b[0m\x1b[K") // Clear the rest of a high resolution line
+	}
+
+	out.WriteString(endFrame)
+	self.prev = append(self.prev[:0], c.Gfx...)
+	_, err := out.WriteTo(w)
+	return err
+}
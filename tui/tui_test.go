@@ -0,0 +1,124 @@
+package tui_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bomer/chip8/chip8"
+	"github.com/bomer/chip8/keymap"
+	"github.com/bomer/chip8/tui"
+	"golang.org/x/mobile/event/key"
+)
+
+func TestDraw(t *testing.T) {
+	var c chip8.Chip8
+	c.Init()
+	c.Gfx[0] = 1    // Top left pixel, above an unlit one
+	c.Gfx[64+1] = 1 // Lit below an unlit one
+	c.Gfx[2] = 1    // and a column with both lit
+	c.Gfx[64+2] = 1
+	screen := tui.NewScreen()
+
+	var out bytes.Buffer
+	if err := screen.Draw(&out, &c); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	if n := strings.Count(got, "▀"); n != 64*16 {
+		t.Errorf("drew %d half blocks, want %d", n, 64*16)
+	}
+	white, black := "38;2;255;255;255m", "48;2;0;0;0m"
+	first := got[strings.Index(got, "\x1b[1;1H"):strings.Index(got, "\x1b[2;1H")]
+	want := "\x1b[1;1H\x1b[38;2;255;255;255m\x1b[48;2;0;0;0m▀" + // Top lit
+		"\x1b[38;2;0;0;0m\x1b[48;2;255;255;255m▀" + // Bottom lit
+		"\x1b[38;2;255;255;255m▀" + // Both
+		"\x1b[38;2;0;0;0m\x1b[48;2;0;0;0m▀"
+	if !strings.HasPrefix(first, want) {
+		t.Errorf("first line starts %q", first[:len(want)])
+	}
+	if strings.Count(first, white)+strings.Count(first, black) > 8 {
+		t.Error("colours should only be sent when they change")
+	}
+
+	out.Reset()
+	screen.Draw(&out, &c)
+	if out.Len() != 0 {
+		t.Error("an unchanged display shouldn't be redrawn")
+	}
+
+	c.Memory[0x200], c.Memory[0x201] = 0x00, 0xFF // Switch to high resolution
+	c.EmulateCycle()
+	screen.Draw(&out, &c)
+	if n := strings.Count(out.String(), "▀"); n != 128*32 {
+		t.Errorf("high resolution drew %d half blocks, want %d", n, 128*32)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	got := tui.Decode([]byte("q1 \x1b[A\x1bOD\x1b[1;5C\x1b\x03?"))
+	want := []key.Code{key.CodeQ, key.Code1, key.CodeSpacebar, key.CodeUpArrow,
+		key.CodeLeftArrow, key.CodeRightArrow, key.CodeEscape, key.CodeEscape}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDecoderSplitSequence(t *testing.T) {
+	var d tui.Decoder
+	var got []key.Code
+	for _, read := range []string{"q\x1b", "[", "1;5", "Cw\x1b"} {
+		got = append(got, d.Decode([]byte(read))...)
+	}
+	want := []key.Code{key.CodeQ, key.CodeRightArrow, key.CodeW}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !d.Pending() {
+		t.Fatal("a trailing escape should wait for the next read")
+	}
+	if got := d.Flush(); !reflect.DeepEqual(got, []key.Code{key.CodeEscape}) {
+		t.Errorf("flushed %v, want Escape", got)
+	}
+	if d.Pending() || d.Flush() != nil {
+		t.Error("nothing should be left after a flush")
+	}
+}
+
+func TestKeypadHolds(t *testing.T) {
+	km, err := keymap.DefaultConfig().Keymap("", [20]byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := tui.NewKeypad(km)
+	pad.FirstHold, pad.RepeatHold = 10, 3
+	var c chip8.Chip8
+	frames := func(n int) (held int) {
+		for i := 0; i < n; i++ {
+			pad.Frame(&c)
+			held += int(c.Key[4])
+		}
+		return held
+	}
+
+	if !pad.Press(key.CodeQ) || pad.Press(key.CodeP) {
+		t.Fatal("Q is key 4 and P isn't bound")
+	}
+	if held := frames(20); held != 10 {
+		t.Errorf("a single press was held for %d frames, want 10", held)
+	}
+
+	// Held down: the first press, then repeats every other frame
+	pad.Press(key.CodeQ)
+	frames(8)
+	for i := 0; i < 5; i++ {
+		pad.Press(key.CodeQ)
+		if frames(2) != 2 {
+			t.Fatal("key released between repeats")
+		}
+	}
+	if held := frames(10); held != 1 {
+		t.Errorf("held for %d frames after the last repeat, want 1 more", held)
+	}
+}